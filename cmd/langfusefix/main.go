@@ -0,0 +1,613 @@
+// Command langfusefix migrates code from deprecated langfuse APIs to their
+// replacements, in the spirit of go fix. It rewrites:
+//
+//   - nested params literals, such as
+//     langfuse.GenerationParams{SpanParams: langfuse.SpanParams{ObservationParams: langfuse.ObservationParams{...}}},
+//     to the NewSpan, NewGeneration, NewTool, ... builders with With* options,
+//     including pointer-wrapped values such as Level: langfuse.Ptr(langfuse.LevelError)
+//     or Level: &level, which become langfuse.WithLevel(...);
+//   - id, err := trace.CreateGeneration(params) followed by
+//     client.UpdateGeneration(id, ...) calls to the handle returned by
+//     trace.StartGeneration, and likewise for spans, tools, agents and evaluators.
+//
+// Literals with fields that have no option, or pointer fields set to anything
+// other than &x or langfuse.Ptr(x), are left alone, as are Create calls whose
+// ID is used for anything else or whose error is not checked right away,
+// since StartX returns a nil handle on error; those are reported for manual
+// migration.
+//
+// Usage:
+//
+//	langfusefix [-w] [-l] path ...
+//
+// Without -w or -l the rewritten files are printed to standard output.
+// Directories are processed recursively.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// importPath is the import path of the langfuse package
+const importPath = "github.com/voicefoxai/langfuse-gosdk/langfuse"
+
+var (
+	write = flag.Bool("w", false, "write the result to the source file instead of standard output")
+	list  = flag.Bool("l", false, "list the files that would be changed")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: langfusefix [-w] [-l] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".go") {
+				return nil
+			}
+			return processFile(path)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// processFile fixes one file and writes, lists or prints the result
+func processFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fixed, notes, err := fix(path, src)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		fmt.Fprintln(os.Stderr, note)
+	}
+
+	changed := !bytes.Equal(src, fixed)
+	switch {
+	case *list:
+		if changed {
+			fmt.Println(path)
+		}
+	case *write:
+		if changed {
+			return os.WriteFile(path, fixed, 0o644)
+		}
+	default:
+		_, err = os.Stdout.Write(fixed)
+		return err
+	}
+	return nil
+}
+
+// edit replaces src[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// fixer holds the state of fixing one file
+type fixer struct {
+	fset  *token.FileSet
+	file  *ast.File
+	src   []byte
+	pkg   string // local name of the langfuse package
+	edits []edit
+	notes []string
+}
+
+// fix returns src with the deprecated patterns rewritten, and notes about
+// deprecated calls that need a manual migration
+func fix(path string, src []byte) ([]byte, []string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &fixer{fset: fset, file: file, src: src, pkg: packageName(file)}
+	if f.pkg == "" {
+		return src, nil, nil
+	}
+
+	f.fixLiterals()
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			f.fixCreateCalls(fn.Body)
+		}
+	}
+	if len(f.edits) == 0 {
+		return src, f.notes, nil
+	}
+
+	fixed, err := format.Source(f.apply())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: rewritten file does not parse: %w", path, err)
+	}
+	return fixed, f.notes, nil
+}
+
+// packageName returns the name the file imports the langfuse package under,
+// or an empty string if it does not import it
+func packageName(file *ast.File) string {
+	for _, imp := range file.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path != importPath {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				return ""
+			}
+			return imp.Name.Name
+		}
+		return "langfuse"
+	}
+	return ""
+}
+
+// apply returns the source with the edits applied, skipping edits that
+// overlap an earlier one
+func (f *fixer) apply() []byte {
+	sort.SliceStable(f.edits, func(i, j int) bool { return f.edits[i].start < f.edits[j].start })
+
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range f.edits {
+		if e.start < pos {
+			continue
+		}
+		out.Write(f.src[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(f.src[pos:])
+	return out.Bytes()
+}
+
+func (f *fixer) offset(pos token.Pos) int {
+	return f.fset.Position(pos).Offset
+}
+
+// text returns the source of a node
+func (f *fixer) text(node ast.Node) string {
+	return string(f.src[f.offset(node.Pos()):f.offset(node.End())])
+}
+
+func (f *fixer) note(pos token.Pos, format string, args ...interface{}) {
+	f.notes = append(f.notes, fmt.Sprintf("%s: %s", f.fset.Position(pos), fmt.Sprintf(format, args...)))
+}
+
+// isPkgSelector reports whether expr is the langfuse package's name
+func (f *fixer) isPkgSelector(expr ast.Expr, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == f.pkg && sel.Sel.Name == name
+}
+
+// builders maps the params types to the builder that replaces their literals
+var builders = map[string]string{
+	"SpanParams":       "NewSpan",
+	"GenerationParams": "NewGeneration",
+	"ToolParams":       "NewTool",
+	"AgentParams":      "NewAgent",
+	"ChainParams":      "NewChain",
+	"RetrieverParams":  "NewRetriever",
+	"EvaluatorParams":  "NewEvaluator",
+}
+
+// fieldOption describes the option that sets a params field
+type fieldOption struct {
+	option  string
+	pointer bool // the field is a pointer and the option takes the value
+}
+
+// fieldOptions maps the params fields with an option to it
+var fieldOptions = map[string]fieldOption{
+	"StartTime":           {"WithStartTime", true},
+	"EndTime":             {"WithEndTime", true},
+	"Input":               {"WithInput", false},
+	"Output":              {"WithOutput", false},
+	"Metadata":            {"WithMetadata", false},
+	"Level":               {"WithLevel", true},
+	"StatusMessage":       {"WithStatusMessage", true},
+	"ParentObservationID": {"WithParent", true},
+	"Version":             {"WithVersion", true},
+	"Environment":         {"WithEnvironment", true},
+	"ID":                  {"WithID", true},
+	"Model":               {"WithModel", true},
+	"Provider":            {"WithProvider", true},
+	"ModelParameters":     {"WithModelParameters", false},
+	"Usage":               {"WithUsage", true},
+	"CompletionStartTime": {"WithCompletionStartTime", true},
+	"OutputSchema":        {"WithOutputSchema", false},
+}
+
+// embedded maps each params type to the params type it embeds
+var embedded = map[string]string{
+	"SpanParams":       "ObservationParams",
+	"GenerationParams": "SpanParams",
+	"ToolParams":       "SpanParams",
+	"AgentParams":      "SpanParams",
+	"ChainParams":      "SpanParams",
+	"RetrieverParams":  "SpanParams",
+	"EvaluatorParams":  "SpanParams",
+}
+
+// fixLiterals rewrites the params literals that map to builders
+func (f *fixer) fixLiterals() {
+	ast.Inspect(f.file, func(node ast.Node) bool {
+		lit, ok := node.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		sel, ok := lit.Type.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		builder, ok := builders[sel.Sel.Name]
+		if !ok || !f.isPkgSelector(lit.Type, sel.Sel.Name) {
+			return true
+		}
+
+		call, ok := f.builderCall(lit, sel.Sel.Name, builder)
+		if !ok {
+			return true
+		}
+		f.edits = append(f.edits, edit{start: f.offset(lit.Pos()), end: f.offset(lit.End()), text: call})
+		return false
+	})
+}
+
+// builderCall returns the builder call that replaces a params literal
+func (f *fixer) builderCall(lit *ast.CompositeLit, typeName, builder string) (string, bool) {
+	if f.hasComments(lit) {
+		return "", false
+	}
+
+	name := `""`
+	var options []string
+	if !f.collectOptions(lit, typeName, &name, &options) {
+		return "", false
+	}
+
+	multiline := f.fset.Position(lit.Lbrace).Line != f.fset.Position(lit.Rbrace).Line
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s.%s(%s", f.pkg, builder, name)
+	for _, option := range options {
+		if multiline {
+			b.WriteString(",\n")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(option)
+	}
+	if multiline && len(options) > 0 {
+		b.WriteString(",\n")
+	}
+	b.WriteString(")")
+	return b.String(), true
+}
+
+// collectOptions adds the options for the fields of a params literal of the
+// given type, and of the literals of its embedded params, in source order
+func (f *fixer) collectOptions(lit *ast.CompositeLit, typeName string, name *string, options *[]string) bool {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return false
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return false
+		}
+
+		if inner := embedded[typeName]; inner != "" && key.Name == inner {
+			innerLit, ok := kv.Value.(*ast.CompositeLit)
+			if !ok || !f.isPkgSelector(innerLit.Type, key.Name) {
+				return false
+			}
+			if !f.collectOptions(innerLit, key.Name, name, options) {
+				return false
+			}
+			continue
+		}
+
+		if key.Name == "Name" && typeName == "ObservationParams" {
+			value, ok := f.pointee(kv.Value)
+			if !ok {
+				return false
+			}
+			*name = value
+			continue
+		}
+
+		opt, ok := fieldOptions[key.Name]
+		if !ok || !f.fieldAllowed(typeName, key.Name) {
+			return false
+		}
+		value := f.text(kv.Value)
+		if opt.pointer {
+			if value, ok = f.pointee(kv.Value); !ok {
+				return false
+			}
+		}
+		*options = append(*options, fmt.Sprintf("%s.%s(%s)", f.pkg, opt.option, value))
+	}
+	return true
+}
+
+// fieldAllowed reports whether field belongs to the params type, so that a
+// field of another type is never moved to an option by accident
+func (f *fixer) fieldAllowed(typeName, field string) bool {
+	switch field {
+	case "EndTime":
+		return typeName == "SpanParams"
+	case "Model", "ModelParameters", "Usage", "CompletionStartTime", "OutputSchema":
+		return typeName == "GenerationParams"
+	default:
+		return typeName == "ObservationParams"
+	}
+}
+
+// pointee returns the source of the value a pointer expression points to,
+// if it is &x or langfuse.Ptr(x). Other pointers might be nil, so they cannot
+// be dereferenced safely.
+func (f *fixer) pointee(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return f.text(e.X), true
+		}
+	case *ast.CallExpr:
+		if len(e.Args) == 1 && f.isPkgSelector(e.Fun, "Ptr") {
+			return f.text(e.Args[0]), true
+		}
+	}
+	return "", false
+}
+
+// hasComments reports whether a comment lies within node, which rewriting it would drop
+func (f *fixer) hasComments(node ast.Node) bool {
+	for _, group := range f.file.Comments {
+		if group.Pos() >= node.Pos() && group.End() <= node.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// createMethods maps the deprecated Trace.Create methods to their
+// replacement and the Client update method of their IDs
+var createMethods = map[string]struct{ start, update string }{
+	"CreateSpan":       {"StartSpan", "UpdateSpan"},
+	"CreateGeneration": {"StartGeneration", "UpdateGeneration"},
+	"CreateTool":       {"StartTool", "UpdateTool"},
+	"CreateAgent":      {"StartAgent", ""},
+	"CreateEvaluator":  {"StartEvaluator", "UpdateEvaluator"},
+}
+
+// fixCreateCalls rewrites id, err := trace.CreateX(params) to a handle from
+// trace.StartX if the ID is only passed to client.UpdateX calls in body and
+// err is checked by the next statement
+func (f *fixer) fixCreateCalls(body *ast.BlockStmt) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		stmts := statements(node)
+		for i, stmt := range stmts {
+			var next ast.Stmt
+			if i+1 < len(stmts) {
+				next = stmts[i+1]
+			}
+			f.fixCreateCall(body, stmt, next)
+		}
+		return true
+	})
+}
+
+// statements returns the statement list of a block, case or select clause
+func statements(node ast.Node) []ast.Stmt {
+	switch n := node.(type) {
+	case *ast.BlockStmt:
+		return n.List
+	case *ast.CaseClause:
+		return n.Body
+	case *ast.CommClause:
+		return n.Body
+	}
+	return nil
+}
+
+// fixCreateCall rewrites stmt if it is a Create call that can be migrated;
+// next is the statement after it, if any
+func (f *fixer) fixCreateCall(body *ast.BlockStmt, stmt, next ast.Stmt) {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Rhs) != 1 || len(assign.Lhs) != 2 {
+		return
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	method, ok := createMethods[sel.Sel.Name]
+	if !ok {
+		return
+	}
+
+	id, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || id.Name == "_" || id.Obj == nil {
+		f.note(call.Pos(), "%s is deprecated; migrate to %s by hand", sel.Sel.Name, method.start)
+		return
+	}
+	if errIdent, ok := assign.Lhs[1].(*ast.Ident); !ok || !checksError(next, errIdent.Name) {
+		f.note(call.Pos(), "%s is deprecated; migrate to %s by hand, checking its error, as it returns a nil handle on error",
+			sel.Sel.Name, method.start)
+		return
+	}
+
+	updates, ok := f.idUpdates(body, id, method.update)
+	handle := f.handleName(body, id.Name, strings.TrimPrefix(method.start, "Start"))
+	if !ok || handle == "" {
+		f.note(call.Pos(), "%s is deprecated; migrate to %s by hand", sel.Sel.Name, method.start)
+		return
+	}
+
+	f.edits = append(f.edits,
+		edit{start: f.offset(id.Pos()), end: f.offset(id.End()), text: handle},
+		edit{start: f.offset(sel.Sel.Pos()), end: f.offset(sel.Sel.End()), text: method.start},
+	)
+	for _, update := range updates {
+		target := "Update"
+		if hasField(update.Args[1], "EndTime") {
+			target = "EndWithParams"
+		}
+		f.edits = append(f.edits, edit{
+			start: f.offset(update.Fun.Pos()),
+			end:   f.offset(update.Args[1].Pos()),
+			text:  handle + "." + target + "(",
+		})
+	}
+}
+
+// checksError reports whether stmt is if err != nil { ... } with a body that
+// does not fall through, so the code after it never sees a failed call
+func checksError(stmt ast.Stmt, err string) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) == 0 {
+		return false
+	}
+	cond, ok := ifStmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	y, isIdent := cond.Y.(*ast.Ident)
+	if !ok || !isIdent || x.Name != err || y.Name != "nil" {
+		return false
+	}
+
+	switch last := ifStmt.Body.List[len(ifStmt.Body.List)-1].(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := last.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			return fun.Name == "panic"
+		case *ast.SelectorExpr:
+			switch fun.Sel.Name {
+			case "Fatal", "Fatalf", "Fatalln", "Exit", "Panic", "Panicf", "Panicln", "FailNow", "SkipNow", "Skip", "Skipf":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// idUpdates returns the update calls id is passed to, or false if id is used
+// in any other way in body
+func (f *fixer) idUpdates(body *ast.BlockStmt, id *ast.Ident, update string) ([]*ast.CallExpr, bool) {
+	var updates []*ast.CallExpr
+	used := make(map[*ast.Ident]bool)
+	ast.Inspect(body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		arg, isIdent := call.Args[0].(*ast.Ident)
+		if ok && update != "" && sel.Sel.Name == update && isIdent && arg.Obj == id.Obj {
+			updates = append(updates, call)
+			used[arg] = true
+		}
+		return true
+	})
+
+	otherUse := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if ok && ident != id && ident.Obj == id.Obj && !used[ident] {
+			otherUse = true
+		}
+		return !otherUse
+	})
+	return updates, !otherUse
+}
+
+// handleName returns the name for the handle replacing the ID variable
+// idName, e.g. gen for genID, or an empty string if no name is free in body
+func (f *fixer) handleName(body *ast.BlockStmt, idName, kind string) string {
+	taken := make(map[string]bool)
+	ast.Inspect(body, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok {
+			taken[ident.Name] = true
+		}
+		return true
+	})
+
+	candidates := []string{
+		strings.TrimSuffix(strings.TrimSuffix(idName, "ID"), "Id"),
+		strings.ToLower(kind[:1]) + kind[1:],
+	}
+	for _, name := range candidates {
+		if name != "" && name != idName && !taken[name] && token.IsIdentifier(name) && !token.IsKeyword(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// hasField reports whether expr is a literal that sets field, directly or in
+// a nested literal
+func hasField(expr ast.Expr, field string) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if kv, ok := node.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == field {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
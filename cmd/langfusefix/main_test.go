@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixString runs fix on a source file and fails the test on an error
+func fixString(t *testing.T, src string) (string, []string) {
+	t.Helper()
+	fixed, notes, err := fix("test.go", []byte(src))
+	if err != nil {
+		t.Fatalf("fix: %v", err)
+	}
+	return string(fixed), notes
+}
+
+func TestFixBundledExamples(t *testing.T) {
+	tests := []struct {
+		input, golden string
+		notes         int
+	}{
+		// The generation and tool IDs are created with the error discarded,
+		// so only the literals are rewritten
+		{"trace.go.input", "trace.go.golden", 2},
+		// Uses no deprecated API
+		{"fetch.go.input", "fetch.go.input", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, notes := fixString(t, string(src))
+			if got != string(want) {
+				t.Errorf("fix(%s) does not match %s", tt.input, tt.golden)
+			}
+			if len(notes) != tt.notes {
+				t.Errorf("got notes %q, want %d", notes, tt.notes)
+			}
+		})
+	}
+}
+
+const createSource = `package p
+
+import "github.com/voicefoxai/langfuse-gosdk/langfuse"
+
+func run(client *langfuse.Client, trace *langfuse.Trace, params langfuse.GenerationParams) error {
+	genID, %s := trace.CreateGeneration(langfuse.NewGeneration("llm"))
+%s
+	client.UpdateGeneration(genID, params)
+	return nil
+}
+`
+
+func TestFixMigratesCheckedCreateCall(t *testing.T) {
+	src := strings.Replace(createSource, "%s", "err", 1)
+	src = strings.Replace(src, "%s", "\tif err != nil {\n\t\treturn err\n\t}", 1)
+
+	got, notes := fixString(t, src)
+	for _, want := range []string{
+		"gen, err := trace.StartGeneration(",
+		"gen.Update(params)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fixed source lacks %q:\n%s", want, got)
+		}
+	}
+	if len(notes) != 0 {
+		t.Errorf("got notes %q, want none", notes)
+	}
+}
+
+func TestFixRefusesUncheckedCreateCall(t *testing.T) {
+	tests := map[string]struct{ err, check string }{
+		"discarded":        {"_", ""},
+		"unchecked":        {"err", "\t_ = err"},
+		"logged":           {"err", "\tif err != nil {\n\t\tprintln(err)\n\t}"},
+		"checked too late": {"err", "\tprintln()\n\tif err != nil {\n\t\treturn err\n\t}"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := strings.Replace(createSource, "%s", tt.err, 1)
+			src = strings.Replace(src, "%s", tt.check, 1)
+
+			got, notes := fixString(t, src)
+			if got != src {
+				t.Errorf("fix rewrote a Create call whose error is not checked:\n%s", got)
+			}
+			if len(notes) != 1 || !strings.Contains(notes[0], "nil handle") {
+				t.Errorf("got notes %q, want one about the nil handle", notes)
+			}
+		})
+	}
+}
+
+func TestFixRewritesEndingUpdateToEndWithParams(t *testing.T) {
+	src := strings.Replace(createSource, "%s", "err", 1)
+	src = strings.Replace(src, "%s", "\tif err != nil {\n\t\tpanic(err)\n\t}", 1)
+	src = strings.Replace(src, "genID, params)",
+		"genID, langfuse.GenerationParams{SpanParams: langfuse.SpanParams{EndTime: langfuse.Ptr(time.Now())}})", 1)
+
+	got, _ := fixString(t, src)
+	if !strings.Contains(got, "gen.EndWithParams(langfuse.NewGeneration(\"\", langfuse.WithEndTime(time.Now())))") {
+		t.Errorf("update setting EndTime was not rewritten to EndWithParams:\n%s", got)
+	}
+}
+
+func TestFixRewritesPointerLevel(t *testing.T) {
+	src := `package p
+
+import "github.com/voicefoxai/langfuse-gosdk/langfuse"
+
+var params = langfuse.SpanParams{ObservationParams: langfuse.ObservationParams{Name: langfuse.Ptr("step"), Level: langfuse.Ptr(langfuse.LevelError)}}
+`
+	got, _ := fixString(t, src)
+	if want := `langfuse.NewSpan("step", langfuse.WithLevel(langfuse.LevelError))`; !strings.Contains(got, want) {
+		t.Errorf("fixed source lacks %q:\n%s", want, got)
+	}
+}
+
+func TestFixLeavesLiteralsWithoutOptionsAlone(t *testing.T) {
+	src := `package p
+
+import "github.com/voicefoxai/langfuse-gosdk/langfuse"
+
+func level() *langfuse.ObservationLevel { return nil }
+
+var params = langfuse.SpanParams{ObservationParams: langfuse.ObservationParams{Level: level()}}
+`
+	if got, _ := fixString(t, src); got != src {
+		t.Errorf("fix rewrote a literal with a pointer that may be nil:\n%s", got)
+	}
+}
@@ -23,10 +23,10 @@ func main() {
 	fmt.Println("  Langfuse Fetch Data Example")
 	fmt.Println("========================================")
 
-	config := langfuse.DefaultConfig()
-	config.PublicKey = os.Getenv("LANGFUSE_PUBLIC_KEY")
-	config.SecretKey = os.Getenv("LANGFUSE_SECRET_KEY")
-	config.BaseURL = os.Getenv("LANGFUSE_BASE_URL")
+	config, err := langfuse.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load Langfuse config: %v", err)
+	}
 	config.Debug = true
 
 	client, err := langfuse.NewClient(config)
@@ -258,6 +258,11 @@ func printTrace(trace *langfuse.TraceWithFullDetails) {
 	if len(trace.Scores) > 0 {
 		fmt.Printf("  Scores: %d\n", len(trace.Scores))
 		for i, score := range trace.Scores {
+			if score.StringValue != nil {
+				fmt.Printf("    [%d] Name: %s, Value: %s, Type: %s\n",
+					i+1, score.Name, *score.StringValue, score.DataType)
+				continue
+			}
 			fmt.Printf("    [%d] Name: %s, Value: %.2f, Type: %s\n",
 				i+1, score.Name, score.Value, score.DataType)
 		}
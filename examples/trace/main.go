@@ -29,10 +29,10 @@ func main() {
 		log.Fatal("OPENAI_API_KEY is required. Please set it in .env file")
 	}
 
-	langfuseConfig := langfuse.DefaultConfig()
-	langfuseConfig.PublicKey = os.Getenv("LANGFUSE_PUBLIC_KEY")
-	langfuseConfig.SecretKey = os.Getenv("LANGFUSE_SECRET_KEY")
-	langfuseConfig.BaseURL = os.Getenv("LANGFUSE_BASE_URL")
+	langfuseConfig, err := langfuse.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 	langfuseConfig.Debug = true
 
 	langfuseClient, err := langfuse.NewClient(langfuseConfig)
@@ -106,22 +106,20 @@ func main() {
 
 	// Step 3: request model
 	genStartTime := time.Now()
-	genParams := langfuse.GenerationParams{
-		SpanParams: langfuse.SpanParams{
-			ObservationParams: langfuse.ObservationParams{
-				Name:      langfuse.Ptr("llm-generation"),
-				StartTime: &genStartTime,
-				Input: map[string]any{
-					"messages": messages,
-					"tools":    tools,
-				},
-			},
-		},
+	genParams := langfuse.NewGeneration("llm-generation",
+		langfuse.WithStartTime(genStartTime),
+		langfuse.WithInput(map[string]any{
+			"messages": messages,
+			"tools":    tools,
+		}),
+		langfuse.WithModel(openaiModel),
+		langfuse.WithModelParameters(map[string]any{"temperature": 0.7}),
+	)
+
+	gen, err := trace.StartGeneration(genParams)
+	if err != nil {
+		log.Fatal(err)
 	}
-	genParams.Model = &openaiModel
-	genParams.ModelParameters = map[string]any{"temperature": 0.7}
-
-	genID, _ := trace.CreateGeneration(genParams)
 
 	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model:    openaiModel,
@@ -131,15 +129,11 @@ func main() {
 	if err != nil {
 		genEndTime := time.Now()
 		level := langfuse.LevelError
-		langfuseClient.UpdateGeneration(genID, langfuse.GenerationParams{
-			SpanParams: langfuse.SpanParams{
-				ObservationParams: langfuse.ObservationParams{
-					StatusMessage: langfuse.Ptr(err.Error()),
-					Level:         &level,
-				},
-				EndTime: &genEndTime,
-			},
-		})
+		gen.EndWithParams(langfuse.NewGeneration("",
+			langfuse.WithStatusMessage(err.Error()),
+			langfuse.WithLevel(level),
+			langfuse.WithEndTime(genEndTime),
+		))
 		panic(err)
 	}
 
@@ -161,35 +155,29 @@ func main() {
 		var argsMap map[string]any
 		json.Unmarshal([]byte(toolCall.Function.Arguments), &argsMap)
 
-		toolID, _ := trace.CreateTool(langfuse.ToolParams{
-			SpanParams: langfuse.SpanParams{
-				ObservationParams: langfuse.ObservationParams{
-					Name:      langfuse.Ptr("tool-get_weather"),
-					StartTime: &toolStartTime,
-					Input: map[string]any{
-						"tool_name": toolCall.Function.Name,
-						"tool_id":   toolCall.ID,
-						"arguments": argsMap,
-					},
-				},
-			},
-		})
+		tool, err := trace.StartTool(langfuse.NewTool("tool-get_weather",
+			langfuse.WithStartTime(toolStartTime),
+			langfuse.WithInput(map[string]any{
+				"tool_name": toolCall.Function.Name,
+				"tool_id":   toolCall.ID,
+				"arguments": argsMap,
+			}),
+		))
+		if err != nil {
+			log.Printf("Warning: failed to create tool observation: %v", err)
+		}
 
 		// Step 5: execute tool
 		result := getWeather(args.City)
-		toolEndTime := time.Now()
-
-		// Update Tool observation with result
-		langfuseClient.UpdateTool(toolID, langfuse.ToolParams{
-			SpanParams: langfuse.SpanParams{
-				ObservationParams: langfuse.ObservationParams{
-					Output: map[string]any{
-						"result": result,
-					},
-				},
-				EndTime: &toolEndTime,
-			},
-		})
+
+		// End Tool observation with result
+		if tool != nil {
+			tool.EndWithParams(langfuse.NewTool("",
+				langfuse.WithOutput(map[string]any{
+					"result": result,
+				}),
+			))
+		}
 
 		// Step 6: append tool result to messages
 		messages = append(messages, msg)
@@ -212,31 +200,27 @@ func main() {
 		finalEndTime := time.Now()
 
 		// Update generation with complete information
-		usage := langfuse.Usage{
-			Input:  langfuse.Ptr(resp.Usage.PromptTokens + finalResp.Usage.PromptTokens),
-			Output: langfuse.Ptr(resp.Usage.CompletionTokens + finalResp.Usage.CompletionTokens),
-			Total:  langfuse.Ptr(resp.Usage.TotalTokens + finalResp.Usage.TotalTokens),
-		}
+		usage := langfuse.UsageFromOpenAI(
+			resp.Usage.PromptTokens+finalResp.Usage.PromptTokens,
+			resp.Usage.CompletionTokens+finalResp.Usage.CompletionTokens,
+			resp.Usage.TotalTokens+finalResp.Usage.TotalTokens,
+		)
 
 		// Re-parse args for generation update
 		json.Unmarshal([]byte(toolCall.Function.Arguments), &argsMap)
 
-		langfuseClient.UpdateGeneration(genID, langfuse.GenerationParams{
-			SpanParams: langfuse.SpanParams{
-				ObservationParams: langfuse.ObservationParams{
-					Output: map[string]any{
-						"tool_calls": map[string]any{
-							"tool_name": toolCall.Function.Name,
-							"arguments": argsMap,
-							"result":    result,
-						},
-						"final_response": finalMsg.Content,
-					},
+		gen.EndWithParams(langfuse.NewGeneration("",
+			langfuse.WithOutput(map[string]any{
+				"tool_calls": map[string]any{
+					"tool_name": toolCall.Function.Name,
+					"arguments": argsMap,
+					"result":    result,
 				},
-				EndTime: &finalEndTime,
-			},
-			Usage: &usage,
-		})
+				"final_response": finalMsg.Content,
+			}),
+			langfuse.WithEndTime(finalEndTime),
+			langfuse.WithUsage(usage),
+		))
 
 		// Update trace with output and session metadata
 		trace.Update(langfuse.TraceParams{
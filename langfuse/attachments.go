@@ -0,0 +1,231 @@
+package langfuse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MetadataKeyAttachments is the observation metadata key holding the list of
+// attachments recorded with Attach
+const MetadataKeyAttachments = "attachments"
+
+// MaxAttachmentBytes is the largest attachment Attach accepts
+const MaxAttachmentBytes = 10 << 20
+
+// MaxInlineAttachmentBytes is the largest attachment that is stored inline, as
+// base64 in the observation metadata, when it cannot be uploaded to the media API
+const MaxInlineAttachmentBytes = 64 << 10
+
+// Attachment describes a file attached to an observation, such as generated SQL
+// or a rendered HTML page. Its content is either stored with the media API
+// (MediaID) or inline (Data). If both uploading and inlining failed, only the
+// description is recorded and Error says why.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"` // base64-encoded SHA-256 of the content
+	MediaID     string `json:"mediaId,omitempty"`
+	Data        string `json:"data,omitempty"` // base64-encoded content, if stored inline
+	Error       string `json:"error,omitempty"`
+}
+
+// Content returns the content of an attachment stored inline. Attachments stored
+// with the media API must be downloaded from Langfuse by MediaID.
+func (a Attachment) Content() ([]byte, error) {
+	if a.Data == "" {
+		if a.MediaID != "" {
+			return nil, fmt.Errorf("attachment %q is stored as media %s", a.Name, a.MediaID)
+		}
+		return nil, fmt.Errorf("attachment %q has no content: %s", a.Name, a.Error)
+	}
+	return base64.StdEncoding.DecodeString(a.Data)
+}
+
+// Attachments returns the attachments recorded in the observation's metadata
+func (o *ObservationDetails) Attachments() ([]Attachment, error) {
+	raw, ok := o.Metadata[MetadataKeyAttachments]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachments: %w", err)
+	}
+	var attachments []Attachment
+	if err := json.Unmarshal(data, &attachments); err != nil {
+		return nil, fmt.Errorf("failed to read attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// attachmentList accumulates the attachments of one observation handle
+type attachmentList struct {
+	mu          sync.Mutex
+	attachments []Attachment
+}
+
+// Attach stores data as an attachment of the observation and records it, with
+// any earlier attachments of this handle, in the observation's metadata
+func (h *observationHandle) Attach(ctx context.Context, name, contentType string, data []byte) error {
+	attachment, err := h.client.uploadAttachment(ctx, h.traceID, h.id, name, contentType, data)
+	if err != nil {
+		return err
+	}
+
+	h.attachments.mu.Lock()
+	defer h.attachments.mu.Unlock()
+
+	h.attachments.attachments = append(h.attachments.attachments, attachment)
+	return h.client.recordAttachments(h.traceID, h.id, h.obsType, h.attachments.attachments)
+}
+
+// Attach stores data as an attachment of an observation and records it in the
+// observation's metadata. The attachment replaces any attachments recorded
+// earlier; use the Attach method of a handle to attach several files.
+func (c *Client) Attach(ctx context.Context, traceID, observationID string, obsType ObservationType, name, contentType string, data []byte) (Attachment, error) {
+	attachment, err := c.uploadAttachment(ctx, traceID, observationID, name, contentType, data)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachment, c.recordAttachments(traceID, observationID, obsType, []Attachment{attachment})
+}
+
+// recordAttachments sets the attachments metadata of an observation
+func (c *Client) recordAttachments(traceID, observationID string, obsType ObservationType, attachments []Attachment) error {
+	list := make([]Attachment, len(attachments))
+	copy(list, attachments)
+
+	return c.UpdateObservation(observationID, obsType, SpanParams{
+		ObservationParams: ObservationParams{
+			TraceID:  traceID,
+			Metadata: map[string]interface{}{MetadataKeyAttachments: list},
+		},
+	})
+}
+
+// uploadAttachment stores data with the media API. If that fails, small
+// attachments are inlined and larger ones are recorded without content.
+func (c *Client) uploadAttachment(ctx context.Context, traceID, observationID, name, contentType string, data []byte) (Attachment, error) {
+	if name == "" {
+		return Attachment{}, errors.New("attachment name is required")
+	}
+	if len(data) > MaxAttachmentBytes {
+		return Attachment{}, fmt.Errorf("attachment %q is %d bytes, the limit is %d", name, len(data), MaxAttachmentBytes)
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := Attachment{
+		Name:        name,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      base64.StdEncoding.EncodeToString(sum[:]),
+	}
+
+	mediaID, err := c.uploadMedia(ctx, traceID, observationID, contentType, data, attachment.SHA256)
+	if err == nil {
+		attachment.MediaID = mediaID
+		return attachment, nil
+	}
+
+	c.config.logger().Debugf("Failed to upload attachment %q: %v", name, err)
+	if len(data) <= MaxInlineAttachmentBytes {
+		attachment.Data = base64.StdEncoding.EncodeToString(data)
+	} else {
+		attachment.Error = fmt.Sprintf("media upload failed: %v", err)
+	}
+	return attachment, nil
+}
+
+// mediaUploadRequest requests an upload URL from the media API
+type mediaUploadRequest struct {
+	TraceID       string `json:"traceId"`
+	ObservationID string `json:"observationId,omitempty"`
+	ContentType   string `json:"contentType"`
+	ContentLength int    `json:"contentLength"`
+	SHA256Hash    string `json:"sha256Hash"`
+	Field         string `json:"field"`
+}
+
+// mediaUploadResponse is the media API's answer to a mediaUploadRequest.
+// UploadURL is nil if the same content was uploaded before.
+type mediaUploadResponse struct {
+	UploadURL *string `json:"uploadUrl"`
+	MediaID   string  `json:"mediaId"`
+}
+
+// mediaUploadResult reports the outcome of an upload back to the media API
+type mediaUploadResult struct {
+	UploadedAt       string  `json:"uploadedAt"`
+	UploadHTTPStatus int     `json:"uploadHttpStatus"`
+	UploadHTTPError  *string `json:"uploadHttpError,omitempty"`
+	UploadTimeMs     int64   `json:"uploadTimeMs"`
+}
+
+// uploadMedia uploads data with the media API and returns its media ID
+func (c *Client) uploadMedia(ctx context.Context, traceID, observationID, contentType string, data []byte, sha256Hash string) (string, error) {
+	if !c.config.Enabled {
+		return "", fmt.Errorf("client is disabled")
+	}
+
+	result, err := c.doJSON(ctx, "POST", c.config.BaseURL+"/api/public/media", mediaUploadRequest{
+		TraceID:       traceID,
+		ObservationID: observationID,
+		ContentType:   contentType,
+		ContentLength: len(data),
+		SHA256Hash:    sha256Hash,
+		Field:         "metadata",
+	}, &mediaUploadResponse{})
+	if err != nil {
+		return "", fmt.Errorf("failed to request media upload: %w", err)
+	}
+	upload := result.(*mediaUploadResponse)
+	if upload.UploadURL == nil {
+		return upload.MediaID, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", *upload.UploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Checksum-Sha256", sha256Hash)
+
+	start := time.Now()
+	report := mediaUploadResult{}
+	var uploadErr error
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		uploadErr = NewNetworkError(err)
+		message := err.Error()
+		report.UploadHTTPError = &message
+	} else {
+		resp.Body.Close()
+		report.UploadHTTPStatus = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			uploadErr = NewHTTPError(resp.StatusCode, resp.Status)
+			report.UploadHTTPError = &resp.Status
+		}
+	}
+	report.UploadedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	report.UploadTimeMs = time.Since(start).Milliseconds()
+
+	patchURL := fmt.Sprintf("%s/api/public/media/%s", c.config.BaseURL, url.PathEscape(upload.MediaID))
+	if _, err := c.doJSON(ctx, "PATCH", patchURL, report, nil); err != nil && uploadErr == nil {
+		return "", fmt.Errorf("failed to confirm media upload: %w", err)
+	}
+	if uploadErr != nil {
+		return "", fmt.Errorf("failed to upload media: %w", uploadErr)
+	}
+	return upload.MediaID, nil
+}
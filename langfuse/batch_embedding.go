@@ -0,0 +1,191 @@
+package langfuse
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetadataKeyEmbeddingBatches is the trace metadata key holding the rolled-up
+// BatchEmbeddingSummary written by BatchEmbedding.End
+const MetadataKeyEmbeddingBatches = "embeddingBatches"
+
+// BatchEmbeddingParams contains parameters for a batch embedding job
+type BatchEmbeddingParams struct {
+	// Name is the name of every batch observation (default: "embedding-batch")
+	Name string
+
+	// EmbeddingModel is the embedding model name/identifier
+	EmbeddingModel *string
+
+	// Provider is the embedding model provider, see EmbeddingParams.Provider
+	Provider *string
+
+	// EmbeddingModelParameters are parameters passed to the embedding model
+	EmbeddingModelParameters map[string]interface{}
+
+	// ParentObservationID nests the batch observations under an observation
+	ParentObservationID *string
+
+	// SampleEvery captures every Nth item's input and output, counted across
+	// all batches, as an exemplar for debugging (default: 0, no samples)
+	SampleEvery int
+}
+
+// EmbeddingBatch is the result of embedding one batch of items
+type EmbeddingBatch struct {
+	// Inputs are the items of the batch; only sampled items are sent
+	Inputs []interface{}
+
+	// Outputs are the results aligned with Inputs (e.g. vector dimensions); optional
+	Outputs []interface{}
+
+	// Failures is the number of items that could not be embedded
+	Failures int
+
+	// Usage is the token usage of the batch
+	Usage *Usage
+
+	// StartTime and EndTime bound the embedding call (EndTime defaults to now)
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// BatchEmbeddingSummary aggregates all batches recorded by a BatchEmbedding
+type BatchEmbeddingSummary struct {
+	Batches     int   `json:"batches"`
+	Items       int   `json:"items"`
+	Failures    int   `json:"failures"`
+	TotalTokens int   `json:"totalTokens"`
+	DurationMs  int64 `json:"durationMs"`
+}
+
+// BatchEmbedding records an embedding job as one EMBEDDING observation per batch
+// with aggregate stats and sampled items, instead of one observation per item.
+// It is safe for concurrent use.
+type BatchEmbedding struct {
+	trace  *Trace
+	params BatchEmbeddingParams
+
+	mu      sync.Mutex
+	summary BatchEmbeddingSummary
+	seen    int // items seen across batches, for sampling
+}
+
+// StartBatchEmbedding starts recording a batch embedding job on the trace
+func (t *Trace) StartBatchEmbedding(params BatchEmbeddingParams) *BatchEmbedding {
+	if params.Name == "" {
+		params.Name = "embedding-batch"
+	}
+	return &BatchEmbedding{trace: t, params: params}
+}
+
+// RecordBatch creates the EMBEDDING observation of one batch and returns its ID
+func (b *BatchEmbedding) RecordBatch(batch EmbeddingBatch) (string, error) {
+	if batch.EndTime.IsZero() {
+		batch.EndTime = time.Now()
+	}
+	if batch.StartTime.IsZero() {
+		batch.StartTime = batch.EndTime
+	}
+	duration := batch.EndTime.Sub(batch.StartTime)
+
+	b.mu.Lock()
+	index := b.summary.Batches
+	samples := b.sampleLocked(batch)
+	b.summary.Batches++
+	b.summary.Items += len(batch.Inputs)
+	b.summary.Failures += batch.Failures
+	if batch.Usage != nil {
+		b.summary.TotalTokens += batchTokens(*batch.Usage)
+	}
+	b.summary.DurationMs += duration.Milliseconds()
+	b.mu.Unlock()
+
+	output := map[string]interface{}{
+		"items":    len(batch.Inputs),
+		"failures": batch.Failures,
+	}
+	if len(samples) > 0 {
+		output["samples"] = samples
+	}
+
+	params := EmbeddingParams{
+		SpanParams: SpanParams{
+			ObservationParams: ObservationParams{
+				Name:                Ptr(fmt.Sprintf("%s-%d", b.params.Name, index)),
+				ParentObservationID: b.params.ParentObservationID,
+				StartTime:           &batch.StartTime,
+				Output:              output,
+				Metadata: map[string]interface{}{
+					"batchIndex": index,
+					"itemCount":  len(batch.Inputs),
+					"failures":   batch.Failures,
+					"durationMs": duration.Milliseconds(),
+				},
+			},
+			EndTime: &batch.EndTime,
+		},
+		EmbeddingModel:           b.params.EmbeddingModel,
+		Provider:                 b.params.Provider,
+		EmbeddingModelParameters: b.params.EmbeddingModelParameters,
+		Usage:                    batch.Usage,
+	}
+	if batch.Failures > 0 {
+		params.Level = Ptr(LevelWarning)
+	}
+
+	return b.trace.CreateEmbedding(params)
+}
+
+// batchTokens returns the total token count of a batch usage,
+// falling back to input + output when the total is not set
+func batchTokens(u Usage) int {
+	if u.Total != nil {
+		return *u.Total
+	}
+	var tokens int
+	if u.Input != nil {
+		tokens += *u.Input
+	}
+	if u.Output != nil {
+		tokens += *u.Output
+	}
+	return tokens
+}
+
+// sampleLocked returns the sampled items of a batch and advances the item
+// counter. The caller must hold b.mu.
+func (b *BatchEmbedding) sampleLocked(batch EmbeddingBatch) []map[string]interface{} {
+	var samples []map[string]interface{}
+	for i, input := range batch.Inputs {
+		b.seen++
+		if b.params.SampleEvery <= 0 || (b.seen-1)%b.params.SampleEvery != 0 {
+			continue
+		}
+		sample := map[string]interface{}{"index": i, "input": input}
+		if i < len(batch.Outputs) {
+			sample["output"] = batch.Outputs[i]
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// Summary returns the aggregate stats of the batches recorded so far
+func (b *BatchEmbedding) Summary() BatchEmbeddingSummary {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.summary
+}
+
+// End writes the rolled-up summary into the trace metadata under
+// MetadataKeyEmbeddingBatches
+func (b *BatchEmbedding) End() error {
+	summary := b.Summary()
+	return b.trace.Update(TraceParams{
+		Metadata: map[string]interface{}{
+			MetadataKeyEmbeddingBatches: summary,
+		},
+	})
+}
@@ -2,27 +2,36 @@ package langfuse
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
 
 // Batcher handles batching and async sending of events
 type Batcher struct {
-	client   *Client
+	client   *clientCore
 	config   *Config
 	queue    []Event
 	mu       sync.Mutex
-	ticker   *time.Ticker
+	ticker   Ticker
 	done     chan struct{}
 	wg       sync.WaitGroup
-	attempts map[string]int // Track retry attempts per event batch
+	attempts map[string]int // Track retry attempts per event ID
+	retryAt  time.Time      // Background flushes wait until then after a retryable error
+	persist  *persistentQueue
+	dirty    bool           // The queue changed in ways the persistent queue's appends don't mirror
+	overflow *overflowQueue // Events that did not fit in the queue, with Config.OverflowToDisk
+	space    chan struct{}  // Closed when the queue shrinks, for AddContext callers waiting on a full queue
+
+	deadLetters *deadLetterStore // Events given up on, with Config.DeadLetterPath
 }
 
 // NewBatcher creates a new batcher
 func NewBatcher(client *Client, config *Config) *Batcher {
 	return &Batcher{
-		client: client,
+		client: client.clientCore,
 		config: config,
 		queue:  make([]Event, 0, config.MaxQueueSize),
 		done:   make(chan struct{}),
@@ -31,18 +40,16 @@ func NewBatcher(client *Client, config *Config) *Batcher {
 
 // Start begins the background flush loop
 func (b *Batcher) Start() {
-	b.ticker = time.NewTicker(b.config.FlushInterval)
+	b.ticker = b.config.clock().NewTicker(b.config.FlushInterval)
 	b.wg.Add(1)
 
 	go func() {
 		defer b.wg.Done()
 		for {
 			select {
-			case <-b.ticker.C:
-				if err := b.Flush(context.Background()); err != nil {
-					if b.config.Debug {
-						log.Printf("[Langfuse] Error flushing events: %v", err)
-					}
+			case <-b.ticker.C():
+				if err := b.tick(context.Background()); err != nil {
+					b.config.logger().Debugf("Error flushing events: %v", err)
 				}
 			case <-b.done:
 				b.ticker.Stop()
@@ -50,10 +57,29 @@ func (b *Batcher) Start() {
 			}
 		}
 	}()
+
+	if b.overflow != nil {
+		b.wg.Add(1)
+		go b.replayOverflow()
+	}
 }
 
-// Add adds an event to the queue
+// Add adds an event to the queue. If the queue is full, the event is handled
+// as set by Config.QueueFullBehavior.
 func (b *Batcher) Add(event Event) error {
+	return b.add(nil, event)
+}
+
+// AddContext adds an event to the queue like Add, but if the queue is full it
+// waits for a flush to make room until ctx is done, and only then drops the
+// event
+func (b *Batcher) AddContext(ctx context.Context, event Event) error {
+	return b.add(ctx, event)
+}
+
+// add adds an event to the queue. With a nil ctx, an event that does not fit
+// is handled as set by Config.QueueFullBehavior.
+func (b *Batcher) add(ctx context.Context, event Event) error {
 	// Record metrics if enabled
 	if b.config.MetricsEnabled {
 		b.client.metrics.RecordEnqueued(1)
@@ -62,69 +88,301 @@ func (b *Batcher) Add(event Event) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// With an overflow file, the event is spilled rather than dropped or waited on
+	if len(b.queue) >= b.config.MaxQueueSize && b.overflow != nil && b.spillLocked(event) {
+		return nil
+	}
+
+	blocking := false
+	if ctx == nil && b.config.QueueFullBehavior == QueueFullBlock && len(b.queue) >= b.config.MaxQueueSize {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), b.config.queueFullTimeout())
+		defer cancel()
+		blocking = true
+	}
+
 	// Check if queue is full
-	if len(b.queue) >= b.config.MaxQueueSize {
-		if b.config.Debug {
-			log.Printf("[Langfuse] Queue is full (%d events), dropping event", len(b.queue))
+	for len(b.queue) >= b.config.MaxQueueSize {
+		if ctx == nil && b.config.QueueFullBehavior == QueueFullDropOldest {
+			b.dropOldestLocked()
+			continue
 		}
+		if ctx == nil {
+			b.config.logger().Debugf("Queue is full (%d events), dropping event", len(b.queue))
+			b.recordDropped(1, DropReasonQueueFull)
 
-		// Record dropped event
-		if b.config.MetricsEnabled {
-			b.client.metrics.RecordDropped(1)
+			return &QueueFullError{MaxSize: b.config.MaxQueueSize}
 		}
+		if err := b.waitForSpaceLocked(ctx); err != nil {
+			b.config.logger().Debugf("Queue is full (%d events), dropping event: %v", len(b.queue), err)
+			b.recordDropped(1, DropReasonQueueFull)
 
-		// Call drop callback if provided
-		if b.config.OnEventDropped != nil {
-			go b.config.OnEventDropped(1)
+			if blocking && errors.Is(err, context.DeadlineExceeded) {
+				return &QueueFullError{MaxSize: b.config.MaxQueueSize}
+			}
+			return err
 		}
-
-		return &QueueFullError{MaxSize: b.config.MaxQueueSize}
 	}
 
+	if b.persist != nil {
+		// The queue file holds encoded events, so lazy values are resolved now
+		event = resolveLazyMetadata(event, b.config.logger())
+	}
 	b.queue = append(b.queue, event)
+	if b.persist != nil {
+		if err := b.persist.append(event); err != nil {
+			b.config.logger().Warnf("failed to persist event: %v", err)
+			b.dirty = true
+		}
+	}
 
-	// Auto-flush if we've reached FlushAt threshold
-	// Use async flush to avoid blocking the caller
-	if len(b.queue) >= b.config.FlushAt {
+	b.autoFlushLocked()
+	return nil
+}
+
+// autoFlushLocked starts a flush if FlushAt events are queued, unless backing
+// off. The flush is async to avoid blocking the caller, who must hold b.mu.
+func (b *Batcher) autoFlushLocked() {
+	if len(b.queue) >= b.config.FlushAt && !b.backingOffLocked() {
 		go func() {
 			if err := b.Flush(context.Background()); err != nil {
-				if b.config.Debug {
-					log.Printf("[Langfuse] Error auto-flushing: %v", err)
-				}
+				b.config.logger().Debugf("Error auto-flushing: %v", err)
 			}
 		}()
 	}
+}
 
-	return nil
+// dropOldestLocked evicts the oldest queued event to make room for a new one.
+// The caller must hold b.mu.
+func (b *Batcher) dropOldestLocked() {
+	oldest := b.queue[0]
+	copy(b.queue, b.queue[1:])
+	b.queue = b.queue[:len(b.queue)-1]
+	b.dirty = true
+	delete(b.attempts, oldest.ID)
+
+	b.config.logger().Debugf("Queue is full (%d events), dropping oldest event", b.config.MaxQueueSize)
+	b.recordDropped(1, DropReasonQueueFull)
+}
+
+// waitForSpaceLocked releases b.mu until the queue shrinks, ctx is done or
+// the batcher is stopped, and reacquires it. The caller must hold b.mu.
+func (b *Batcher) waitForSpaceLocked(ctx context.Context) error {
+	if b.space == nil {
+		b.space = make(chan struct{})
+	}
+	space := b.space
+
+	b.mu.Unlock()
+	defer b.mu.Lock()
+
+	select {
+	case <-space:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for queue space: %w", ctx.Err())
+	case <-b.done:
+		return fmt.Errorf("client is closed")
+	}
 }
 
-// Flush sends all queued events immediately
+// freeSpaceLocked wakes the AddContext callers waiting for queue space. The
+// caller must hold b.mu.
+func (b *Batcher) freeSpaceLocked() {
+	if b.space != nil {
+		close(b.space)
+		b.space = nil
+	}
+}
+
+// tick runs one cycle of the background flush loop: it flushes unless the
+// batcher is backing off after a retryable error
+func (b *Batcher) tick(ctx context.Context) error {
+	b.mu.Lock()
+	backingOff := b.backingOffLocked()
+	b.mu.Unlock()
+
+	if backingOff {
+		return nil
+	}
+	return b.Flush(ctx)
+}
+
+// backingOffLocked reports whether background flushes should wait for the
+// retry backoff to pass. The caller must hold b.mu.
+func (b *Batcher) backingOffLocked() bool {
+	return !b.retryAt.IsZero() && b.config.clock().Now().Before(b.retryAt)
+}
+
+// Flush sends all queued events immediately, even while backing off
 func (b *Batcher) Flush(ctx context.Context) error {
+	_, err := b.flush(ctx)
+	return err
+}
+
+// flushResult counts the outcome of one flush
+type flushResult struct {
+	delivered    int // accepted by the API
+	rejected     int // rejected by the API or discarded after a non-retryable error
+	deadLettered int // of the rejected, written to the dead-letter file
+}
+
+// flush sends all queued events and reports what happened to them.
+// Events that failed with a retryable error are back in the queue and counted in neither field.
+func (b *Batcher) flush(ctx context.Context) (flushResult, error) {
+	var result flushResult
+	defer b.persistQueue()
+
 	b.mu.Lock()
 
+	if b.client.authDisabled.Load() {
+		result.rejected = len(b.queue)
+		b.discardLocked(&LangfuseError{Code: "CLIENT_DISABLED", Message: "client disabled after authentication error"}, DropReasonAuthDisabled)
+		b.mu.Unlock()
+		return result, nil
+	}
+
+	stale := b.dropStaleLocked()
+
 	if len(b.queue) == 0 {
 		b.mu.Unlock()
-		return nil
+		b.recordDropped(stale, DropReasonExpired)
+		return result, nil
 	}
 
 	// Take all events from queue
 	events := make([]Event, len(b.queue))
 	copy(events, b.queue)
 	b.queue = b.queue[:0] // Clear queue
+	b.dirty = true
+	b.freeSpaceLocked()
 
 	b.mu.Unlock()
 
-	// Send events
-	req := &IngestionRequest{
-		Batch: events,
+	b.recordDropped(stale, DropReasonExpired)
+
+	logger := b.config.logger()
+	for i := range events {
+		events[i] = resolveLazyMetadata(events[i], logger)
+	}
+
+	if b.config.BeforeFlush != nil {
+		var invalid int
+		events, invalid = b.beforeFlush(events)
+		result.rejected += invalid
 	}
 
-	resp, err := b.client.sendIngestion(ctx, req)
+	if b.config.DisableMetadataOnEvents {
+		for i := range events {
+			events[i].Metadata = nil
+		}
+	}
+
+	events = orderByTrace(events)
+
+	batches, oversized, unserializable := b.splitBatches(events)
+	if len(unserializable) > 0 {
+		result.rejected += len(unserializable)
+		b.recordUnserializable(unserializable)
+	}
+	if len(oversized) > 0 {
+		result.rejected += len(oversized)
+		b.recordOversized(oversized)
+		result.deadLettered += b.deadLetter(oversized)
+	}
+
+	for i, batch := range batches {
+		batchResult, err := b.sendBatch(ctx, batch)
+		result.delivered += batchResult.delivered
+		result.rejected += batchResult.rejected
+		result.deadLettered += batchResult.deadLettered
+		if err != nil {
+			// Keep the batches that were not attempted for the next flush,
+			// behind the failed batch's events that are back for retry, so
+			// each trace's events are still sent in order
+			var unsent []Event
+			for _, rest := range batches[i+1:] {
+				unsent = append(unsent, rest...)
+			}
+			if len(unsent) > 0 {
+				retried := len(batch) - batchResult.rejected
+				b.mu.Lock()
+				retried = min(retried, len(b.queue))
+				queue := make([]Event, 0, len(b.queue)+len(unsent))
+				queue = append(queue, b.queue[:retried]...)
+				queue = append(queue, unsent...)
+				b.queue = append(queue, b.queue[retried:]...)
+				b.dirty = true
+				b.mu.Unlock()
+			}
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// beforeFlush passes events through Config.BeforeFlush, discarding returned
+// events that lack required fields. It returns the events to send and the
+// number discarded.
+func (b *Batcher) beforeFlush(events []Event) (result []Event, invalid int) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.config.logger().Errorf("BeforeFlush panicked, sending events unchanged: %v", r)
+			result, invalid = events, 0
+		}
+	}()
+
+	// Pass a copy so the callback can't reorder the caller's slice
+	batch := make([]Event, len(events))
+	copy(batch, events)
+
+	err := &LangfuseError{Code: "INVALID_EVENT", Message: "event returned by BeforeFlush lacks an ID, type, timestamp or body"}
+	returned := make(map[string]bool, len(events))
+	for _, e := range b.config.BeforeFlush(batch) {
+		returned[e.ID] = true
+		if e.ID == "" || e.Type == "" || e.Timestamp.IsZero() || e.Body == nil {
+			invalid++
+			if b.config.MetricsEnabled {
+				b.client.metrics.RecordFailedEventWithReason(e, err, 0, DropReasonInvalid)
+			}
+			continue
+		}
+		result = append(result, e)
+	}
+
+	if invalid > 0 {
+		b.config.logger().Debugf("Discarding %d invalid events returned by BeforeFlush", invalid)
+		b.recordFailures(invalid, DropReasonInvalid)
+	}
+
+	// Events BeforeFlush did not return were filtered out
+	filtered := 0
+	for _, e := range events {
+		if !returned[e.ID] {
+			filtered++
+		}
+	}
+	b.recordFailures(filtered, DropReasonFiltered)
+	return result, invalid
+}
+
+// sendBatch sends one ingestion batch and reports what happened to its events
+func (b *Batcher) sendBatch(ctx context.Context, events []Event) (flushResult, error) {
+	var result flushResult
+
+	resp, err := b.client.sendEvents(ctx, events)
+	b.client.health.record(err == nil)
 
 	// Handle errors
 	if err != nil {
-		b.handleFlushError(events, err, resp)
-		return err
+		givenUp := b.handleFlushError(events, err, resp)
+		result.rejected = len(givenUp)
+		result.deadLettered = b.deadLetter(givenUp)
+		if b.config.DisableOnAuthError && IsAuthError(err) {
+			b.client.disableForAuthError(err)
+		}
+		return result, err
 	}
 
 	// Record metrics
@@ -135,8 +393,21 @@ func (b *Batcher) Flush(ctx context.Context) error {
 		errorCount = len(resp.Errors)
 	}
 
+	result.delivered = len(events) - errorCount
+	result.rejected = errorCount
+	b.recordFailures(errorCount, DropReasonRejected)
+	if errorCount > 0 {
+		result.deadLettered = b.deadLetter(rejectedEvents(events, resp.Errors))
+		if b.config.OnIngestionError != nil {
+			go b.reportIngestionErrors(events, resp.Errors)
+		}
+	}
+
+	b.resetRetries(events)
+
 	if b.config.MetricsEnabled {
 		b.client.metrics.RecordFlush(successCount, errorCount)
+		b.client.metrics.RecordResults(events, resp)
 	}
 
 	// Call flush callback if provided
@@ -146,53 +417,508 @@ func (b *Batcher) Flush(ctx context.Context) error {
 
 	// Log any errors from the API
 	if resp != nil && len(resp.Errors) > 0 {
-		if b.config.Debug {
-			log.Printf("[Langfuse] API returned %d errors out of %d events", len(resp.Errors), len(events))
+		b.config.logger().Debugf("API returned %d errors out of %d events", len(resp.Errors), len(events))
+	}
+
+	return result, nil
+}
+
+// rejectedEvents returns the events of a batch the API returned errors for
+func rejectedEvents(events []Event, errs []ErrorResult) []Event {
+	rejected := make(map[string]bool, len(errs))
+	for _, result := range errs {
+		rejected[result.ID] = true
+	}
+	var result []Event
+	for _, e := range events {
+		if rejected[e.ID] {
+			result = append(result, e)
 		}
 	}
+	return result
+}
 
-	return nil
+// reportIngestionErrors calls Config.OnIngestionError for each event the API
+// rejected in a batch. An error for an unknown event ID is reported with an
+// event that only has that ID.
+func (b *Batcher) reportIngestionErrors(events []Event, errs []ErrorResult) {
+	byID := make(map[string]Event, len(events))
+	for _, e := range events {
+		byID[e.ID] = e
+	}
+	for _, result := range errs {
+		event, ok := byID[result.ID]
+		if !ok {
+			event = Event{ID: result.ID}
+		}
+		b.config.OnIngestionError(event, result)
+	}
+}
+
+// batchEnvelopeBytes is the size of the ingestion request around its events: {"batch":[]}
+const batchEnvelopeBytes = len(`{"batch":[]}`)
+
+// splitBatches splits events into batches whose serialized size stays within
+// Config.MaxBatchBytes, keeping their order. Events that exceed the limit on
+// their own, and events that cannot be encoded, are returned separately so
+// they don't fail the batch they would be sent in.
+func (b *Batcher) splitBatches(events []Event) (batches [][]Event, oversized, unserializable []Event) {
+	limit := b.maxBatchBytes()
+
+	var current []Event
+	size := batchEnvelopeBytes
+	for _, e := range events {
+		eventSize, err := encodedSize(e)
+		if err != nil {
+			unserializable = append(unserializable, e)
+			continue
+		}
+		if batchEnvelopeBytes+eventSize > limit {
+			oversized = append(oversized, e)
+			continue
+		}
+
+		// Account for the comma separating events
+		if len(current) > 0 && size+1+eventSize > limit {
+			batches = append(batches, current)
+			current = nil
+			size = batchEnvelopeBytes
+		}
+		if len(current) > 0 {
+			size++
+		}
+		current = append(current, e)
+		size += eventSize
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches, oversized, unserializable
+}
+
+// maxBatchBytes returns Config.MaxBatchBytes, or the default if it is not set
+func (b *Batcher) maxBatchBytes() int {
+	if b.config.MaxBatchBytes <= 0 {
+		return DefaultMaxBatchBytes
+	}
+	return b.config.MaxBatchBytes
+}
+
+// encodedSize returns the serialized size of an event in bytes, or an error if
+// it cannot be serialized
+func encodedSize(e Event) (int, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
 }
 
-// handleFlushError processes errors during flush
-func (b *Batcher) handleFlushError(events []Event, err error, resp *IngestionResponse) {
-	// Check if this is a retryable error
-	if langfuseErr, ok := err.(*LangfuseError); ok && langfuseErr.IsRetryable() {
-		if b.config.Debug {
-			log.Printf("[Langfuse] Retryable error encountered: %v", err)
+// recordOversized records events that exceed MaxBatchBytes on their own as failed
+func (b *Batcher) recordOversized(events []Event) {
+	err := &LangfuseError{
+		Code:    "EVENT_TOO_LARGE",
+		Message: fmt.Sprintf("event exceeds the ingestion batch limit of %d bytes", b.maxBatchBytes()),
+	}
+
+	logger := b.config.logger()
+	for _, e := range events {
+		data, _ := json.Marshal(e)
+		logger.Warnf("Dropping %s event %s of %d bytes, larger than the batch limit of %d bytes", e.Type, e.ID, len(data), b.maxBatchBytes())
+		if b.config.MetricsEnabled {
+			b.client.metrics.RecordFailedEventWithReason(e, err, 0, DropReasonOversized)
 		}
+	}
+	b.recordFailures(len(events), DropReasonOversized)
+}
 
-		// Record retry attempt
+// recordUnserializable records events that cannot be encoded as JSON as failed
+func (b *Batcher) recordUnserializable(events []Event) {
+	logger := b.config.logger()
+	for _, e := range events {
+		_, encodeErr := json.Marshal(e)
+		logger.Warnf("Dropping %s event %s that cannot be encoded as JSON: %v", e.Type, e.ID, encodeErr)
 		if b.config.MetricsEnabled {
-			b.client.metrics.RecordRetry()
+			err := &LangfuseError{Code: "INVALID_EVENT", Message: fmt.Sprintf("event cannot be encoded as JSON: %v", encodeErr)}
+			b.client.metrics.RecordFailedEventWithReason(e, err, 0, DropReasonUnserializable)
 		}
+	}
+	b.recordFailures(len(events), DropReasonUnserializable)
+}
+
+// handleFlushError processes errors during flush.
+// It returns the events that were given up on; the rest were requeued for a retry.
+func (b *Batcher) handleFlushError(events []Event, err error, resp *IngestionResponse) []Event {
+	// Ingestion is idempotent by event ID, so retryable errors are retried
+	if shouldRetry(err, true) {
+		return b.scheduleRetry(events, err)
+	}
 
-		// Put events back at the front of the queue for retry
+	// Non-retryable error - record and discard
+	b.config.logger().Debugf("Non-retryable error, dropping %d events: %v", len(events), err)
+
+	// Record failed events for monitoring
+	b.mu.Lock()
+	for _, e := range events {
+		if b.config.MetricsEnabled {
+			b.client.metrics.RecordFailedEventWithReason(e, err, b.attempts[e.ID], DropReasonRejected)
+		}
+		delete(b.attempts, e.ID)
+	}
+	b.mu.Unlock()
+
+	b.recordFailures(len(events), DropReasonRejected)
+	return events
+}
+
+// scheduleRetry requeues events after a retryable error and delays background
+// flushes by the exponential backoff of the batch's attempt, with jitter.
+// Events that already failed MaxRetryAttempts retries are discarded and
+// recorded as failed, and returned.
+func (b *Batcher) scheduleRetry(events []Event, err error) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.attempts == nil {
+		b.attempts = make(map[string]int)
+	}
+
+	var retry, givenUp []Event
+	attempt := 0
+	for _, e := range events {
+		b.attempts[e.ID]++
+		if b.attempts[e.ID] > b.config.MaxRetryAttempts {
+			if b.config.MetricsEnabled {
+				b.client.metrics.RecordFailedEventWithReason(e, err, b.attempts[e.ID], DropReasonRetryExhausted)
+			}
+			delete(b.attempts, e.ID)
+			givenUp = append(givenUp, e)
+			continue
+		}
+		retry = append(retry, e)
+		attempt = max(attempt, b.attempts[e.ID])
+	}
+	b.recordFailures(len(givenUp), DropReasonRetryExhausted)
+
+	if len(givenUp) > 0 {
+		b.config.logger().Debugf("Giving up on %d events after %d retries: %v", len(givenUp), b.config.MaxRetryAttempts, err)
+	}
+	if len(retry) == 0 {
+		return givenUp
+	}
+
+	delay := max(withJitter(retryDelay(b.config, attempt)), retryAfter(err))
+	b.retryAt = b.config.clock().Now().Add(delay)
+	b.config.logger().Debugf("Retryable error encountered, retrying %d events in %s: %v", len(retry), delay, err)
+
+	// Record retry attempt
+	if b.config.MetricsEnabled {
+		b.client.metrics.RecordRetry()
+	}
+
+	// Put events back at the front of the queue for retry
+	b.queue = append(retry, b.queue...)
+	b.dirty = true
+	return givenUp
+}
+
+// resetRetries forgets the retry attempts of delivered events and ends the backoff
+func (b *Batcher) resetRetries(events []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range events {
+		delete(b.attempts, e.ID)
+	}
+	b.retryAt = time.Time{}
+}
+
+// restore puts events from the persistent queue into the queue, keeping at
+// most MaxQueueSize, spills the rest to the overflow file if there is one,
+// and records what did not fit there either as dropped
+func (b *Batcher) restore(events []Event) {
+	var excess []Event
+	if len(events) > b.config.MaxQueueSize {
+		excess = events[b.config.MaxQueueSize:]
+		events = events[:b.config.MaxQueueSize]
+	}
+
+	b.mu.Lock()
+	b.queue = append(b.queue, events...)
+	b.dirty = true
+	dropped := 0
+	for _, e := range excess {
+		if b.overflow == nil || !b.spillLocked(e) {
+			dropped++
+		}
+	}
+	b.mu.Unlock()
+
+	b.persistQueue()
+	b.recordDropped(dropped, DropReasonQueueFull)
+}
+
+// persistQueue writes the current queue to the persistent queue, if there is
+// one and the queue changed since it was last written
+func (b *Batcher) persistQueue() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.persist == nil || !b.dirty {
+		return
+	}
+	if err := b.persist.rewrite(b.queue); err != nil {
+		b.config.logger().Warnf("%v", err)
+		return
+	}
+	b.dirty = false
+}
+
+// spool leaves the queued events in the persistent queue for the next client,
+// closes it and returns how many events were left
+func (b *Batcher) spool() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	spooled := len(b.queue)
+	if err := b.persist.rewrite(b.queue); err != nil {
+		b.config.logger().Warnf("%v", err)
+	}
+	b.persist.close()
+	if b.overflow != nil {
+		spooled += b.overflow.count
+		if err := b.overflow.file.sync(); err != nil {
+			b.config.logger().Warnf("failed to sync overflow file: %v", err)
+		}
+		b.overflow.file.close()
+	}
+	b.queue = nil
+	b.attempts = nil
+	b.freeSpaceLocked()
+	return spooled
+}
+
+// release stops using the queue: it spools queued events if there is a
+// persistent queue, and otherwise discards them, recording them as dropped
+// and writing them to the dead-letter file if there is one. It returns the
+// number of spooled, dropped and dead-lettered events.
+func (b *Batcher) release() (spooled, dropped, deadLettered int) {
+	if b.persist != nil {
+		spooled = b.spool()
+	} else {
 		b.mu.Lock()
-		b.queue = append(events, b.queue...)
+		queued := b.queue
+		b.mu.Unlock()
+
+		dropped = b.dropQueued(DropReasonShutdown)
+		deadLettered = b.deadLetter(queued)
+	}
+	if b.deadLetters != nil {
+		b.deadLetters.close()
+	}
+	return spooled, dropped, deadLettered
+}
+
+// closeFiles closes the queue, overflow and dead-letter files of a batcher
+// that was not started
+func (b *Batcher) closeFiles() {
+	if b.persist != nil {
+		b.persist.close()
+	}
+	if b.overflow != nil {
+		b.overflow.file.close()
+	}
+	if b.deadLetters != nil {
+		b.deadLetters.close()
+	}
+}
+
+// Len returns the number of queued events
+func (b *Batcher) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue)
+}
+
+// Stop stops the background flush loop and discards queued events,
+// recording them as dropped. With a persistent queue they are kept for the
+// next client instead.
+func (b *Batcher) Stop() {
+	close(b.done)
+	b.wg.Wait()
+
+	b.release()
+}
+
+// Purge discards all queued events and retry state, recording the events as
+// dropped, and returns how many were discarded
+func (b *Batcher) Purge() int {
+	b.mu.Lock()
+	b.attempts = nil
+	b.mu.Unlock()
+
+	dropped := b.dropQueued(DropReasonPurged)
+	b.persistQueue()
+	return dropped + b.dropOverflow(DropReasonPurged)
+}
+
+// dropOverflow discards the spilled events, recording them as dropped for
+// reason, and returns how many were discarded
+func (b *Batcher) dropOverflow(reason DropReason) int {
+	b.mu.Lock()
+	if b.overflow == nil {
 		b.mu.Unlock()
+		return 0
+	}
+	dropped, err := b.overflow.clear()
+	b.mu.Unlock()
+
+	if err != nil {
+		b.config.logger().Warnf("%v", err)
+	}
+	b.recordDropped(dropped, reason)
+	return dropped
+}
+
+// dropQueued discards queued events, recording them as dropped for reason,
+// and returns how many were discarded
+func (b *Batcher) dropQueued(reason DropReason) int {
+	b.mu.Lock()
+	dropped := len(b.queue)
+	b.queue = nil
+	b.dirty = true
+	b.attempts = nil
+	b.retryAt = time.Time{}
+	b.freeSpaceLocked()
+	b.mu.Unlock()
+
+	b.recordDropped(dropped, reason)
+	return dropped
+}
+
+// dropStaleLocked removes events older than Config.MaxEventAge from the queue
+// and returns how many were removed. The caller must hold b.mu.
+func (b *Batcher) dropStaleLocked() int {
+	if b.config.MaxEventAge <= 0 {
+		return 0
+	}
+
+	cutoff := b.config.clock().Now().Add(-b.config.MaxEventAge)
+	kept := b.queue[:0]
+	for _, e := range b.queue {
+		if e.Timestamp.Before(cutoff) {
+			delete(b.attempts, e.ID)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	stale := len(b.queue) - len(kept)
+	b.queue = kept
+
+	if stale > 0 {
+		b.dirty = true
+		b.config.logger().Debugf("Dropping %d events older than %s", stale, b.config.MaxEventAge)
+		b.freeSpaceLocked()
+	}
+	return stale
+}
+
+// recordDropped records events dropped from the queue in the metrics and
+// calls OnEventDropped and OnEventDroppedWithReason
+func (b *Batcher) recordDropped(dropped int, reason DropReason) {
+	if dropped == 0 {
 		return
 	}
+	if b.config.MetricsEnabled {
+		b.client.metrics.RecordDropped(dropped)
+	}
+	if b.config.OnEventDropped != nil {
+		go b.config.OnEventDropped(dropped)
+	}
+	b.recordFailures(dropped, reason)
+}
 
-	// Non-retryable error - record and discard
-	if b.config.Debug {
-		log.Printf("[Langfuse] Non-retryable error, dropping %d events: %v", len(events), err)
+// recordFailures records why events were not delivered in the metrics and
+// calls OnEventDroppedWithReason
+func (b *Batcher) recordFailures(count int, reason DropReason) {
+	if count == 0 {
+		return
+	}
+	if b.config.MetricsEnabled {
+		b.client.metrics.RecordDropReason(reason, count)
+	}
+	if b.config.OnEventDroppedWithReason != nil {
+		go b.config.OnEventDroppedWithReason(count, reason)
 	}
+}
 
-	// Record failed events for monitoring
+// discardLocked empties the queue, recording each event as failed for reason.
+// The caller must hold b.mu.
+func (b *Batcher) discardLocked(err error, reason DropReason) {
 	if b.config.MetricsEnabled {
-		for _, e := range events {
-			b.client.metrics.RecordFailedEvent(e, err, 0)
+		for _, e := range b.queue {
+			b.client.metrics.RecordFailedEventWithReason(e, err, b.attempts[e.ID], reason)
 		}
 	}
+	b.recordFailures(len(b.queue), reason)
+	b.queue = b.queue[:0]
+	b.dirty = true
+	b.attempts = nil
+	b.freeSpaceLocked()
 }
 
 // Close stops the batcher and flushes remaining events
 func (b *Batcher) Close(ctx context.Context) error {
+	_, err := b.Shutdown(ctx)
+	return err
+}
+
+// Shutdown stops the batcher, makes one attempt to flush the remaining events
+// within ctx, and discards whatever is still queued afterwards (events that
+// hit a retryable error), recording it as dropped. With a persistent queue
+// those events are kept for the next client instead.
+func (b *Batcher) Shutdown(ctx context.Context) (CloseReport, error) {
 	close(b.done)
 	b.wg.Wait()
 
-	return b.Flush(ctx)
+	result, err := b.flush(ctx)
+	spooled, dropped, deadLettered := b.release()
+
+	deadLettered += result.deadLettered
+	report := CloseReport{
+		Delivered:    result.delivered,
+		Spooled:      spooled,
+		DeadLettered: deadLettered,
+		Abandoned:    result.rejected + dropped - deadLettered,
+	}
+	return report, err
+}
+
+// QueueFullBehavior selects what happens to a new event when the queue is full
+type QueueFullBehavior string
+
+const (
+	// QueueFullDropNewest drops the new event and returns a *QueueFullError
+	QueueFullDropNewest QueueFullBehavior = "drop_newest"
+
+	// QueueFullDropOldest drops the oldest queued event to admit the new one
+	QueueFullDropOldest QueueFullBehavior = "drop_oldest"
+
+	// QueueFullBlock waits up to Config.QueueFullTimeout for a flush to make
+	// room, then drops the new event and returns a *QueueFullError. Only the
+	// caller adding the event waits; other callers are not held up meanwhile.
+	QueueFullBlock QueueFullBehavior = "block"
+)
+
+// DefaultQueueFullTimeout is the default QueueFullTimeout
+const DefaultQueueFullTimeout = 5 * time.Second
+
+// queueFullTimeout returns QueueFullTimeout, or DefaultQueueFullTimeout if it is not set
+func (c *Config) queueFullTimeout() time.Duration {
+	if c.QueueFullTimeout > 0 {
+		return c.QueueFullTimeout
+	}
+	return DefaultQueueFullTimeout
 }
 
 // QueueFullError is returned when the event queue is full
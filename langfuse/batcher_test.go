@@ -0,0 +1,258 @@
+package langfuse
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOversizedEventIsLoggedAsWarning(t *testing.T) {
+	server := newTestServer(t)
+	logger := &testLogger{}
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = logger
+		c.MaxBatchBytes = 1000
+	})
+
+	if _, err := client.CreateTrace(TraceParams{Input: strings.Repeat("x", 2000)}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	if n := len(server.eventsOfType(EventTypeTraceCreate)); n != 0 {
+		t.Errorf("the oversized event was sent %d times", n)
+	}
+	warning := regexp.MustCompile(`trace-create event (\S+) of (\d+) bytes, larger than the batch limit of 1000 bytes`)
+	for _, w := range logger.Warnings() {
+		if m := warning.FindStringSubmatch(w); m != nil {
+			if size, _ := strconv.Atoi(m[2]); m[1] == "" || size <= 2000 {
+				t.Errorf("warning %q does not name the event and its size", w)
+			}
+			return
+		}
+	}
+	t.Errorf("no warning about the oversized event, got %q", logger.Warnings())
+}
+
+func TestBeforeFlushPassthrough(t *testing.T) {
+	server := newTestServer(t)
+	var seen int
+	client := newTestClient(t, server, func(c *Config) {
+		c.BeforeFlush = func(events []Event) []Event {
+			seen += len(events)
+			return events
+		}
+	})
+
+	ids := createTraces(t, client, 3)
+	flush(t, client)
+
+	if seen != len(ids) {
+		t.Errorf("BeforeFlush saw %d events, want %d", seen, len(ids))
+	}
+	if counts := traceCreateCounts(server); len(counts) != len(ids) {
+		t.Errorf("sent %d traces, want %d", len(counts), len(ids))
+	}
+}
+
+func TestBeforeFlushAppend(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.BeforeFlush = func(events []Event) []Event {
+			heartbeat := Event{
+				ID:        "heartbeat-1",
+				Type:      EventTypeSdkLog,
+				Timestamp: time.Now(),
+				Body:      map[string]interface{}{"log": "heartbeat"},
+			}
+			invalid := Event{ID: "invalid-1", Type: EventTypeSdkLog} // no timestamp or body
+			return append(events, heartbeat, invalid)
+		}
+	})
+
+	createTraces(t, client, 2)
+	flush(t, client)
+
+	if n := len(server.eventsOfType(EventTypeTraceCreate)); n != 2 {
+		t.Errorf("sent %d traces, want 2", n)
+	}
+	logs := server.eventsOfType(EventTypeSdkLog)
+	if len(logs) != 1 || logs[0].ID != "heartbeat-1" {
+		t.Errorf("sent sdk-log events %+v, want only the valid heartbeat", logs)
+	}
+}
+
+func TestBeforeFlushPanicSendsEventsUnchanged(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = &testLogger{}
+		c.BeforeFlush = func(events []Event) []Event {
+			panic("broken callback")
+		}
+	})
+
+	createTraces(t, client, 2)
+	flush(t, client)
+
+	if n := len(server.eventsOfType(EventTypeTraceCreate)); n != 2 {
+		t.Errorf("sent %d traces after BeforeFlush panicked, want 2", n)
+	}
+}
+
+func TestUnserializableEventDoesNotPoisonTheBatch(t *testing.T) {
+	server := newTestServer(t)
+	logger := &testLogger{}
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = logger
+		c.MetricsEnabled = true
+	})
+
+	good := createTraces(t, client, 1)
+	bad, err := client.CreateTrace(TraceParams{Input: map[string]interface{}{"ch": make(chan int)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	good = append(good, createTraces(t, client, 1)...)
+	flush(t, client)
+
+	counts := traceCreateCounts(server)
+	for _, id := range good {
+		if counts[id] != 1 {
+			t.Errorf("good trace %s was sent %d times, want 1", id, counts[id])
+		}
+	}
+	if counts[bad.ID()] != 0 {
+		t.Errorf("the unserializable trace was sent")
+	}
+	if n := client.GetMetrics().DroppedByReason[DropReasonUnserializable]; n != 1 {
+		t.Errorf("%d events recorded as unserializable, want 1", n)
+	}
+	if len(logger.Warnings()) == 0 {
+		t.Error("the unserializable event was dropped without a warning")
+	}
+}
+
+func TestDisableMetadataOnEvents(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		server := newTestServer(t)
+		client := newTestClient(t, server, func(c *Config) {
+			c.DisableMetadataOnEvents = disabled
+			c.BeforeFlush = func(events []Event) []Event {
+				for i := range events {
+					events[i].Metadata = map[string]interface{}{"region": "eu"}
+				}
+				return events
+			}
+		})
+
+		if _, err := client.CreateTrace(TraceParams{Metadata: map[string]interface{}{"step": "plan"}}); err != nil {
+			t.Fatal(err)
+		}
+		flush(t, client)
+
+		events := server.eventsOfType(EventTypeTraceCreate)
+		if len(events) != 1 {
+			t.Fatalf("sent %d traces, want 1", len(events))
+		}
+		if sent := events[0].Metadata != nil; sent == disabled {
+			t.Errorf("with DisableMetadataOnEvents %v, event metadata = %v", disabled, events[0].Metadata)
+		}
+		if metadata, _ := events[0].Body["metadata"].(map[string]interface{}); metadata["step"] != "plan" {
+			t.Errorf("with DisableMetadataOnEvents %v, trace metadata = %v, want it kept", disabled, events[0].Body["metadata"])
+		}
+	}
+}
+
+func TestQueueFullDropOldestEvictsTheHead(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.MaxQueueSize = 2
+		c.QueueFullBehavior = QueueFullDropOldest
+		c.MetricsEnabled = true
+	})
+
+	ids := createTraces(t, client, 3)
+	flush(t, client)
+
+	counts := traceCreateCounts(server)
+	if len(counts) != 2 || counts[ids[0]] != 0 || counts[ids[1]] != 1 || counts[ids[2]] != 1 {
+		t.Errorf("sent traces %v, want the last two of %v", counts, ids)
+	}
+	if n := client.GetMetrics().DroppedByReason[DropReasonQueueFull]; n != 1 {
+		t.Errorf("%d events recorded as dropped, want the evicted one", n)
+	}
+}
+
+func TestQueueFullBlockWaitsForAFlush(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.MaxQueueSize = 1
+		c.QueueFullBehavior = QueueFullBlock
+		c.QueueFullTimeout = 10 * time.Second
+	})
+
+	first := createTraces(t, client, 1)
+	created := make(chan string, 1)
+	go func() {
+		trace, err := client.CreateTrace(TraceParams{})
+		if err != nil {
+			t.Error(err)
+			created <- ""
+			return
+		}
+		created <- trace.ID()
+	}()
+
+	// Wait for CreateTrace to block on the full queue
+	for waiting := false; !waiting; {
+		time.Sleep(time.Millisecond)
+		client.batcher.mu.Lock()
+		waiting = client.batcher.space != nil
+		client.batcher.mu.Unlock()
+	}
+	select {
+	case <-created:
+		t.Fatal("CreateTrace returned while the queue was full")
+	default:
+	}
+
+	// A blocked enqueue must not hold the client lock, or nothing else could
+	// be created, updated or closed until the queue drains
+	if !client.mu.TryLock() {
+		t.Fatal("the client lock is held while waiting for queue space")
+	}
+	client.mu.Unlock()
+
+	flush(t, client)
+	var second string
+	select {
+	case second = <-created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateTrace still blocked after a flush made room")
+	}
+	flush(t, client)
+
+	counts := traceCreateCounts(server)
+	if counts[first[0]] != 1 || counts[second] != 1 {
+		t.Errorf("sent traces %v, want %s and %s", counts, first[0], second)
+	}
+}
+
+func TestQueueFullBlockDropsAfterTheTimeout(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.MaxQueueSize = 1
+		c.QueueFullBehavior = QueueFullBlock
+		c.QueueFullTimeout = 10 * time.Millisecond
+	})
+
+	createTraces(t, client, 1)
+	_, err := client.CreateTrace(TraceParams{})
+	var full *QueueFullError
+	if !errors.As(err, &full) {
+		t.Errorf("CreateTrace on a full queue = %v, want a QueueFullError after the timeout", err)
+	}
+}
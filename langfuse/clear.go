@@ -0,0 +1,31 @@
+package langfuse
+
+// Empty values
+//
+// Event bodies omit a field whose value is nil or empty (an empty string, map
+// or slice), for traces, observations and scores alike. On updates an omitted
+// field keeps its current value on the server, so Ptr("") or an empty
+// metadata map never erases data by accident. Input and Output are free-form
+// and are only omitted when nil.
+//
+// To erase a value, ask for it explicitly: set Input or Output to ClearField,
+// use ClearField as a metadata value, or list the field in the params' Clear
+// field. Cleared fields are sent as JSON null.
+
+// ClearField is a marker value that is sent as JSON null. Set Input or Output
+// to it to clear them, or use it as a metadata value to clear that key.
+var ClearField interface{} = clearField{}
+
+type clearField struct{}
+
+// MarshalJSON encodes the marker as null
+func (clearField) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// applyClear sets each of fields to null in body
+func applyClear(body map[string]interface{}, fields []string) {
+	for _, field := range fields {
+		body[field] = nil
+	}
+}
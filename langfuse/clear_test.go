@@ -0,0 +1,103 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEmptyValuesAreOmitted(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name  string
+		field string
+		body  map[string]interface{}
+	}{
+		{"trace nil name", "name", traceBody(TraceParams{})},
+		{"trace empty name", "name", traceBody(TraceParams{Name: &empty})},
+		{"trace nil input", "input", traceBody(TraceParams{})},
+		{"trace nil output", "output", traceBody(TraceParams{})},
+		{"trace nil metadata", "metadata", traceBody(TraceParams{})},
+		{"trace empty metadata", "metadata", traceBody(TraceParams{Metadata: map[string]interface{}{}})},
+		{"trace empty userId", "userId", traceBody(TraceParams{UserID: &empty})},
+		{"trace empty sessionId", "sessionId", traceBody(TraceParams{SessionID: &empty})},
+		{"trace empty environment", "environment", traceBody(TraceParams{Environment: &empty})},
+		{"trace empty version", "version", traceBody(TraceParams{Version: &empty})},
+		{"trace empty release", "release", traceBody(TraceParams{Release: &empty})},
+		{"trace nil tags", "tags", traceBody(TraceParams{})},
+		{"trace empty tags", "tags", traceBody(TraceParams{Tags: []string{}})},
+		{"trace nil public", "public", traceBody(TraceParams{})},
+		{"observation empty parentObservationId", "parentObservationId", observationToBody(ObservationParams{ParentObservationID: &empty}, "o1")},
+		{"observation empty name", "name", observationToBody(ObservationParams{Name: &empty}, "o1")},
+		{"observation empty metadata", "metadata", observationToBody(ObservationParams{Metadata: map[string]interface{}{}}, "o1")},
+		{"observation nil input", "input", observationToBody(ObservationParams{}, "o1")},
+		{"observation nil output", "output", observationToBody(ObservationParams{}, "o1")},
+		{"observation empty level", "level", observationToBody(ObservationParams{Level: ptr(ObservationLevel(""))}, "o1")},
+		{"observation empty statusMessage", "statusMessage", observationToBody(ObservationParams{StatusMessage: &empty}, "o1")},
+		{"observation empty version", "version", observationToBody(ObservationParams{Version: &empty}, "o1")},
+		{"observation empty environment", "environment", observationToBody(ObservationParams{Environment: &empty}, "o1")},
+		{"score empty traceId", "traceId", scoreToBody(ScoreParams{Name: "s", TraceID: &empty}, "s1")},
+		{"score empty observationId", "observationId", scoreToBody(ScoreParams{Name: "s", ObservationID: &empty}, "s1")},
+		{"score empty comment", "comment", scoreToBody(ScoreParams{Name: "s", Comment: &empty}, "s1")},
+		{"score empty configId", "configId", scoreToBody(ScoreParams{Name: "s", ConfigID: &empty}, "s1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, ok := tt.body[tt.field]; ok {
+				t.Errorf("body has %s = %#v, want it omitted", tt.field, v)
+			}
+		})
+	}
+}
+
+func TestClearedValuesAreSentAsNull(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		body  map[string]interface{}
+	}{
+		{"trace ClearField output", "output", traceBody(TraceParams{Output: ClearField})},
+		{"observation ClearField input", "input", observationToBody(ObservationParams{Input: ClearField}, "o1")},
+		{"observation Clear statusMessage", "statusMessage", observationToBody(ObservationParams{StatusMessage: Ptr("x"), Clear: []string{"statusMessage"}}, "o1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := tt.body[tt.field]
+			if !ok {
+				t.Fatalf("body has no %s, want null", tt.field)
+			}
+			if data, _ := json.Marshal(v); string(data) != "null" {
+				t.Errorf("%s is sent as %s, want null", tt.field, data)
+			}
+		})
+	}
+}
+
+// traceBody returns the body of a trace with params
+func traceBody(params TraceParams) map[string]interface{} {
+	trace := &Trace{traceState: &traceState{id: "t1", params: params}}
+	return trace.toBody()
+}
+
+func TestUpdateSendsClearedTraceFieldsAsNull(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{UserID: Ptr("u1"), Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Update(TraceParams{Clear: []string{"userId", "tags"}}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) != 1 {
+		t.Fatalf("got %d trace updates, want 1", len(updates))
+	}
+	for _, field := range []string{"userId", "tags"} {
+		if v, ok := updates[0][field]; !ok || v != nil {
+			t.Errorf("update %s = %v (sent: %v), want null", field, v, ok)
+		}
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,12 +19,43 @@ import (
 
 // Client is the main Langfuse client
 type Client struct {
+	*clientCore
+}
+
+// clientCore holds the client state shared with the background batcher.
+// It is kept separate from Client so the batcher goroutine does not keep an
+// unclosed Client reachable, which lets the finalizer set by NewClient run.
+type clientCore struct {
 	config     *Config
 	httpClient *http.Client
 	batcher    *Batcher
 	metrics    *Metrics
 	mu         sync.Mutex
 	closed     bool
+
+	// authDisabled is set when DisableOnAuthError disabled the client
+	authDisabled atomic.Bool
+
+	// sequences numbers observations per trace, guarded by mu
+	sequences *sequenceCounter
+
+	// generations tracks open generations for WarnIncompleteGenerations
+	generations *generationTracker
+
+	// otlp remembers the spans sent with TransportOTLP
+	otlp *otlpState
+
+	// traces caches recently created traces for CachedTrace
+	traces *traceCache
+
+	// sampler drops the events of traces not sampled with SampleRate
+	sampler *sampler
+
+	// health tracks recent ingestion requests for IsHealthy
+	health *healthTracker
+
+	// deprecations holds the deprecated call sites already warned about
+	deprecations sync.Map
 }
 
 // NewClient creates a new Langfuse client with the given configuration
@@ -35,36 +68,161 @@ func NewClient(config *Config) (*Client, error) {
 		return nil, err
 	}
 
-	client := &Client{
+	return newClient(config, osFS{})
+}
+
+// newClient creates a client with a valid config, keeping its queue files in fs
+func newClient(config *Config, fs queueFS) (*Client, error) {
+	client := &Client{&clientCore{
 		config: config,
 		httpClient: &http.Client{
-			Timeout: config.Timeout,
+			Timeout:   config.Timeout,
+			Transport: newTransport(config),
 		},
-		metrics: &Metrics{},
-	}
+		metrics:     &Metrics{},
+		sequences:   newSequenceCounter(),
+		generations: newGenerationTracker(config.logger()),
+		otlp:        newOTLPState(),
+		traces:      newTraceCache(config.TraceCacheMaxSize),
+		sampler:     newSampler(config.SampleRate),
+		health:      newHealthTracker(config.HealthWindow),
+	}}
 
 	// Initialize batcher for async event sending
 	if config.Enabled {
 		client.batcher = NewBatcher(client, config)
+
+		// Close the queue files already opened if a later one fails to open
+		started := false
+		defer func() {
+			if !started {
+				client.batcher.closeFiles()
+			}
+		}()
+
+		if config.PersistentQueuePath != "" {
+			persist, events, err := openPersistentQueue(fs, config.PersistentQueuePath, config.logger())
+			if err != nil {
+				return nil, err
+			}
+			client.batcher.persist = persist
+			if config.OverflowToDisk {
+				overflow, err := openOverflowQueue(fs, config.PersistentQueuePath+overflowQueueSuffix, config.maxOverflowBytes(), config.logger())
+				if err != nil {
+					return nil, err
+				}
+				client.batcher.overflow = overflow
+			}
+			client.batcher.restore(events)
+		}
+		if config.DeadLetterPath != "" {
+			deadLetters, err := openDeadLetterStore(fs, config.DeadLetterPath)
+			if err != nil {
+				return nil, err
+			}
+			client.batcher.deadLetters = deadLetters
+		}
 		client.batcher.Start()
+		started = true
+		runtime.SetFinalizer(client, (*Client).finalize)
 	}
 
 	return client, nil
 }
 
+// newTransport returns the HTTP transport for the client, with
+// Config.NetworkTimeout applied to dialing and Config.TLSConfig to TLS
+func newTransport(config *Config) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.NetworkTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   config.NetworkTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	return transport
+}
+
+// finalize runs when an unclosed Client is garbage collected. It warns if
+// events are still queued, flushes them if Config.FlushOnFinalize is set,
+// and stops the background batcher either way.
+// Go does not run finalizers at process exit, so this is only a safety net:
+// always Close (or MustClose) the client, typically with defer.
+func (c *Client) finalize() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	if queued := c.batcher.Len(); queued > 0 {
+		c.config.logger().Warnf("client was garbage collected without Close; %d queued events", queued)
+		if !c.config.FlushOnFinalize {
+			c.config.logger().Warnf("discarding %d events; call Close or set FlushOnFinalize", queued)
+			c.batcher.Stop()
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.batcher.Close(ctx); err != nil {
+		c.config.logger().Warnf("flush on finalize failed: %v", err)
+	}
+}
+
+// MustClose closes the client and panics if the final flush fails.
+// It is meant to be deferred right after NewClient:
+//
+//	client, err := langfuse.NewClient(config)
+//	if err != nil { ... }
+//	defer langfuse.MustClose(client)
+func MustClose(client *Client) {
+	if err := client.Close(); err != nil {
+		panic(fmt.Sprintf("langfuse: close failed: %v", err))
+	}
+}
+
+// NewClientFromEnv creates a new Langfuse client configured from LANGFUSE_*
+// environment variables (see ConfigFromEnv)
+func NewClientFromEnv() (*Client, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(config)
+}
+
 // makeAuthHeader creates the Basic Auth header
-func (c *Client) makeAuthHeader() string {
+func (c *clientCore) makeAuthHeader() string {
 	auth := c.config.PublicKey + ":" + c.config.SecretKey
 	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
+// sendEvents sends a batch of events with the configured transport
+func (c *clientCore) sendEvents(ctx context.Context, events []Event) (*IngestionResponse, error) {
+	if c.config.DryRun {
+		return c.sendDryRun(events)
+	}
+	if c.config.Transport == TransportOTLP {
+		return c.sendOTLP(ctx, events)
+	}
+	return c.sendIngestion(ctx, &IngestionRequest{Batch: events})
+}
+
 // sendIngestion sends an ingestion request to the Langfuse API
-func (c *Client) sendIngestion(ctx context.Context, req *IngestionRequest) (*IngestionResponse, error) {
+func (c *clientCore) sendIngestion(ctx context.Context, req *IngestionRequest) (*IngestionResponse, error) {
 	if !c.config.Enabled {
 		return &IngestionResponse{}, nil
 	}
 
-	url := c.config.BaseURL + "/api/public/ingestion"
+	url := c.config.ingestionBaseURL() + "/api/public/ingestion"
 
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -84,9 +242,7 @@ func (c *Client) sendIngestion(ctx context.Context, req *IngestionRequest) (*Ing
 		httpReq.Header.Set("X-Langfuse-Sdk-Integration", c.config.SDKIntegration)
 	}
 
-	if c.config.Debug {
-		log.Printf("[Langfuse] Sending %d events to %s", len(req.Batch), url)
-	}
+	c.config.logger().Debugf("Sending %d events to %s", len(req.Batch), url)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -101,7 +257,7 @@ func (c *Client) sendIngestion(ctx context.Context, req *IngestionRequest) (*Ing
 
 	// API returns 207 Multi-Status for batch requests
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
-		return nil, NewHTTPError(resp.StatusCode, string(respBody))
+		return nil, newResponseError(resp, respBody)
 	}
 
 	var ingestionResp IngestionResponse
@@ -111,32 +267,91 @@ func (c *Client) sendIngestion(ctx context.Context, req *IngestionRequest) (*Ing
 		}
 	}
 
-	if c.config.Debug {
-		log.Printf("[Langfuse] Response: %d successes, %d errors", len(ingestionResp.Successes), len(ingestionResp.Errors))
-		if len(ingestionResp.Errors) > 0 {
-			for _, e := range ingestionResp.Errors {
-				log.Printf("[Langfuse] Error: %s - %s", e.Error, e.Message)
-			}
-		}
+	logger := c.config.logger()
+	logger.Debugf("Response: %d successes, %d errors", len(ingestionResp.Successes), len(ingestionResp.Errors))
+	for _, e := range ingestionResp.Errors {
+		logger.Debugf("Error: %s - %s", e.Error, e.Message)
 	}
 
 	return &ingestionResp, nil
 }
 
+// disableForAuthError disables the client after an authentication failure
+func (c *clientCore) disableForAuthError(err error) {
+	if c.authDisabled.Swap(true) {
+		return
+	}
+	c.config.logger().Errorf("ingestion was rejected with an authentication error, disabling the client until it is recreated: %v", err)
+}
+
 // enqueue adds an event to the batch queue
 func (c *Client) enqueue(event Event) error {
+	if c.config.QueueFullBehavior == QueueFullBlock {
+		return c.addUnlocked(nil, event)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	event, ok, err := c.prepareLocked(event)
+	if !ok {
+		return err
+	}
+	return c.batcher.Add(event)
+}
+
+// enqueueWaiting returns an enqueue function that, if the queue is full,
+// waits for space until ctx is done instead of dropping the event. c.mu is
+// not held while waiting, so a flush can make room.
+func (c *Client) enqueueWaiting(ctx context.Context) func(Event) error {
+	return func(event Event) error {
+		return c.addUnlocked(ctx, event)
+	}
+}
+
+// addUnlocked prepares an event under c.mu but queues it without holding
+// c.mu, since queuing may wait for space. With a nil ctx it waits as set by
+// Config.QueueFullBehavior.
+func (c *Client) addUnlocked(ctx context.Context, event Event) error {
+	c.mu.Lock()
+	event, ok, err := c.prepareLocked(event)
+	c.mu.Unlock()
+	if !ok {
+		return err
+	}
+	return c.batcher.add(ctx, event)
+}
+
+// prepareLocked applies the client's settings to an event about to be
+// queued. It reports false if the event must not be queued, with the error
+// to return, if any. c.mu must be held.
+func (c *Client) prepareLocked(event Event) (Event, bool, error) {
 	if c.closed {
-		return fmt.Errorf("client is closed")
+		return event, false, fmt.Errorf("client is closed")
 	}
 
-	if !c.config.Enabled {
-		return nil
+	if !c.config.Enabled || c.authDisabled.Load() {
+		return event, false, nil
 	}
 
-	return c.batcher.Add(event)
+	if !c.sampler.keep(event) {
+		c.batcher.recordFailures(1, DropReasonSampled)
+		return event, false, nil
+	}
+
+	if c.config.MaskFunc != nil {
+		event.Body = maskBody(event.Body, c.config.MaskFunc)
+	}
+
+	if c.config.TagStructTypes {
+		event.Body = tagBodyTypes(event.Body)
+	}
+
+	if !c.config.DisableObservationSequence {
+		c.sequences.stampSequence(&event)
+	}
+
+	return event, true, nil
 }
 
 // Flush forces all queued events to be sent immediately
@@ -152,23 +367,66 @@ func (c *Client) Flush(ctx context.Context) error {
 	return c.batcher.Flush(ctx)
 }
 
-// Close stops the client and flushes all pending events
+// CloseReport describes what happened to the events pending when the client was closed
+type CloseReport struct {
+	// Delivered is the number of events accepted by the API during Close
+	Delivered int
+
+	// Spooled is the number of events left in the persistent queue
+	// (Config.PersistentQueuePath) and its overflow file for delivery by a
+	// later client
+	Spooled int
+
+	// DeadLettered is the number of events that were not delivered and were
+	// written to the dead-letter file (Config.DeadLetterPath) instead
+	DeadLettered int
+
+	// Abandoned is the number of events that were not delivered and were
+	// lost: rejected by the API, failed with a non-retryable error, or still
+	// queued when the flush failed or ctx expired, and neither spooled nor
+	// dead-lettered
+	Abandoned int
+}
+
+// Close stops the client and flushes all pending events, waiting up to 5 seconds
 func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := c.CloseWithContext(ctx)
+	return err
+}
+
+// CloseWithContext stops the client and flushes all pending events within ctx.
+// New events are rejected as soon as it is called. Events that could not be
+// delivered are written to Config.DeadLetterPath if it is set and counted as
+// DeadLettered, and otherwise discarded and counted as Abandoned in the
+// returned report.
+// Closing an already closed client returns an empty report.
+func (c *Client) CloseWithContext(ctx context.Context) (CloseReport, error) {
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
-		return nil
+		return CloseReport{}, nil
 	}
 	c.closed = true
 	c.mu.Unlock()
 
 	if c.batcher != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		return c.batcher.Close(ctx)
+		return c.batcher.Shutdown(ctx)
 	}
 
-	return nil
+	return CloseReport{}, nil
+}
+
+// PurgeQueue discards all queued events without sending them, including events
+// waiting to be retried, records them as dropped and returns how many were discarded.
+// Unlike Close, the client stays usable afterwards.
+func (c *Client) PurgeQueue() int {
+	if c.batcher == nil {
+		return 0
+	}
+	return c.batcher.Purge()
 }
 
 // GetMetrics returns a snapshot of current metrics
@@ -181,9 +439,43 @@ func (c *Client) GetFailedEvents() []FailedEvent {
 	return c.metrics.GetFailedEvents()
 }
 
-// generateID generates a new UUID for events
-func generateID() string {
-	return uuid.New().String()
+// ResetMetrics clears all metrics, including failed events, so that later
+// snapshots start from zero. Queued events are not affected.
+func (c *Client) ResetMetrics() {
+	c.metrics.Reset()
+}
+
+// generateID generates a new ID for events with Config.IDGenerator, or a
+// UUID of Config.IDVersion. UUIDv7s are monotonic within the process, even
+// within one millisecond.
+func (c *clientCore) generateID() string {
+	if c.config.IDGenerator != nil {
+		if id := c.config.IDGenerator(); id != "" {
+			return id
+		}
+		c.config.logger().Warnf("IDGenerator returned an empty ID, using a UUID instead")
+	}
+	if c.config.IDVersion == 4 {
+		return uuid.New().String()
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+// deterministicIDNamespace is the UUIDv5 namespace of DeterministicID
+var deterministicIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/voicefoxai/langfuse-gosdk/deterministic-id"))
+
+// DeterministicID returns a UUIDv5 derived from seed, such as
+// "order-12345-step-2", so re-running a job yields the same IDs. Passed as
+// TraceParams.ID or ObservationParams.ID, it makes the events of a re-run
+// update the trace or observation of the previous run, since the server
+// upserts traces and observations by ID, instead of creating duplicates.
+// Different seeds give different IDs, barring SHA-1 collisions.
+func DeterministicID(seed string) string {
+	return uuid.NewSHA1(deterministicIDNamespace, []byte(seed)).String()
 }
 
 // Ptr is a helper function to get a pointer to a value
@@ -0,0 +1,157 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDisableOnAuthErrorStopsSending(t *testing.T) {
+	server := newTestServer(t)
+	var requests atomic.Int32
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	client := newTestClient(t, server, func(c *Config) {
+		c.DisableOnAuthError = true
+	})
+
+	createTraces(t, client, 2)
+	if err := client.Flush(context.Background()); !IsAuthError(err) {
+		t.Fatalf("Flush = %v, want the 403", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("sent %d requests before the 403, want 1", n)
+	}
+
+	createTraces(t, client, 2)
+	if err := client.Flush(context.Background()); err != nil {
+		t.Errorf("Flush of a disabled client = %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d requests, want none after the 403", n-1)
+	}
+}
+
+func TestPurgeQueueDiscardsQueuedAndRetriedEvents(t *testing.T) {
+	server := newTestServer(t)
+	var failed atomic.Bool
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		if !failed.Swap(true) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ingest(w, r)
+	})
+	client := newTestClient(t, server, func(c *Config) {
+		c.MetricsEnabled = true
+	})
+
+	createTraces(t, client, 3)
+	client.Flush(context.Background()) // fails, so the events wait to be retried
+	createTraces(t, client, 2)
+
+	if n := client.PurgeQueue(); n != 5 {
+		t.Errorf("PurgeQueue = %d, want 5", n)
+	}
+	if n := client.batcher.Len(); n != 0 {
+		t.Errorf("%d events are still queued after PurgeQueue", n)
+	}
+	if n := client.GetMetrics().DroppedByReason[DropReasonPurged]; n != 5 {
+		t.Errorf("%d events recorded as purged, want 5", n)
+	}
+
+	flush(t, client)
+	if n := len(server.Events()); n != 0 {
+		t.Errorf("sent %d purged events", n)
+	}
+}
+
+func TestDeterministicID(t *testing.T) {
+	id := DeterministicID("order-12345-step-2")
+	if again := DeterministicID("order-12345-step-2"); again != id {
+		t.Errorf("DeterministicID is not stable: %s, then %s", id, again)
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		t.Fatalf("DeterministicID = %q, not a UUID: %v", id, err)
+	}
+	if parsed.Version() != 5 || parsed.String() != id {
+		t.Errorf("DeterministicID = %q, want a canonical UUIDv5", id)
+	}
+
+	seen := make(map[string]string)
+	for i := 0; i < 100000; i++ {
+		seed := fmt.Sprintf("order-%d", i)
+		id := DeterministicID(seed)
+		if other, ok := seen[id]; ok {
+			t.Fatalf("seeds %q and %q both give %s", other, seed, id)
+		}
+		seen[id] = seed
+	}
+}
+
+func TestDeterministicIDUpsertsOnRerun(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	id := DeterministicID("job-42")
+	for run := 0; run < 2; run++ {
+		trace, err := client.CreateTrace(TraceParams{ID: Ptr(id)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := trace.StartSpan(NewSpan("step", WithID(DeterministicID("job-42-step-1")))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flush(t, client)
+
+	if counts := traceCreateCounts(server); len(counts) != 1 || counts[id] != 2 {
+		t.Errorf("trace-create counts = %v, want both runs to send trace %s", counts, id)
+	}
+	for _, e := range server.eventsOfType(EventTypeSpanCreate) {
+		if e.Body["id"] != DeterministicID("job-42-step-1") {
+			t.Errorf("span id = %v, want the deterministic ID", e.Body["id"])
+		}
+	}
+}
+
+func TestGeneratedIDsAreTimeOrderedUUIDv7(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+
+	var prev string
+	for i := 0; i < 10000; i++ {
+		id := client.generateID()
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			t.Fatalf("generated ID %q is not a UUID: %v", id, err)
+		}
+		if parsed.Version() != 7 || parsed.Variant() != uuid.RFC4122 {
+			t.Fatalf("generated ID %s has version %d, variant %v, want an RFC 4122 UUIDv7", id, parsed.Version(), parsed.Variant())
+		}
+		if id <= prev {
+			t.Fatalf("generated ID %s does not sort after %s", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestIDVersion4(t *testing.T) {
+	client := newTestClient(t, newTestServer(t), func(c *Config) {
+		c.IDVersion = 4
+	})
+
+	parsed, err := uuid.Parse(client.generateID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Version() != 4 {
+		t.Errorf("generated ID has version %d with IDVersion 4, want 4", parsed.Version())
+	}
+}
@@ -0,0 +1,70 @@
+package langfuse
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the time source of the batcher's background flush loop.
+// Tests can set Config.Clock to a fake, such as the one in the langfusetest
+// package, to control when flushes happen.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// NewTicker returns a ticker that ticks every d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a Clock
+type Ticker interface {
+	// C returns the channel ticks are delivered on
+	C() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker adapts time.Ticker to Ticker
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// clock returns Config.Clock, or the real clock if it is not set
+func (c *Config) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return realClock{}
+}
+
+// Clock returns the clock driving the client's flush loop
+func (c *Client) Clock() Clock {
+	return c.config.clock()
+}
+
+// ForceFlushSync runs one cycle of the background flush loop on the calling
+// goroutine and returns when it has finished. Like the loop, it does not send
+// while backing off after a retryable error. Together with a fake Config.Clock
+// it lets tests decide exactly when queued events are sent.
+func (c *Client) ForceFlushSync() error {
+	if !c.config.Enabled || c.batcher == nil {
+		return nil
+	}
+	return c.batcher.tick(context.Background())
+}
@@ -0,0 +1,73 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Comment represents a comment retrieved from API
+type Comment struct {
+	ID           string  `json:"id"`
+	ProjectID    string  `json:"projectId"`
+	ObjectType   string  `json:"objectType"` // TRACE, OBSERVATION, SESSION, PROMPT
+	ObjectID     string  `json:"objectId"`
+	Content      string  `json:"content"`
+	AuthorUserID *string `json:"authorUserId,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// PaginatedComments represents paginated comment list response
+type PaginatedComments struct {
+	Data []Comment      `json:"data"`
+	Meta PaginationMeta `json:"meta"`
+}
+
+// ListCommentsParams represents parameters for listing comments
+type ListCommentsParams struct {
+	Page         *int
+	Limit        *int
+	ObjectType   *string
+	ObjectID     *string
+	AuthorUserID *string
+}
+
+// ListComments retrieves a paginated list of comments
+func (c *Client) ListComments(ctx context.Context, params ListCommentsParams) (*PaginatedComments, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	baseURL := fmt.Sprintf("%s/api/public/comments", c.config.BaseURL)
+	queryParams := url.Values{}
+
+	if params.Page != nil {
+		queryParams.Set("page", strconv.Itoa(*params.Page))
+	}
+	if params.Limit != nil {
+		queryParams.Set("limit", strconv.Itoa(*params.Limit))
+	}
+	if params.ObjectType != nil {
+		queryParams.Set("objectType", *params.ObjectType)
+	}
+	if params.ObjectID != nil {
+		queryParams.Set("objectId", *params.ObjectID)
+	}
+	if params.AuthorUserID != nil {
+		queryParams.Set("authorUserId", *params.AuthorUserID)
+	}
+
+	fullURL := baseURL
+	if len(queryParams) > 0 {
+		fullURL = baseURL + "?" + queryParams.Encode()
+	}
+
+	comments, err := c.fetchJSON(ctx, fullURL, &PaginatedComments{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	return comments.(*PaginatedComments), nil
+}
@@ -1,6 +1,9 @@
 package langfuse
 
 import (
+	"crypto/tls"
+	"os"
+	"strconv"
 	"time"
 )
 
@@ -12,9 +15,27 @@ type Config struct {
 	// SecretKey is the Langfuse project secret key
 	SecretKey string
 
+	// DryRun writes each flushed batch of events to the Logger, pretty-printed,
+	// instead of sending it (default: false). Events still go through the
+	// queue, BeforeFlush and metrics, and are reported as delivered, so
+	// instrumentation can be checked without keys or network access, which
+	// DryRun does not require. Unlike with Enabled false, events are created
+	// and flushed as usual. Reads such as GetTrace still call the API.
+	DryRun bool
+
 	// BaseURL is the Langfuse API base URL (default: https://cloud.langfuse.com)
 	BaseURL string
 
+	// IngestionBaseURL is the base URL events are sent to, for deployments that
+	// serve ingestion from a separate host. Reads (traces, prompts, datasets, ...)
+	// keep using BaseURL (default: empty, use BaseURL)
+	IngestionBaseURL string
+
+	// ProjectID is the ID of the Langfuse project the keys belong to, as shown
+	// in its UI URLs. It is only used to build UI links such as TraceURL
+	// (default: empty, links that redirect to the project once signed in)
+	ProjectID string
+
 	// FlushInterval is how often to flush events to the server (default: 1 second)
 	FlushInterval time.Duration
 
@@ -24,9 +45,30 @@ type Config struct {
 	// MaxQueueSize is the maximum number of events to queue before dropping (default: 1000)
 	MaxQueueSize int
 
+	// QueueFullBehavior is what happens to a new event when MaxQueueSize
+	// events are queued (default: QueueFullDropNewest)
+	QueueFullBehavior QueueFullBehavior
+
+	// QueueFullTimeout is how long QueueFullBlock waits for queue space
+	// before dropping the event (default: 0, meaning DefaultQueueFullTimeout)
+	QueueFullTimeout time.Duration
+
 	// Timeout is the HTTP request timeout (default: 10 seconds)
 	Timeout time.Duration
 
+	// NetworkTimeout limits DNS resolution and connecting to the server, so an
+	// unreachable host fails fast while Timeout still allows slow responses
+	// (default: 5 seconds; 0 uses the net/http default)
+	NetworkTimeout time.Duration
+
+	// TLSConfig configures TLS for all requests, e.g. to trust the internal CA
+	// of a self-hosted instance by setting RootCAs (default: nil, system roots).
+	//
+	// SECURITY: this replaces the default TLS settings. Prefer adding your CA to
+	// RootCAs over InsecureSkipVerify, which turns off certificate checks and
+	// lets anyone on the network read and alter your traces and API keys.
+	TLSConfig *tls.Config
+
 	// SDKIntegration identifies the SDK integration (optional)
 	SDKIntegration string
 
@@ -39,15 +81,33 @@ type Config struct {
 	// Debug enables debug logging (default: false)
 	Debug bool
 
-	// MaxRetryAttempts is the maximum number of retry attempts for retryable errors (default: 5)
+	// Logger receives the SDK's log messages (default: the standard log
+	// package, with debug messages only if Debug is set)
+	Logger Logger
+
+	// MaxRetryAttempts is the maximum number of retry attempts of ingested
+	// events for retryable errors (default: 5)
 	MaxRetryAttempts int
 
-	// RetryBaseDelay is the base delay for retry backoff (default: 5 seconds)
+	// RetryBaseDelay is the base delay for retry backoff of ingested events (default: 5 seconds)
 	RetryBaseDelay time.Duration
 
-	// RetryMaxDelay is the maximum delay for retry backoff (default: 30 seconds)
+	// RetryMaxDelay is the maximum delay for retry backoff of ingested events (default: 30 seconds)
 	RetryMaxDelay time.Duration
 
+	// SyncMaxRetryAttempts is the maximum number of retry attempts of
+	// synchronous reads and deletes, such as GetTrace, for retryable errors.
+	// The caller waits for them, so their budget is kept short (default: 3)
+	SyncMaxRetryAttempts int
+
+	// SyncRetryBaseDelay is the base delay for retry backoff of synchronous
+	// requests (default: 100 milliseconds)
+	SyncRetryBaseDelay time.Duration
+
+	// SyncRetryMaxDelay is the maximum delay for retry backoff of synchronous
+	// requests (default: 1 second)
+	SyncRetryMaxDelay time.Duration
+
 	// MetricsEnabled enables metrics collection (default: false)
 	MetricsEnabled bool
 
@@ -56,8 +116,188 @@ type Config struct {
 
 	// OnEventDropped is called when events are dropped due to a full queue
 	OnEventDropped func(count int)
+
+	// OnEventDroppedWithReason is called with the number of events that were
+	// not delivered and why, for every DropReason. It is called in addition to
+	// OnEventDropped and OnEventFlushed.
+	OnEventDroppedWithReason func(count int, reason DropReason)
+
+	// OnIngestionError is called for each event the ingestion API rejected in
+	// an otherwise accepted request (HTTP 207), with the event as it was sent
+	// and the API's error, e.g. to alert on malformed observations. The calls
+	// for one request run in order on their own goroutine. Events whose whole
+	// request failed are reported through OnEventDroppedWithReason instead.
+	OnIngestionError func(event Event, err ErrorResult)
+
+	// BeforeFlush is called with the events of each flush just before they are
+	// sent and returns the events to send, e.g. with a heartbeat event appended
+	// or a batch ID added to each event's Metadata. It runs on the flush
+	// goroutine, so it must be fast. Requeued events pass through it again when
+	// they are retried. Returned events without an ID, Type, Timestamp or Body
+	// are not sent and are recorded as failed; if it panics, the events are sent unchanged.
+	BeforeFlush func(events []Event) []Event
+
+	// MaskFunc is applied to the Input and Output of every trace and
+	// observation event before it is queued, e.g. to scrub emails or API keys.
+	// It gets the value as passed to the SDK and returns the value to send,
+	// and may redact or drop nested fields. It runs on the calling goroutine
+	// and must not modify value in place. Metadata is not masked.
+	MaskFunc func(value any) any
+
+	// TagStructTypes records the Go type name of struct inputs and outputs,
+	// e.g. "tools.SearchArgs", under the TypeTagKey ("_type") key of the
+	// object sent, to tell input shapes apart in the UI and in analysis
+	// (default: false). Structs are converted to JSON objects when the event
+	// is queued, after MaskFunc. Anonymous structs, maps and other values are
+	// sent unchanged.
+	TagStructTypes bool
+
+	// DiffTraceUpdates makes Trace.Update also leave out the fields it sets to
+	// the value they had in the last trace event (default: false).
+	//
+	// Deprecated: Trace.Update already sends only the fields it sets, plus the
+	// trace ID, which is what callers need to keep payloads small.
+	DiffTraceUpdates bool
+
+	// DeferTraceCreation makes CreateTrace hold back the trace-create event
+	// until the first observation or score is created through the Trace, or
+	// the trace is updated or ended (default: false), so requests aborted
+	// before doing any work leave no empty traces. The trace ID is available
+	// right away. Observations created with Client methods by trace ID do not
+	// send the trace.
+	DeferTraceCreation bool
+
+	// MergeTraceTags makes Trace.Update add the given Tags to the trace's tags,
+	// skipping duplicates, instead of replacing them (default: false). Tags can
+	// still be removed by listing "tags" in Clear. Trace.AddTags always merges.
+	MergeTraceTags bool
+
+	// DisableMetadataOnEvents leaves the event-level Metadata envelope out of
+	// every event sent, including events returned by BeforeFlush, to reduce
+	// payload size (default: false). Observation and trace metadata in the
+	// event body are not affected.
+	DisableMetadataOnEvents bool
+
+	// CostPrecision is the number of decimal places usage costs are rounded to
+	// before they are sent (default: 0, no rounding)
+	CostPrecision int
+
+	// FlushOnFinalize makes a Client that is garbage collected without Close
+	// flush its queued events synchronously instead of discarding them (default: false).
+	// Finalizers do not run at process exit, so this does not replace Close.
+	FlushOnFinalize bool
+
+	// DisableOnAuthError disables the client when ingestion fails with HTTP 401 or 403,
+	// so revoked credentials don't cause a failing flush on every interval (default: false).
+	// Events created after that are discarded until a new client is created.
+	DisableOnAuthError bool
+
+	// DisableObservationSequence stops stamping observations with a per-trace
+	// sequence number under the MetadataKeySequence metadata key (default: false)
+	DisableObservationSequence bool
+
+	// WarnDeprecated logs a warning through Logger the first time each call
+	// site uses a deprecated function (default: false). cmd/langfusefix
+	// migrates the common deprecated patterns.
+	WarnDeprecated bool
+
+	// WarnIncompleteGenerations logs a warning when a generation ends without a
+	// Model or Usage, which leaves it out of cost analytics (default: false).
+	// Meant for development, to surface instrumentation gaps.
+	WarnIncompleteGenerations bool
+
+	// ValidateOutputSchema checks the output of a generation ended through a
+	// GenerationHandle against its GenerationParams.OutputSchema, and records
+	// the result under the MetadataKeySchemaValid metadata key (default: false).
+	// See ValidateJSONSchema for the supported schema keywords.
+	ValidateOutputSchema bool
+
+	// OutputSchemaScoreName, if set with ValidateOutputSchema, also records the
+	// validation result as a BOOLEAN score with this name on the generation
+	OutputSchemaScoreName string
+
+	// SampleRate is the fraction of traces to send, between 0 and 1 (default:
+	// nil, send every trace). Ptr(0.0) drops every trace. Whether a trace is
+	// sampled depends only on a hash of its ID, so all of its events, including
+	// observations, updates and scores, are either sent or dropped before being
+	// queued, recorded with DropReasonSampled. See Trace.Sampled.
+	SampleRate *float64
+
+	// MaxEventAge discards queued events older than this at flush time instead of
+	// sending them, recording them as dropped (default: 0, no limit)
+	MaxEventAge time.Duration
+
+	// MaxBatchBytes is the maximum serialized size of one ingestion request;
+	// larger flushes are split into several requests, and events larger than
+	// this on their own are recorded as failed (default: DefaultMaxBatchBytes)
+	MaxBatchBytes int
+
+	// PersistentQueuePath is a file that mirrors the event queue, so events
+	// that were not delivered before the process exited are sent by the next
+	// client created with the same path (default: empty, queue in memory only).
+	// Only one client at a time may use a file.
+	PersistentQueuePath string
+
+	// DeadLetterPath is a file that events given up on are appended to:
+	// events rejected by the API, failed with a non-retryable error, out of
+	// retries or larger than MaxBatchBytes (default: empty, they are only
+	// recorded as dropped). It is in the PersistentQueuePath format, so the
+	// events can be inspected, or sent again by starting a client with the
+	// file as its PersistentQueuePath. The file is synced after each append
+	// and never truncated by the SDK.
+	DeadLetterPath string
+
+	// OverflowToDisk, with PersistentQueuePath set, writes events that do not
+	// fit in a full queue to the file PersistentQueuePath + ".overflow" instead
+	// of handling them as set by QueueFullBehavior, and moves them back into
+	// the queue in the background as flushes make room. Events left in the
+	// file are replayed by the next client using the same path.
+	OverflowToDisk bool
+
+	// MaxOverflowBytes is the maximum size of the OverflowToDisk file; once it
+	// is reached, events are handled as set by QueueFullBehavior again
+	// (default: 0, meaning DefaultMaxOverflowBytes)
+	MaxOverflowBytes int64
+
+	// TraceCacheMaxSize is the number of recently used traces kept for
+	// Client.CachedTrace; the least recently used are evicted first
+	// (default: 0, no traces are cached)
+	TraceCacheMaxSize int
+
+	// HealthWindow is the number of most recent ingestion requests
+	// Client.IsHealthy considers (default: 0, meaning DefaultHealthWindow)
+	HealthWindow int
+
+	// HealthMinSuccessRate is the fraction of the last HealthWindow ingestion
+	// requests, between 0 and 1, that must have succeeded for Client.IsHealthy
+	// to report true (default: 0, meaning DefaultHealthMinSuccessRate)
+	HealthMinSuccessRate float64
+
+	// IDVersion is the UUID version of the IDs the SDK generates for traces,
+	// observations, scores and events: 7, which are time-ordered, so IDs
+	// generated later in a process sort after earlier ones, or 4, which are
+	// random (default: 0, meaning 7)
+	IDVersion int
+
+	// IDGenerator, if set, generates the IDs of traces, observations, scores
+	// and events instead of IDVersion UUIDs, e.g. to add a tenant prefix or to
+	// get predictable IDs in tests. It is called concurrently. If it returns an
+	// empty string, a UUID is used instead and a warning is logged.
+	IDGenerator func() string
+
+	// Transport selects the API events are sent to (default: TransportIngestion).
+	// With TransportOTLP, traces and observations are sent as OTLP spans and
+	// stored under OTLP IDs, see OTLPTraceID.
+	Transport Transport
+
+	// Clock drives the background flush loop and event age checks
+	// (default: nil, the system clock). Meant for tests, see the langfusetest package.
+	Clock Clock
 }
 
+// DefaultMaxBatchBytes is the default MaxBatchBytes, below the ingestion API's ~3.5MB limit
+const DefaultMaxBatchBytes = 3_000_000
+
 // DefaultConfig returns a Config with default values
 func DefaultConfig() *Config {
 	return &Config{
@@ -66,22 +306,96 @@ func DefaultConfig() *Config {
 		FlushAt:          15,
 		MaxQueueSize:     1000,
 		Timeout:          10 * time.Second,
+		NetworkTimeout:   5 * time.Second,
 		SDKVersion:       "0.2.0",
 		Enabled:          true,
 		Debug:            false,
 		MaxRetryAttempts: 5,
 		RetryBaseDelay:   5 * time.Second,
 		RetryMaxDelay:    30 * time.Second,
-		MetricsEnabled:   false,
+
+		SyncMaxRetryAttempts: 3,
+		SyncRetryBaseDelay:   100 * time.Millisecond,
+		SyncRetryMaxDelay:    time.Second,
+
+		MetricsEnabled: false,
+		MaxBatchBytes:  DefaultMaxBatchBytes,
+	}
+}
+
+// Environment variables read by ConfigFromEnv
+const (
+	EnvPublicKey     = "LANGFUSE_PUBLIC_KEY"
+	EnvSecretKey     = "LANGFUSE_SECRET_KEY"
+	EnvBaseURL       = "LANGFUSE_BASE_URL"
+	EnvDebug         = "LANGFUSE_DEBUG"
+	EnvEnabled       = "LANGFUSE_ENABLED"
+	EnvFlushAt       = "LANGFUSE_FLUSH_AT"
+	EnvFlushInterval = "LANGFUSE_FLUSH_INTERVAL"
+)
+
+// ConfigFromEnv returns DefaultConfig overridden by any LANGFUSE_* environment
+// variables that are set. LANGFUSE_FLUSH_INTERVAL accepts a Go duration ("500ms")
+// or a number of seconds ("0.5"). The returned config is validated, so missing
+// keys are reported as a ConfigError.
+func ConfigFromEnv() (*Config, error) {
+	config := DefaultConfig()
+
+	if v := os.Getenv(EnvPublicKey); v != "" {
+		config.PublicKey = v
+	}
+	if v := os.Getenv(EnvSecretKey); v != "" {
+		config.SecretKey = v
 	}
+	if v := os.Getenv(EnvBaseURL); v != "" {
+		config.BaseURL = v
+	}
+	if v := os.Getenv(EnvDebug); v != "" {
+		debug, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &ConfigError{Field: "Debug", Message: EnvDebug + " must be a boolean"}
+		}
+		config.Debug = debug
+	}
+	if v := os.Getenv(EnvEnabled); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &ConfigError{Field: "Enabled", Message: EnvEnabled + " must be a boolean"}
+		}
+		config.Enabled = enabled
+	}
+	if v := os.Getenv(EnvFlushAt); v != "" {
+		flushAt, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, &ConfigError{Field: "FlushAt", Message: EnvFlushAt + " must be an integer"}
+		}
+		config.FlushAt = flushAt
+	}
+	if v := os.Getenv(EnvFlushInterval); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			seconds, ferr := strconv.ParseFloat(v, 64)
+			if ferr != nil {
+				return nil, &ConfigError{Field: "FlushInterval", Message: EnvFlushInterval + " must be a duration or a number of seconds"}
+			}
+			interval = time.Duration(seconds * float64(time.Second))
+		}
+		config.FlushInterval = interval
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.PublicKey == "" {
+	if c.PublicKey == "" && !c.DryRun {
 		return &ConfigError{Field: "PublicKey", Message: "public key is required"}
 	}
-	if c.SecretKey == "" {
+	if c.SecretKey == "" && !c.DryRun {
 		return &ConfigError{Field: "SecretKey", Message: "secret key is required"}
 	}
 	if c.BaseURL == "" {
@@ -93,9 +407,37 @@ func (c *Config) Validate() error {
 	if c.MaxQueueSize <= 0 {
 		return &ConfigError{Field: "MaxQueueSize", Message: "max queue size must be positive"}
 	}
+	switch c.QueueFullBehavior {
+	case "", QueueFullDropNewest, QueueFullDropOldest, QueueFullBlock:
+	default:
+		return &ConfigError{Field: "QueueFullBehavior", Message: "queue full behavior must be drop_newest, drop_oldest or block"}
+	}
+	if c.OverflowToDisk && c.PersistentQueuePath == "" {
+		return &ConfigError{Field: "OverflowToDisk", Message: "overflow to disk requires a persistent queue path"}
+	}
+	if c.MaxOverflowBytes < 0 {
+		return &ConfigError{Field: "MaxOverflowBytes", Message: "max overflow bytes must not be negative"}
+	}
+	if c.SampleRate != nil && (*c.SampleRate < 0 || *c.SampleRate > 1) {
+		return &ConfigError{Field: "SampleRate", Message: "sample rate must be between 0 and 1"}
+	}
+	if c.HealthMinSuccessRate < 0 || c.HealthMinSuccessRate > 1 {
+		return &ConfigError{Field: "HealthMinSuccessRate", Message: "health min success rate must be between 0 and 1"}
+	}
+	if c.IDVersion != 0 && c.IDVersion != 4 && c.IDVersion != 7 {
+		return &ConfigError{Field: "IDVersion", Message: "ID version must be 4 or 7"}
+	}
 	return nil
 }
 
+// ingestionBaseURL returns IngestionBaseURL, or BaseURL if it is not set
+func (c *Config) ingestionBaseURL() string {
+	if c.IngestionBaseURL != "" {
+		return c.IngestionBaseURL
+	}
+	return c.BaseURL
+}
+
 // ConfigError represents a configuration error
 type ConfigError struct {
 	Field   string
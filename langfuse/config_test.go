@@ -0,0 +1,62 @@
+package langfuse
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIngestionBaseURLSeparatesIngestionFromReads(t *testing.T) {
+	api := newTestServer(t)
+	ingestion := newTestServer(t)
+	api.handle("GET", "/api/public/traces/t1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"id": "t1"})
+	})
+	client := newTestClient(t, api, func(c *Config) {
+		c.IngestionBaseURL = ingestion.URL
+	})
+
+	createTraces(t, client, 1)
+	flush(t, client)
+	trace, err := client.GetTrace(context.Background(), GetTraceParams{TraceID: "t1"})
+	if err != nil {
+		t.Fatalf("GetTrace from BaseURL: %v", err)
+	}
+
+	if trace.ID != "t1" {
+		t.Errorf("GetTrace = %+v, want trace t1", trace)
+	}
+	if n := len(ingestion.Events()); n != 1 {
+		t.Errorf("IngestionBaseURL received %d events, want 1", n)
+	}
+	if n := len(api.Events()); n != 0 {
+		t.Errorf("BaseURL received %d events, want none", n)
+	}
+}
+
+func TestTLSConfigTrustsSelfSignedServer(t *testing.T) {
+	server := &testServer{handlers: make(map[string]http.HandlerFunc)}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+
+	untrusting := newTestClient(t, server)
+	createTraces(t, untrusting, 1)
+	if err := untrusting.Flush(context.Background()); err == nil {
+		t.Fatal("Flush to a self-signed server succeeded without TLSConfig")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client := newTestClient(t, server, func(c *Config) {
+		c.TLSConfig = &tls.Config{RootCAs: roots}
+	})
+	ids := createTraces(t, client, 1)
+	flush(t, client)
+
+	if counts := traceCreateCounts(server); counts[ids[0]] != 1 {
+		t.Errorf("trace %s was sent %d times with TLSConfig, want 1", ids[0], counts[ids[0]])
+	}
+}
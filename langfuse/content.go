@@ -0,0 +1,160 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Content part types
+const (
+	ContentPartTypeText     = "text"
+	ContentPartTypeImageURL = "image_url"
+)
+
+// ContentPart is one part of a multimodal chat message content.
+// A ChatMessage.Content of []ContentPart is rendered as multimodal in the Langfuse UI.
+type ContentPart struct {
+	// Type is ContentPartTypeText or ContentPartTypeImageURL
+	Type string `json:"type"`
+
+	// Text is the text of a text part
+	Text string `json:"text,omitempty"`
+
+	// ImageURL is the image of an image_url part
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is the image referenced by an image_url content part
+type ImageURL struct {
+	// URL is an http(s) URL or a base64 data URL
+	URL string `json:"url"`
+
+	// Detail is the image detail level (auto, low, high)
+	Detail string `json:"detail,omitempty"`
+}
+
+// TextPart returns a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartTypeText, Text: text}
+}
+
+// ImageURLPart returns an image_url content part
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: ContentPartTypeImageURL, ImageURL: &ImageURL{URL: url}}
+}
+
+// Validate checks that the part has a known type and the matching field set
+func (p ContentPart) Validate() error {
+	switch p.Type {
+	case ContentPartTypeText:
+		return nil
+	case ContentPartTypeImageURL:
+		if p.ImageURL == nil || p.ImageURL.URL == "" {
+			return fmt.Errorf("image_url content part requires a URL")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown content part type %q", p.Type)
+	}
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for ChatMessage
+// to decode the content field as a string or a list of content parts
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	type Alias ChatMessage
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(m),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Content = nil
+	if len(aux.Content) == 0 || string(aux.Content) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(aux.Content, &text); err == nil {
+		m.Content = text
+		return nil
+	}
+
+	var parts []ContentPart
+	if err := json.Unmarshal(aux.Content, &parts); err == nil && allTyped(parts) {
+		m.Content = parts
+		return nil
+	}
+
+	// Keep any other content as a generic JSON value
+	var content interface{}
+	if err := json.Unmarshal(aux.Content, &content); err != nil {
+		return err
+	}
+	m.Content = content
+	return nil
+}
+
+// allTyped reports whether every part has a type, i.e. the list really is content parts
+func allTyped(parts []ContentPart) bool {
+	for _, p := range parts {
+		if p.Type == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Text returns the text of the message: the content itself if it is a
+// string, or its text parts joined by newlines if it is a list of parts
+func (m ChatMessage) Text() string {
+	switch v := m.Content.(type) {
+	case string:
+		return v
+	case []ContentPart:
+		var texts []string
+		for _, p := range v {
+			if p.Type == ContentPartTypeText {
+				texts = append(texts, p.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// contentFromData converts decoded JSON content into a string, a list of
+// content parts, or leaves it as is if it is neither
+func contentFromData(data interface{}) interface{} {
+	items, ok := data.([]interface{})
+	if !ok {
+		return data
+	}
+
+	parts := make([]ContentPart, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return data
+		}
+		partType, _ := m["type"].(string)
+		if partType == "" {
+			return data
+		}
+
+		part := ContentPart{Type: partType}
+		part.Text, _ = m["text"].(string)
+		if image, ok := m["image_url"].(map[string]interface{}); ok {
+			part.ImageURL = &ImageURL{}
+			part.ImageURL.URL, _ = image["url"].(string)
+			part.ImageURL.Detail, _ = image["detail"].(string)
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
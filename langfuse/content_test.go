@@ -0,0 +1,60 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChatMessageContentSerialization(t *testing.T) {
+	tests := []struct {
+		name    string
+		message ChatMessage
+		want    string
+	}{
+		{
+			name:    "text only",
+			message: ChatMessage{Role: "user", Content: "Describe this image"},
+			want:    `{"role":"user","content":"Describe this image"}`,
+		},
+		{
+			name: "mixed content",
+			message: ChatMessage{Role: "user", Content: []ContentPart{
+				TextPart("Describe this image"),
+				ImageURLPart("https://example.com/cat.png"),
+			}},
+			want: `{"role":"user","content":[{"type":"text","text":"Describe this image"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal = %s, want %s", data, tt.want)
+			}
+
+			var decoded ChatMessage
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, tt.message) {
+				t.Errorf("Unmarshal = %+v, want %+v", decoded, tt.message)
+			}
+		})
+	}
+}
+
+func TestContentPartValidate(t *testing.T) {
+	if err := ImageURLPart("https://example.com/cat.png").Validate(); err != nil {
+		t.Errorf("Validate(image_url part) = %v", err)
+	}
+	if err := (ContentPart{Type: ContentPartTypeImageURL}).Validate(); err == nil {
+		t.Error("Validate accepted an image_url part without a URL")
+	}
+	if err := (ContentPart{Type: "audio"}).Validate(); err == nil {
+		t.Error("Validate accepted an unknown part type")
+	}
+}
@@ -0,0 +1,96 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoTraceInContext is returned by the FromContext creation helpers when the
+// context carries no trace
+var ErrNoTraceInContext = errors.New("langfuse: no trace in context")
+
+type traceContextKey struct{}
+
+type observationContextKey struct{}
+
+// ContextWithTrace returns a copy of ctx that carries trace, so code further
+// down the call chain can add observations to it without explicit plumbing.
+// Any observation carried by ctx is dropped, since it belongs to another trace.
+func ContextWithTrace(ctx context.Context, trace *Trace) context.Context {
+	ctx = context.WithValue(ctx, traceContextKey{}, trace)
+	return context.WithValue(ctx, observationContextKey{}, "")
+}
+
+// TraceFromContext returns the trace carried by ctx, or nil if there is none
+func TraceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceContextKey{}).(*Trace)
+	return trace
+}
+
+// ContextWithObservation returns a copy of ctx that carries observationID as
+// the current observation, which the FromContext creation helpers and Observe
+// use as the parent of new observations
+func ContextWithObservation(ctx context.Context, observationID string) context.Context {
+	return context.WithValue(ctx, observationContextKey{}, observationID)
+}
+
+// ObservationIDFromContext returns the ID of the current observation carried
+// by ctx, or an empty string if there is none
+func ObservationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(observationContextKey{}).(string)
+	return id
+}
+
+// parentFromContext returns the current observation of ctx as a parent ID
+// for an observation created on trace, or nil if ctx has no current
+// observation or carries a different trace
+func parentFromContext(ctx context.Context, trace *Trace) *string {
+	if ctxTrace := TraceFromContext(ctx); ctxTrace != nil && ctxTrace != trace {
+		return nil
+	}
+	if id := ObservationIDFromContext(ctx); id != "" {
+		return &id
+	}
+	return nil
+}
+
+// SpanFromContext starts a span named name on the trace carried by ctx, nested
+// under the current observation of ctx if there is one. It returns the span's
+// handle and a copy of ctx with the span as the current observation, or
+// ErrNoTraceInContext.
+func SpanFromContext(ctx context.Context, name string, opts ...SpanOption) (*SpanHandle, context.Context, error) {
+	trace := TraceFromContext(ctx)
+	if trace == nil {
+		return nil, ctx, ErrNoTraceInContext
+	}
+
+	params := NewSpan(name, opts...)
+	if params.ParentObservationID == nil {
+		params.ParentObservationID = parentFromContext(ctx, trace)
+	}
+
+	span, err := trace.StartSpan(params)
+	if err != nil {
+		return nil, ctx, err
+	}
+	return span, ContextWithObservation(ctx, span.id), nil
+}
+
+// GenerationFromContext is like SpanFromContext for a generation
+func GenerationFromContext(ctx context.Context, name string, opts ...GenerationOption) (*GenerationHandle, context.Context, error) {
+	trace := TraceFromContext(ctx)
+	if trace == nil {
+		return nil, ctx, ErrNoTraceInContext
+	}
+
+	params := NewGeneration(name, opts...)
+	if params.ParentObservationID == nil {
+		params.ParentObservationID = parentFromContext(ctx, trace)
+	}
+
+	generation, err := trace.StartGeneration(params)
+	if err != nil {
+		return nil, ctx, err
+	}
+	return generation, ContextWithObservation(ctx, generation.id), nil
+}
@@ -0,0 +1,98 @@
+package langfuse
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// MetadataKeyCostCurrency is the metadata key that carries Usage.Currency on generations
+const MetadataKeyCostCurrency = "costCurrency"
+
+// currencySymbols maps ISO currency codes to display symbols
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+}
+
+// RoundCost rounds a cost to the given number of decimal places.
+// A non-positive precision returns the cost unchanged.
+func RoundCost(cost float64, precision int) float64 {
+	if precision <= 0 {
+		return cost
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(cost*scale) / scale
+}
+
+// Cost returns the total cost, falling back to InputCost + OutputCost
+// when TotalCost is not set
+func (u Usage) Cost() float64 {
+	if u.TotalCost != nil {
+		return *u.TotalCost
+	}
+	var cost float64
+	if u.InputCost != nil {
+		cost += *u.InputCost
+	}
+	if u.OutputCost != nil {
+		cost += *u.OutputCost
+	}
+	return cost
+}
+
+// FormatCost returns the cost formatted with its currency, e.g. "$0.0001235".
+// Four significant digits are kept; the currency defaults to USD.
+func (u Usage) FormatCost() string {
+	currency := "USD"
+	if u.Currency != nil && *u.Currency != "" {
+		currency = *u.Currency
+	}
+
+	cost := u.Cost()
+	decimals := 2
+	if cost != 0 {
+		if d := 3 - int(math.Floor(math.Log10(math.Abs(cost)))); d > decimals {
+			decimals = d
+		}
+	}
+	amount := strconv.FormatFloat(cost, 'f', decimals, 64)
+
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol + amount
+	}
+	return amount + " " + currency
+}
+
+// SumCosts adds up the costs of the given usages. The sum is exact, without
+// floating-point drift, and only rounded once to a float64 at the end, so
+// tiny per-token costs such as 0.00000015 are not lost.
+func SumCosts(usages ...Usage) float64 {
+	sum := new(big.Rat)
+	for _, u := range usages {
+		cost := u.Cost()
+		if math.IsNaN(cost) || math.IsInf(cost, 0) {
+			continue
+		}
+		sum.Add(sum, new(big.Rat).SetFloat64(cost))
+	}
+	total, _ := sum.Float64()
+	return total
+}
+
+// roundedUsage returns a copy of usage with its costs rounded to precision
+func roundedUsage(usage *Usage, precision int) *Usage {
+	if usage == nil || precision <= 0 {
+		return usage
+	}
+	rounded := *usage
+	for _, cost := range []**float64{&rounded.InputCost, &rounded.OutputCost, &rounded.TotalCost} {
+		if *cost != nil {
+			*cost = ptr(RoundCost(**cost, precision))
+		}
+	}
+	return &rounded
+}
@@ -0,0 +1,54 @@
+package langfuse
+
+import "testing"
+
+func TestSumCostsKeepsTinyCosts(t *testing.T) {
+	usages := make([]Usage, 1000)
+	for i := range usages {
+		usages[i] = Usage{TotalCost: Ptr(0.00000015)}
+	}
+
+	got := SumCosts(usages...)
+	if want := 0.00015; got != want {
+		t.Errorf("SumCosts = %v, want %v", got, want)
+	}
+}
+
+func TestSumCostsHasNoDrift(t *testing.T) {
+	usages := make([]Usage, 10)
+	for i := range usages {
+		usages[i] = Usage{InputCost: Ptr(0.1)}
+	}
+
+	if got := SumCosts(usages...); got != 1 {
+		t.Errorf("SumCosts = %v, want 1", got)
+	}
+}
+
+func TestSumCostsFallsBackToInputAndOutputCost(t *testing.T) {
+	got := SumCosts(
+		Usage{TotalCost: Ptr(0.5)},
+		Usage{InputCost: Ptr(0.25), OutputCost: Ptr(0.125)},
+		Usage{},
+	)
+	if want := 0.875; got != want {
+		t.Errorf("SumCosts = %v, want %v", got, want)
+	}
+}
+
+func TestFormatCost(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  string
+	}{
+		{Usage{TotalCost: Ptr(0.000123456789)}, "$0.0001235"},
+		{Usage{TotalCost: Ptr(12.5)}, "$12.50"},
+		{Usage{TotalCost: Ptr(0.5), Currency: Ptr("EUR")}, "€0.5000"},
+		{Usage{TotalCost: Ptr(3.0), Currency: Ptr("CHF")}, "3.000 CHF"},
+	}
+	for _, tt := range tests {
+		if got := tt.usage.FormatCost(); got != tt.want {
+			t.Errorf("FormatCost(%v) = %q, want %q", tt.usage.Cost(), got, tt.want)
+		}
+	}
+}
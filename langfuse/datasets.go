@@ -0,0 +1,399 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dataset is a named collection of items to evaluate, e.g. inputs with their expected outputs
+type Dataset struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description *string                `json:"description,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	ProjectID   string                 `json:"projectId"`
+	CreatedAt   string                 `json:"createdAt"`
+	UpdatedAt   string                 `json:"updatedAt"`
+
+	// Items are the dataset's items, filled in by GetDataset
+	Items []DatasetItem `json:"items,omitempty"`
+}
+
+// DatasetItem is one input of a dataset, optionally with its expected output
+type DatasetItem struct {
+	ID                  string                 `json:"id"`
+	DatasetID           string                 `json:"datasetId"`
+	DatasetName         string                 `json:"datasetName"`
+	Input               interface{}            `json:"input,omitempty"`
+	ExpectedOutput      interface{}            `json:"expectedOutput,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	SourceTraceID       *string                `json:"sourceTraceId,omitempty"`
+	SourceObservationID *string                `json:"sourceObservationId,omitempty"`
+	Status              string                 `json:"status"`
+	CreatedAt           string                 `json:"createdAt"`
+	UpdatedAt           string                 `json:"updatedAt"`
+}
+
+// DatasetItemParams contains parameters for creating a dataset item
+type DatasetItemParams struct {
+	// DatasetName is the name of the dataset to add the item to (required)
+	DatasetName string
+
+	// ID is the item ID (auto-generated by the server if not provided).
+	// Creating an item with the ID of an existing item updates it.
+	ID *string
+
+	Input          interface{}
+	ExpectedOutput interface{}
+	Metadata       map[string]interface{}
+
+	// SourceTraceID and SourceObservationID link the item to the trace or
+	// observation it was taken from, e.g. one fetched with ListTraces
+	SourceTraceID       *string
+	SourceObservationID *string
+
+	// Status is "ACTIVE" or "ARCHIVED" (default: "ACTIVE")
+	Status *string
+}
+
+// paginatedDatasetItems is a page of dataset items
+type paginatedDatasetItems struct {
+	Data []DatasetItem  `json:"data"`
+	Meta PaginationMeta `json:"meta"`
+}
+
+// CreateDataset creates a dataset. Creating a dataset with the name of an
+// existing one updates its description and metadata.
+func (c *Client) CreateDataset(ctx context.Context, name string, description *string, metadata map[string]interface{}) (*Dataset, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	body := map[string]interface{}{
+		"name": name,
+	}
+	if description != nil {
+		body["description"] = *description
+	}
+	if metadata != nil {
+		body["metadata"] = metadata
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/v2/datasets", c.config.BaseURL)
+
+	dataset, err := c.doJSON(ctx, "POST", fullURL, body, &Dataset{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dataset: %w", err)
+	}
+
+	return dataset.(*Dataset), nil
+}
+
+// GetDataset retrieves a dataset with all its items
+func (c *Client) GetDataset(ctx context.Context, name string) (*Dataset, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/v2/datasets/%s", c.config.BaseURL, url.PathEscape(name))
+
+	result, err := c.fetchJSON(ctx, fullURL, &Dataset{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dataset: %w", err)
+	}
+	dataset := result.(*Dataset)
+
+	dataset.Items, err = c.listDatasetItems(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items of dataset %s: %w", name, err)
+	}
+
+	return dataset, nil
+}
+
+// listDatasetItems retrieves all items of a dataset, following pagination
+func (c *Client) listDatasetItems(ctx context.Context, datasetName string) ([]DatasetItem, error) {
+	var items []DatasetItem
+	for page := 1; ; page++ {
+		queryParams := url.Values{}
+		queryParams.Set("datasetName", datasetName)
+		queryParams.Set("page", strconv.Itoa(page))
+		queryParams.Set("limit", "100")
+
+		fullURL := fmt.Sprintf("%s/api/public/dataset-items?%s", c.config.BaseURL, queryParams.Encode())
+
+		result, err := c.fetchJSON(ctx, fullURL, &paginatedDatasetItems{})
+		if err != nil {
+			return nil, err
+		}
+		paginated := result.(*paginatedDatasetItems)
+
+		items = append(items, paginated.Data...)
+		if page >= paginated.Meta.TotalPages || len(paginated.Data) == 0 {
+			return items, nil
+		}
+	}
+}
+
+// CreateDatasetItem adds an item to a dataset, or updates the item with params.ID
+func (c *Client) CreateDatasetItem(ctx context.Context, params DatasetItemParams) (*DatasetItem, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if params.DatasetName == "" {
+		return nil, fmt.Errorf("datasetName is required")
+	}
+
+	body := map[string]interface{}{
+		"datasetName": params.DatasetName,
+	}
+	if params.ID != nil {
+		body["id"] = *params.ID
+	}
+	if params.Input != nil {
+		body["input"] = params.Input
+	}
+	if params.ExpectedOutput != nil {
+		body["expectedOutput"] = params.ExpectedOutput
+	}
+	if params.Metadata != nil {
+		body["metadata"] = params.Metadata
+	}
+	if params.SourceTraceID != nil {
+		body["sourceTraceId"] = *params.SourceTraceID
+	}
+	if params.SourceObservationID != nil {
+		body["sourceObservationId"] = *params.SourceObservationID
+	}
+	if params.Status != nil {
+		body["status"] = *params.Status
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/dataset-items", c.config.BaseURL)
+
+	item, err := c.doJSON(ctx, "POST", fullURL, body, &DatasetItem{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dataset item: %w", err)
+	}
+
+	return item.(*DatasetItem), nil
+}
+
+// DatasetRun is a named run of a dataset, e.g. one model or prompt version
+type DatasetRun struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description *string                `json:"description,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	DatasetID   string                 `json:"datasetId"`
+	DatasetName string                 `json:"datasetName"`
+	CreatedAt   string                 `json:"createdAt"`
+	UpdatedAt   string                 `json:"updatedAt"`
+	Items       []DatasetRunItem       `json:"datasetRunItems"`
+}
+
+// DatasetRunItem links a dataset item to the trace produced for it in a run
+type DatasetRunItem struct {
+	ID             string  `json:"id"`
+	DatasetRunID   string  `json:"datasetRunId"`
+	DatasetRunName string  `json:"datasetRunName"`
+	DatasetItemID  string  `json:"datasetItemId"`
+	TraceID        string  `json:"traceId"`
+	ObservationID  *string `json:"observationId,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+}
+
+// ComparisonReport compares several runs of the same dataset
+type ComparisonReport struct {
+	DatasetName string
+
+	// Runs are the run summaries in the order the runs were requested
+	Runs []RunSummary
+}
+
+// RunSummary summarizes the traces of one dataset run
+type RunSummary struct {
+	Name string
+
+	// Items is the number of run items (traces) in the run
+	Items int
+
+	// AvgScore is the mean of all numeric and boolean scores on the run's traces
+	AvgScore float64
+
+	// AvgScores is the mean numeric or boolean score per score name
+	AvgScores map[string]float64
+
+	// P50Latency is the median trace latency
+	P50Latency time.Duration
+
+	// TotalCost is the summed cost of the run's traces
+	TotalCost float64
+}
+
+// GetDatasetRun retrieves a dataset run with its items
+func (c *Client) GetDatasetRun(ctx context.Context, datasetName, runName string) (*DatasetRun, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if datasetName == "" {
+		return nil, fmt.Errorf("datasetName is required")
+	}
+	if runName == "" {
+		return nil, fmt.Errorf("runName is required")
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/datasets/%s/runs/%s", c.config.BaseURL, url.PathEscape(datasetName), url.PathEscape(runName))
+
+	run, err := c.fetchJSON(ctx, fullURL, &DatasetRun{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dataset run: %w", err)
+	}
+
+	return run.(*DatasetRun), nil
+}
+
+// GetEvalDatasetComparison fetches the named runs of a dataset and the traces
+// of their items, and summarizes score, latency and cost per run side by side
+func (c *Client) GetEvalDatasetComparison(ctx context.Context, datasetName string, runs []string) (*ComparisonReport, error) {
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("at least one run is required")
+	}
+
+	report := &ComparisonReport{
+		DatasetName: datasetName,
+		Runs:        make([]RunSummary, 0, len(runs)),
+	}
+
+	for _, runName := range runs {
+		run, err := c.GetDatasetRun(ctx, datasetName, runName)
+		if err != nil {
+			return nil, err
+		}
+
+		traces, err := c.getRunTraces(ctx, run.Items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get traces of run %s: %w", runName, err)
+		}
+
+		summary := summarizeRun(traces)
+		summary.Name = run.Name
+		summary.Items = len(run.Items)
+		report.Runs = append(report.Runs, summary)
+	}
+
+	return report, nil
+}
+
+// getRunTraces fetches the traces of dataset run items concurrently
+func (c *Client) getRunTraces(ctx context.Context, items []DatasetRunItem) ([]*TraceWithFullDetails, error) {
+	traces := make([]*TraceWithFullDetails, len(items))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sem      = make(chan struct{}, 5)
+	)
+
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, traceID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			trace, err := c.GetTrace(ctx, GetTraceParams{TraceID: traceID})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			traces[i] = trace
+		}(i, item.TraceID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return traces, nil
+}
+
+// summarizeRun computes the score, latency and cost summary of a run's traces
+func summarizeRun(traces []*TraceWithFullDetails) RunSummary {
+	summary := RunSummary{AvgScores: make(map[string]float64)}
+
+	var (
+		scoreSum   float64
+		scoreCount int
+		sums       = make(map[string]float64)
+		counts     = make(map[string]int)
+		latencies  []float64
+		costs      []Usage
+	)
+
+	for _, trace := range traces {
+		for _, score := range trace.Scores {
+			if score.DataType == "CATEGORICAL" {
+				continue
+			}
+			scoreSum += score.Value
+			scoreCount++
+			sums[score.Name] += score.Value
+			counts[score.Name]++
+		}
+		if trace.Latency != nil {
+			latencies = append(latencies, *trace.Latency)
+		}
+		if trace.TotalCost != nil {
+			costs = append(costs, Usage{TotalCost: trace.TotalCost})
+		}
+	}
+
+	if scoreCount > 0 {
+		summary.AvgScore = scoreSum / float64(scoreCount)
+	}
+	for name, sum := range sums {
+		summary.AvgScores[name] = sum / float64(counts[name])
+	}
+	summary.P50Latency = medianSeconds(latencies)
+	summary.TotalCost = SumCosts(costs...)
+
+	return summary
+}
+
+// medianSeconds returns the median of durations given in seconds
+func medianSeconds(seconds []float64) time.Duration {
+	if len(seconds) == 0 {
+		return 0
+	}
+
+	sort.Float64s(seconds)
+	mid := len(seconds) / 2
+	median := seconds[mid]
+	if len(seconds)%2 == 0 {
+		median = (seconds[mid-1] + seconds[mid]) / 2
+	}
+	return time.Duration(median * float64(time.Second))
+}
@@ -0,0 +1,85 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// deadLetterStore appends the events the batcher gives up on to the file of
+// Config.DeadLetterPath
+type deadLetterStore struct {
+	mu   sync.Mutex
+	path string
+	file queueFile
+}
+
+// openDeadLetterStore opens or creates the dead-letter file at path for appending
+func openDeadLetterStore(fs queueFS, path string) (*deadLetterStore, error) {
+	file, err := fs.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	return &deadLetterStore{path: path, file: file}, nil
+}
+
+// add appends events to the file, syncs it, and returns how many were written
+func (d *deadLetterStore) add(events []Event) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return 0, fmt.Errorf("dead-letter file %s is closed", d.path)
+	}
+
+	written := 0
+	var data []byte
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue // cannot be replayed either
+		}
+		data = append(append(data, line...), '\n')
+		written++
+	}
+	if written == 0 {
+		return 0, nil
+	}
+	if _, err := d.file.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	if err := d.file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	return written, nil
+}
+
+// close closes the file
+func (d *deadLetterStore) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// deadLetter writes events given up on to the dead-letter file, if there is
+// one, and returns how many were written
+func (b *Batcher) deadLetter(events []Event) int {
+	if b.deadLetters == nil || len(events) == 0 {
+		return 0
+	}
+
+	written, err := b.deadLetters.add(events)
+	if err != nil {
+		b.config.logger().Warnf("%v", err)
+		return 0
+	}
+	b.config.logger().Debugf("Wrote %d undeliverable events to %s", written, b.deadLetters.path)
+	return written
+}
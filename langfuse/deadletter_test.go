@@ -0,0 +1,105 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// rejectTraceCreates answers ingestion requests accepting every event except
+// trace creates, which it reports as invalid
+func rejectTraceCreates(w http.ResponseWriter, r *http.Request) {
+	var req IngestionRequest
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+
+	var resp IngestionResponse
+	for _, e := range req.Batch {
+		if e.Type == EventTypeTraceCreate {
+			resp.Errors = append(resp.Errors, ErrorResult{ID: e.ID, Status: http.StatusBadRequest, Message: "invalid"})
+		} else {
+			resp.Successes = append(resp.Successes, SuccessResult{ID: e.ID, Status: http.StatusCreated})
+		}
+	}
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestRejectedEventsAreDeadLettered(t *testing.T) {
+	server := newTestServer(t)
+	server.handle("POST", "/api/public/ingestion", rejectTraceCreates)
+	path := filepath.Join(t.TempDir(), "dead")
+	client := newTestClient(t, server, func(c *Config) {
+		c.DeadLetterPath = path
+	})
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trace.CreateEvent(EventParams{}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := client.CloseWithContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Delivered != 1 || report.DeadLettered != 1 || report.Abandoned != 0 {
+		t.Errorf("report = %+v, want 1 delivered and 1 dead-lettered", report)
+	}
+
+	events, skipped, err := readQueueFile(osFS{}, path)
+	if err != nil || skipped != 0 {
+		t.Fatalf("readQueueFile: %d skipped, %v", skipped, err)
+	}
+	if len(events) != 1 || events[0].Type != EventTypeTraceCreate || events[0].Body["id"] != trace.ID() {
+		t.Errorf("dead-letter file holds %v, want the create of trace %s", events, trace.ID())
+	}
+}
+
+func TestUndeliveredEventsAreDeadLetteredOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead")
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = "http://127.0.0.1:1" // unreachable
+	config.FlushAt = 1000
+	config.DeadLetterPath = path
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := createTraces(t, client, 3)
+	report, _ := client.CloseWithContext(context.Background())
+	if report.DeadLettered != len(ids) || report.Abandoned != 0 {
+		t.Errorf("report = %+v, want %d dead-lettered and none abandoned", report, len(ids))
+	}
+
+	// The file can be sent as a persistent queue
+	counts := traceCreateCounts(recoverQueue(t, path))
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times from the dead-letter file, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestFailedDeadLetterWriteIsAbandoned(t *testing.T) {
+	server := newTestServer(t)
+	server.handle("POST", "/api/public/ingestion", rejectTraceCreates)
+	fs := &faultFS{}
+	client := newTestClientFS(t, server, fs, func(c *Config) {
+		c.DeadLetterPath = filepath.Join(t.TempDir(), "dead")
+	})
+
+	createTraces(t, client, 2)
+	fs.crashAt("write")
+	report, _ := client.CloseWithContext(context.Background())
+	if report.DeadLettered != 0 || report.Abandoned != 2 {
+		t.Errorf("report = %+v, want 2 abandoned", report)
+	}
+}
@@ -0,0 +1,24 @@
+package langfuse
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// warnDeprecated logs that the deprecated function name was called, naming
+// its replacement, if Config.WarnDeprecated is set. It must be called
+// directly by the deprecated function, and warns once per call site of it.
+func (c *clientCore) warnDeprecated(name, replacement string) {
+	if !c.config.WarnDeprecated {
+		return
+	}
+
+	site := "unknown location"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		site = fmt.Sprintf("%s:%d", file, line)
+	}
+	if _, warned := c.deprecations.LoadOrStore(name+"@"+site, struct{}{}); warned {
+		return
+	}
+	c.config.logger().Warnf("%s is deprecated, use %s instead (called at %s)", name, replacement, site)
+}
@@ -0,0 +1,23 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// sendDryRun writes events to the logger instead of sending them, as
+// Config.DryRun asks, and reports them all as accepted
+func (c *clientCore) sendDryRun(events []Event) (*IngestionResponse, error) {
+	data, err := json.MarshalIndent(IngestionRequest{Batch: events}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	c.config.logger().Infof("Dry run, not sending %d events:\n%s", len(events), data)
+
+	resp := &IngestionResponse{Successes: make([]SuccessResult, len(events))}
+	for i, e := range events {
+		resp.Successes[i] = SuccessResult{ID: e.ID, Status: http.StatusCreated}
+	}
+	return resp, nil
+}
@@ -0,0 +1,39 @@
+package langfuse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDryRunLogsEventsWithoutSending(t *testing.T) {
+	server := newTestServer(t)
+	logger := &testLogger{}
+	client := newTestClient(t, server, func(c *Config) {
+		c.DryRun = true
+		c.PublicKey = ""
+		c.SecretKey = ""
+		c.Logger = logger
+		c.MetricsEnabled = true
+	})
+
+	ids := createTraces(t, client, 2)
+	flush(t, client)
+
+	if n := len(server.Events()); n != 0 {
+		t.Errorf("dry run sent %d events", n)
+	}
+	var logged string
+	for _, info := range logger.Infos() {
+		if strings.HasPrefix(info, "Dry run") {
+			logged += info
+		}
+	}
+	for _, id := range ids {
+		if !strings.Contains(logged, `"id": "`+id+`"`) {
+			t.Errorf("trace %s was not logged pretty-printed", id)
+		}
+	}
+	if n := client.GetMetrics().EventsSucceeded; n != int64(len(ids)) {
+		t.Errorf("EventsSucceeded = %d, want %d", n, len(ids))
+	}
+}
@@ -1,8 +1,10 @@
 package langfuse
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // LangfuseError represents a Langfuse-specific error with retry information
@@ -10,7 +12,11 @@ type LangfuseError struct {
 	Code       string
 	Message    string
 	StatusCode int
-	retryable  bool
+
+	// RetryAfter is the wait requested by the server's Retry-After header, if any
+	RetryAfter time.Duration
+
+	retryable bool
 }
 
 // Error implements the error interface
@@ -86,3 +92,13 @@ func IsRetryableError(err error) bool {
 	}
 	return false
 }
+
+// IsAuthError checks if an error is an authentication or authorization failure
+// (HTTP 401 or 403), which usually means the API keys are wrong or revoked
+func IsAuthError(err error) bool {
+	var langfuseErr *LangfuseError
+	if !errors.As(err, &langfuseErr) {
+		return false
+	}
+	return langfuseErr.StatusCode == http.StatusUnauthorized || langfuseErr.StatusCode == http.StatusForbidden
+}
@@ -1,28 +1,39 @@
 package langfuse
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 )
 
 // TraceWithFullDetails represents a trace with all nested observations
 type TraceWithFullDetails struct {
-	ID           string                `json:"id"`
-	Name         *string               `json:"name,omitempty"`
-	UserID       *string               `json:"userId,omitempty"`
-	SessionID    *string               `json:"sessionId,omitempty"`
-	Timestamp    string                `json:"timestamp"`
-	Input        interface{}           `json:"input,omitempty"`
-	Output       interface{}           `json:"output,omitempty"`
+	ID           string                 `json:"id"`
+	Name         *string                `json:"name,omitempty"`
+	UserID       *string                `json:"userId,omitempty"`
+	SessionID    *string                `json:"sessionId,omitempty"`
+	Timestamp    string                 `json:"timestamp"`
+	Input        interface{}            `json:"input,omitempty"`
+	Output       interface{}            `json:"output,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
-	Tags         []string              `json:"tags,omitempty"`
-	Observations []ObservationDetails  `json:"observations,omitempty"`
-	Scores       []ScoreData           `json:"scores,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+	Observations []ObservationDetails   `json:"observations,omitempty"`
+	Scores       []ScoreData            `json:"scores,omitempty"`
+
+	// CreatedAt is when the server stored the trace; Timestamp is when it started
+	CreatedAt *string `json:"createdAt,omitempty"`
+
+	// Latency is the trace duration in seconds and TotalCost the summed
+	// observation cost, both computed by Langfuse
+	Latency   *float64 `json:"latency,omitempty"`
+	TotalCost *float64 `json:"totalCost,omitempty"`
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for TraceWithFullDetails
@@ -59,36 +70,82 @@ func (t *TraceWithFullDetails) UnmarshalJSON(data []byte) error {
 
 // ScoreData represents a score retrieved from API
 type ScoreData struct {
-	ID            string   `json:"id"`
-	TraceID       string   `json:"traceId"`
-	ObservationID *string  `json:"observationId,omitempty"`
-	Name          string   `json:"name"`
-	Value         float64  `json:"value"`
-	Comment       *string  `json:"comment,omitempty"`
-	DataType      string   `json:"dataType"`
-	ConfigID      *string  `json:"configId,omitempty"`
-	Timestamp     string   `json:"timestamp"`
+	ID            string  `json:"id"`
+	TraceID       string  `json:"traceId"`
+	ObservationID *string `json:"observationId,omitempty"`
+	Name          string  `json:"name"`
+	Value         float64 `json:"value"`
+	Comment       *string `json:"comment,omitempty"`
+	DataType      string  `json:"dataType"`
+	ConfigID      *string `json:"configId,omitempty"`
+	Timestamp     string  `json:"timestamp"`
+
+	// StringValue is the value of a CATEGORICAL score, e.g. "positive".
+	// Value is then the category's number in the score config, or 0.
+	StringValue *string `json:"stringValue,omitempty"`
+
+	// Source is where the score came from (API, EVAL or ANNOTATION)
+	Source string `json:"source,omitempty"`
+
+	// AuthorUserID is the user who added an annotation score
+	AuthorUserID *string `json:"authorUserId,omitempty"`
+}
+
+// UnmarshalJSON decodes a score, also accepting a categorical score whose
+// "value" is its string value, as in a score-create event body, which it
+// stores in StringValue
+func (s *ScoreData) UnmarshalJSON(data []byte) error {
+	type Alias ScoreData
+	aux := struct {
+		*Alias
+		Value json.RawMessage `json:"value"`
+	}{
+		Alias: (*Alias)(s),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Value) == 0 || string(aux.Value) == "null" {
+		return nil
+	}
+	if aux.Value[0] == '"' {
+		var value string
+		if err := json.Unmarshal(aux.Value, &value); err != nil {
+			return err
+		}
+		if s.StringValue == nil {
+			s.StringValue = &value
+		}
+		return nil
+	}
+	return json.Unmarshal(aux.Value, &s.Value)
 }
 
 // ObservationDetails represents an observation (span, generation, event, tool)
 type ObservationDetails struct {
-	ID                string         `json:"id"`
-	TraceID           string         `json:"traceId"`
-	Type              string         `json:"type"` // SPAN, GENERATION, EVENT, TOOL
-	Name              *string        `json:"name,omitempty"`
-	StartTime         string         `json:"startTime"`
-	EndTime           *string        `json:"endTime,omitempty"`
-	CompletionStartTime *string      `json:"completionStartTime,omitempty"`
-	Input             interface{}    `json:"input,omitempty"`
-	Output            interface{}    `json:"output,omitempty"`
-	Metadata          map[string]interface{} `json:"metadata,omitempty"`
-	Level             *string        `json:"level,omitempty"`
-	StatusMessage     *string        `json:"statusMessage,omitempty"`
-	ParentObservationID *string      `json:"parentObservationId,omitempty"`
-	Version           *string        `json:"version,omitempty"`
-	Model             *string        `json:"model,omitempty"`
-	ModelParameters   map[string]interface{} `json:"modelParameters,omitempty"`
-	Usage             *Usage         `json:"usage,omitempty"`
+	ID                  string                 `json:"id"`
+	TraceID             string                 `json:"traceId"`
+	Type                string                 `json:"type"` // SPAN, GENERATION, EVENT, TOOL
+	Name                *string                `json:"name,omitempty"`
+	StartTime           string                 `json:"startTime"`
+	EndTime             *string                `json:"endTime,omitempty"`
+	CompletionStartTime *string                `json:"completionStartTime,omitempty"`
+	Input               interface{}            `json:"input,omitempty"`
+	Output              interface{}            `json:"output,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	Level               *string                `json:"level,omitempty"`
+	StatusMessage       *string                `json:"statusMessage,omitempty"`
+	ParentObservationID *string                `json:"parentObservationId,omitempty"`
+	Version             *string                `json:"version,omitempty"`
+	Model               *string                `json:"model,omitempty"`
+	ModelParameters     map[string]interface{} `json:"modelParameters,omitempty"`
+	Usage               *Usage                 `json:"usage,omitempty"`
+
+	// Costs calculated by Langfuse from the model price table; use RoundCost for display
+	CalculatedInputCost  *float64 `json:"calculatedInputCost,omitempty"`
+	CalculatedOutputCost *float64 `json:"calculatedOutputCost,omitempty"`
+	CalculatedTotalCost  *float64 `json:"calculatedTotalCost,omitempty"`
 }
 
 // SessionWithTraces represents a session with its traces
@@ -100,16 +157,30 @@ type SessionWithTraces struct {
 
 // PaginatedTraces represents paginated trace list response
 type PaginatedTraces struct {
-	Data       []TraceWithFullDetails `json:"data"`
-	Meta       PaginationMeta         `json:"meta"`
+	Data []TraceWithFullDetails `json:"data"`
+	Meta PaginationMeta         `json:"meta"`
+}
+
+// PaginatedScores represents paginated score list response
+type PaginatedScores struct {
+	Data []ScoreData    `json:"data"`
+	Meta PaginationMeta `json:"meta"`
+}
+
+// PaginatedTracesWithScores is a page of traces together with their scores
+type PaginatedTracesWithScores struct {
+	PaginatedTraces
+
+	// ScoresByTraceID maps each trace ID on the page to its scores
+	ScoresByTraceID map[string][]ScoreData
 }
 
 // PaginationMeta represents pagination metadata
 type PaginationMeta struct {
-	Page       int   `json:"page"`
-	Limit      int   `json:"limit"`
-	TotalItems int   `json:"totalItems"`
-	TotalPages int   `json:"totalPages"`
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	TotalItems int `json:"totalItems"`
+	TotalPages int `json:"totalPages"`
 }
 
 // GetTraceParams represents parameters for fetching a single trace
@@ -119,14 +190,40 @@ type GetTraceParams struct {
 
 // ListTracesParams represents parameters for listing traces
 type ListTracesParams struct {
-	Page      *int
-	Limit     *int
-	UserID    *string
-	Name      *string
-	SessionID *string
+	Page          *int
+	Limit         *int
+	UserID        *string
+	Name          *string
+	SessionID     *string
+	FromTimestamp *string
+	ToTimestamp   *string
+	Tags          []string
+}
+
+// ListTracesWithScoresParams represents parameters for ListTracesWithScores
+type ListTracesWithScoresParams struct {
+	ListTracesParams
+
+	// Concurrency is the maximum number of concurrent score requests (default: 5)
+	Concurrency int
+}
+
+// ListScoresParams represents parameters for listing scores
+type ListScoresParams struct {
+	Page          *int
+	Limit         *int
+	Name          *string
+	UserID        *string
+	TraceID       *string
+	DataType      *string
+	Source        *string
 	FromTimestamp *string
 	ToTimestamp   *string
-	Tags      []string
+
+	// Value filters numeric scores together with Operator,
+	// e.g. Operator ">=" and Value 0.5
+	Value    *float64
+	Operator *string
 }
 
 // GetSessionParams represents parameters for fetching a session
@@ -144,7 +241,7 @@ func (c *Client) GetTrace(ctx context.Context, params GetTraceParams) (*TraceWit
 		return nil, fmt.Errorf("traceID is required")
 	}
 
-	url := fmt.Sprintf("%s/api/public/traces/%s", c.config.BaseURL, params.TraceID)
+	url := fmt.Sprintf("%s/api/public/traces/%s", c.config.BaseURL, c.serverTraceID(params.TraceID))
 
 	trace, err := c.fetchJSON(ctx, url, &TraceWithFullDetails{})
 	if err != nil {
@@ -154,6 +251,46 @@ func (c *Client) GetTrace(ctx context.Context, params GetTraceParams) (*TraceWit
 	return trace.(*TraceWithFullDetails), nil
 }
 
+// TraceResult is the result of GetTraceAsync
+type TraceResult struct {
+	TraceID string
+	Trace   *TraceWithFullDetails
+	Err     error
+}
+
+// GetTraceAsync fetches a trace in the background, like GetTrace. The returned
+// channel yields exactly one result and is then closed. It is buffered, so the
+// fetch finishes even if the result is never received; cancel ctx to stop it.
+func (c *Client) GetTraceAsync(ctx context.Context, traceID string) <-chan TraceResult {
+	results := make(chan TraceResult, 1)
+	go func() {
+		defer close(results)
+		trace, err := c.GetTrace(ctx, GetTraceParams{TraceID: traceID})
+		results <- TraceResult{TraceID: traceID, Trace: trace, Err: err}
+	}()
+	return results
+}
+
+// GetObservation retrieves a single observation by ID
+func (c *Client) GetObservation(ctx context.Context, observationID string) (*ObservationDetails, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if observationID == "" {
+		return nil, fmt.Errorf("observationID is required")
+	}
+
+	url := fmt.Sprintf("%s/api/public/observations/%s", c.config.BaseURL, c.serverObservationID(observationID))
+
+	observation, err := c.fetchJSON(ctx, url, &ObservationDetails{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get observation: %w", err)
+	}
+
+	return observation.(*ObservationDetails), nil
+}
+
 // ListTraces retrieves a paginated list of traces
 func (c *Client) ListTraces(ctx context.Context, params ListTracesParams) (*PaginatedTraces, error) {
 	if !c.config.Enabled {
@@ -201,6 +338,182 @@ func (c *Client) ListTraces(ctx context.Context, params ListTracesParams) (*Pagi
 	return traces.(*PaginatedTraces), nil
 }
 
+// IterateTraces iterates over all traces matching params, fetching the next page
+// as needed, starting at params.Page (default: 1). Iteration stops after the
+// last page, when ctx is done, or after yielding the first error.
+//
+//	for trace, err := range client.IterateTraces(ctx, params) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) IterateTraces(ctx context.Context, params ListTracesParams) iter.Seq2[*TraceWithFullDetails, error] {
+	return func(yield func(*TraceWithFullDetails, error) bool) {
+		page := 1
+		if params.Page != nil {
+			page = *params.Page
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			params.Page = &page
+			traces, err := c.ListTraces(ctx, params)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for i := range traces.Data {
+				if !yield(&traces.Data[i], nil) {
+					return
+				}
+			}
+
+			if page >= traces.Meta.TotalPages || len(traces.Data) == 0 {
+				return
+			}
+			page++
+		}
+	}
+}
+
+// ListTracesWithScores retrieves a page of traces and then fetches the scores
+// of every trace on the page concurrently
+func (c *Client) ListTracesWithScores(ctx context.Context, params ListTracesWithScoresParams) (*PaginatedTracesWithScores, error) {
+	traces, err := c.ListTraces(ctx, params.ListTracesParams)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := params.Concurrency
+	if concurrency <= 0 {
+		concurrency = 5
+	}
+
+	result := &PaginatedTracesWithScores{
+		PaginatedTraces: *traces,
+		ScoresByTraceID: make(map[string][]ScoreData, len(traces.Data)),
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	for _, trace := range traces.Data {
+		wg.Add(1)
+		go func(traceID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			scores, err := c.listTraceScores(ctx, traceID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			result.ScoresByTraceID[traceID] = scores
+		}(trace.ID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("failed to list trace scores: %w", firstErr)
+	}
+
+	return result, nil
+}
+
+// ListScores retrieves a paginated list of scores
+func (c *Client) ListScores(ctx context.Context, params ListScoresParams) (*PaginatedScores, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if (params.Value == nil) != (params.Operator == nil) {
+		return nil, fmt.Errorf("value and operator must be set together")
+	}
+
+	baseURL := fmt.Sprintf("%s/api/public/scores", c.config.BaseURL)
+	queryParams := url.Values{}
+
+	if params.Page != nil {
+		queryParams.Set("page", strconv.Itoa(*params.Page))
+	}
+	if params.Limit != nil {
+		queryParams.Set("limit", strconv.Itoa(*params.Limit))
+	}
+	if params.Name != nil {
+		queryParams.Set("name", *params.Name)
+	}
+	if params.UserID != nil {
+		queryParams.Set("userId", *params.UserID)
+	}
+	if params.TraceID != nil {
+		queryParams.Set("traceId", c.serverTraceID(*params.TraceID))
+	}
+	if params.DataType != nil {
+		queryParams.Set("dataType", *params.DataType)
+	}
+	if params.Source != nil {
+		queryParams.Set("source", *params.Source)
+	}
+	if params.FromTimestamp != nil {
+		queryParams.Set("fromTimestamp", *params.FromTimestamp)
+	}
+	if params.ToTimestamp != nil {
+		queryParams.Set("toTimestamp", *params.ToTimestamp)
+	}
+	if params.Value != nil {
+		queryParams.Set("value", strconv.FormatFloat(*params.Value, 'f', -1, 64))
+		queryParams.Set("operator", *params.Operator)
+	}
+
+	fullURL := baseURL
+	if len(queryParams) > 0 {
+		fullURL = baseURL + "?" + queryParams.Encode()
+	}
+
+	scores, err := c.fetchJSON(ctx, fullURL, &PaginatedScores{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scores: %w", err)
+	}
+
+	return scores.(*PaginatedScores), nil
+}
+
+// listTraceScores retrieves all scores of a trace, following pagination
+func (c *Client) listTraceScores(ctx context.Context, traceID string) ([]ScoreData, error) {
+	var scores []ScoreData
+	for page := 1; ; page++ {
+		paginated, err := c.ListScores(ctx, ListScoresParams{
+			Page:    &page,
+			Limit:   ptr(100),
+			TraceID: &traceID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		scores = append(scores, paginated.Data...)
+		if page >= paginated.Meta.TotalPages || len(paginated.Data) == 0 {
+			return scores, nil
+		}
+	}
+}
+
 // GetSession retrieves a session with all its traces
 func (c *Client) GetSession(ctx context.Context, params GetSessionParams) (*SessionWithTraces, error) {
 	if !c.config.Enabled {
@@ -223,18 +536,58 @@ func (c *Client) GetSession(ctx context.Context, params GetSessionParams) (*Sess
 
 // fetchJSON is a helper method to make GET requests and parse JSON responses
 func (c *Client) fetchJSON(ctx context.Context, url string, target interface{}) (interface{}, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	return c.doJSON(ctx, "GET", url, nil, target)
+}
+
+// doJSON is a helper method to make requests with an optional JSON payload
+// and parse JSON responses into target
+func (c *Client) doJSON(ctx context.Context, method, url string, payload interface{}, target interface{}) (interface{}, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	// Only idempotent requests are retried, see the retry policy in retry.go
+	idempotent := isIdempotentMethod(method)
+	for retries := 0; ; retries++ {
+		result, err := c.doJSONOnce(ctx, method, url, data, target)
+		if err == nil || !shouldRetry(err, idempotent) || retries >= c.config.SyncMaxRetryAttempts {
+			return result, err
+		}
+
+		if c.config.MetricsEnabled {
+			c.metrics.RecordRetry()
+		}
+		if err := sleepContext(ctx, max(syncRetryDelay(c.config, retries+1), retryAfter(err))); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doJSONOnce makes a single JSON API request with an already encoded payload
+func (c *Client) doJSONOnce(ctx context.Context, method, url string, data []byte, target interface{}) (interface{}, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", c.makeAuthHeader())
 	req.Header.Set("Accept", "application/json")
-
-	if c.config.Debug {
-		fmt.Printf("[Langfuse] GET %s\n", url)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
+	c.config.logger().Debugf("%s %s", method, url)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, NewNetworkError(err)
@@ -246,17 +599,17 @@ func (c *Client) fetchJSON(ctx context.Context, url string, target interface{})
 		return nil, NewNetworkError(err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, NewHTTPError(resp.StatusCode, string(body))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newResponseError(resp, body)
 	}
 
-	if err := json.Unmarshal(body, target); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if target != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, target); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
 	}
 
-	if c.config.Debug {
-		fmt.Printf("[Langfuse] Successfully fetched data from %s\n", url)
-	}
+	c.config.logger().Debugf("Successfully fetched data from %s", url)
 
 	return target, nil
 }
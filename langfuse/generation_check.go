@@ -0,0 +1,87 @@
+package langfuse
+
+import (
+	"sync"
+)
+
+// maxTrackedGenerations bounds the number of open generations tracked for
+// WarnIncompleteGenerations; the oldest are forgotten first
+const maxTrackedGenerations = 10000
+
+// generationFields records which analytics fields a generation has been sent with
+type generationFields struct {
+	model bool
+	usage bool
+}
+
+// generationTracker remembers open generations so a warning can be logged
+// when one ends without a model or usage (Config.WarnIncompleteGenerations)
+type generationTracker struct {
+	mu     sync.Mutex
+	fields map[string]generationFields
+	order  []string // generation IDs in creation order, for eviction
+	logger Logger
+}
+
+func newGenerationTracker(logger Logger) *generationTracker {
+	return &generationTracker{fields: make(map[string]generationFields), logger: logger}
+}
+
+// created records a new generation and checks it if it was created already ended
+func (g *generationTracker) created(id string, params GenerationParams) {
+	fields := generationFields{model: params.Model != nil, usage: params.Usage != nil}
+	if params.EndTime != nil {
+		g.warnIfIncomplete(id, fields)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.order) >= maxTrackedGenerations {
+		delete(g.fields, g.order[0])
+		g.order = g.order[1:]
+	}
+	g.fields[id] = fields
+	g.order = append(g.order, id)
+}
+
+// updated merges an update into a tracked generation and checks it when it ends.
+// Generations that are not tracked (created elsewhere or evicted) are ignored.
+func (g *generationTracker) updated(id string, params GenerationParams) {
+	g.mu.Lock()
+	fields, ok := g.fields[id]
+	if !ok {
+		g.mu.Unlock()
+		return
+	}
+	fields.model = fields.model || params.Model != nil
+	fields.usage = fields.usage || params.Usage != nil
+	if params.EndTime == nil {
+		g.fields[id] = fields
+		g.mu.Unlock()
+		return
+	}
+	delete(g.fields, id)
+	for i, tracked := range g.order {
+		if tracked == id {
+			g.order = append(g.order[:i], g.order[i+1:]...)
+			break
+		}
+	}
+	g.mu.Unlock()
+
+	g.warnIfIncomplete(id, fields)
+}
+
+// warnIfIncomplete logs a warning if a generation ended without a model or usage
+func (g *generationTracker) warnIfIncomplete(id string, fields generationFields) {
+	switch {
+	case !fields.model && !fields.usage:
+		g.logger.Warnf("generation %s ended without Model and Usage; it will be missing from cost analytics", id)
+	case !fields.model:
+		g.logger.Warnf("generation %s ended without Model; it will be missing from cost analytics", id)
+	case !fields.usage:
+		g.logger.Warnf("generation %s ended without Usage; it will be missing from cost analytics", id)
+	}
+}
@@ -0,0 +1,65 @@
+package langfuse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWarnIncompleteGenerations(t *testing.T) {
+	server := newTestServer(t)
+	logger := &testLogger{}
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = logger
+		c.WarnIncompleteGenerations = true
+	})
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incomplete, err := trace.StartGeneration(NewGeneration("chat", WithModel("gpt-4o")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete, err := trace.StartGeneration(NewGeneration("chat", WithModel("gpt-4o")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := incomplete.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := complete.EndWithParams(NewGeneration("", WithUsage(Usage{Output: Ptr(3)}))); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := logger.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %q, want one for the generation without usage", warnings)
+	}
+	if !strings.Contains(warnings[0], incomplete.ObservationID()) || !strings.Contains(warnings[0], "without Usage") {
+		t.Errorf("warning = %q, want it to name generation %s and the missing Usage", warnings[0], incomplete.ObservationID())
+	}
+}
+
+func TestIncompleteGenerationsAreNotCheckedByDefault(t *testing.T) {
+	server := newTestServer(t)
+	logger := &testLogger{}
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = logger
+	})
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := trace.StartGeneration(NewGeneration("chat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	if warnings := logger.Warnings(); len(warnings) != 0 {
+		t.Errorf("got warnings %q without WarnIncompleteGenerations", warnings)
+	}
+}
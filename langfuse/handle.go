@@ -0,0 +1,466 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAlreadyEnded is returned by the End methods of traces and observation
+// handles that were already ended
+var ErrAlreadyEnded = errors.New("langfuse: already ended")
+
+// observationHandle holds the identity shared by all observation handles
+type observationHandle struct {
+	client  *Client
+	trace   *Trace
+	traceID string
+	id      string
+	obsType ObservationType
+
+	attachments *attachmentList
+}
+
+// handle returns the identity of an observation created in the trace and
+// records it as open until the handle is ended
+func (t *Trace) handle(id string, obsType ObservationType) observationHandle {
+	t.mu.Lock()
+	if t.open == nil {
+		t.open = make(map[string]ObservationType)
+	}
+	t.open[id] = obsType
+	t.mu.Unlock()
+
+	return observationHandle{
+		client:      t.client,
+		trace:       t,
+		traceID:     t.id,
+		id:          id,
+		obsType:     obsType,
+		attachments: &attachmentList{},
+	}
+}
+
+// ObservationID returns the ID of the observation. With TransportOTLP it is
+// stored under OTLPSpanID of it, which Client.GetObservation maps it to.
+func (h *observationHandle) ObservationID() string {
+	return h.id
+}
+
+// TraceID returns the ID of the trace the observation belongs to
+func (h *observationHandle) TraceID() string {
+	return h.traceID
+}
+
+// ending marks the observation as ended before its end is sent, so that only
+// one End succeeds, or returns ErrAlreadyEnded if it already was
+func (h *observationHandle) ending() error {
+	h.trace.mu.Lock()
+	defer h.trace.mu.Unlock()
+	if _, open := h.trace.open[h.id]; !open {
+		return ErrAlreadyEnded
+	}
+	delete(h.trace.open, h.id)
+	return nil
+}
+
+// ended marks the observation as open again if sending its end failed with
+// err, so End can be retried, and returns err
+func (h *observationHandle) ended(err error) error {
+	if err == nil {
+		return nil
+	}
+	h.trace.mu.Lock()
+	if h.trace.open == nil {
+		h.trace.open = make(map[string]ObservationType)
+	}
+	h.trace.open[h.id] = h.obsType
+	h.trace.mu.Unlock()
+	return err
+}
+
+// CreateChildSpan creates a span nested under this observation, setting its
+// ParentObservationID, and returns a handle to it
+func (h *observationHandle) CreateChildSpan(params SpanParams) (*SpanHandle, error) {
+	params.ParentObservationID = &h.id
+	return h.trace.StartSpan(params)
+}
+
+// CreateChildGeneration creates a generation nested under this observation,
+// setting its ParentObservationID, and returns a handle to it
+func (h *observationHandle) CreateChildGeneration(params GenerationParams) (*GenerationHandle, error) {
+	params.ParentObservationID = &h.id
+	return h.trace.StartGeneration(params)
+}
+
+// CreateChildTool creates a tool observation nested under this observation,
+// setting its ParentObservationID, and returns a handle to it
+func (h *observationHandle) CreateChildTool(params ToolParams) (*ToolHandle, error) {
+	params.ParentObservationID = &h.id
+	return h.trace.StartTool(params)
+}
+
+// CreateChildAgent creates an agent observation nested under this observation,
+// setting its ParentObservationID, and returns a handle to it
+func (h *observationHandle) CreateChildAgent(params AgentParams) (*AgentHandle, error) {
+	params.ParentObservationID = &h.id
+	return h.trace.StartAgent(params)
+}
+
+// CreateChildEvaluator creates an evaluator observation nested under this
+// observation, setting its ParentObservationID, and returns a handle to it
+func (h *observationHandle) CreateChildEvaluator(params EvaluatorParams) (*EvaluatorHandle, error) {
+	params.ParentObservationID = &h.id
+	return h.trace.StartEvaluator(params)
+}
+
+// endTime returns the given end time, or now if it is not set
+func endTime(t *time.Time) *time.Time {
+	if t != nil {
+		return t
+	}
+	now := time.Now()
+	return &now
+}
+
+// SpanHandle is a handle to a span created with Trace.StartSpan
+type SpanHandle struct {
+	observationHandle
+}
+
+// StartSpan creates a new span observation and returns a handle to it
+func (t *Trace) StartSpan(params SpanParams) (*SpanHandle, error) {
+	return t.startSpan(params, t.client.enqueue)
+}
+
+// StartSpanWithContext is like StartSpan, but if the queue is full it waits
+// for space until ctx is done instead of dropping the span
+func (t *Trace) StartSpanWithContext(ctx context.Context, params SpanParams) (*SpanHandle, error) {
+	return t.startSpan(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) startSpan(params SpanParams, enqueue func(Event) error) (*SpanHandle, error) {
+	id, err := t.createSpan(params, enqueue)
+	if err != nil {
+		return nil, err
+	}
+	return &SpanHandle{observationHandle: t.handle(id, ObservationTypeSpan)}, nil
+}
+
+// Update updates the span
+func (h *SpanHandle) Update(params SpanParams) error {
+	return h.client.UpdateSpan(h.id, params)
+}
+
+// End sets the span's EndTime to now
+func (h *SpanHandle) End() error {
+	return h.EndWithParams(SpanParams{})
+}
+
+// EndWithParams updates the span with params and sets EndTime to now
+// unless params.EndTime is already set. Only the first End or EndWithParams
+// that succeeds sends anything; later calls return ErrAlreadyEnded.
+func (h *SpanHandle) EndWithParams(params SpanParams) error {
+	if err := h.ending(); err != nil {
+		return err
+	}
+	params.EndTime = endTime(params.EndTime)
+	return h.ended(h.Update(params))
+}
+
+// MetadataKeyUsageChunks is the generation metadata key holding the number of
+// streaming chunks whose usage was recorded with RecordChunkUsage
+const MetadataKeyUsageChunks = "usageChunks"
+
+// GenerationHandle is a handle to a generation created with Trace.StartGeneration
+type GenerationHandle struct {
+	observationHandle
+
+	mu           sync.Mutex
+	chunkUsage   Usage
+	chunks       int
+	firstChunk   time.Time
+	outputSchema interface{}
+}
+
+// StartGeneration creates a new generation observation and returns a handle to it
+func (t *Trace) StartGeneration(params GenerationParams) (*GenerationHandle, error) {
+	return t.startGeneration(params, t.client.enqueue)
+}
+
+// StartGenerationWithContext is like StartGeneration, but if the queue is
+// full it waits for space until ctx is done instead of dropping the generation
+func (t *Trace) StartGenerationWithContext(ctx context.Context, params GenerationParams) (*GenerationHandle, error) {
+	return t.startGeneration(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) startGeneration(params GenerationParams, enqueue func(Event) error) (*GenerationHandle, error) {
+	id, err := t.createGeneration(params, enqueue)
+	if err != nil {
+		return nil, err
+	}
+	return &GenerationHandle{
+		observationHandle: t.handle(id, ObservationTypeGeneration),
+		outputSchema:      params.OutputSchema,
+	}, nil
+}
+
+// Update updates the generation
+func (h *GenerationHandle) Update(params GenerationParams) error {
+	if params.OutputSchema != nil {
+		h.mu.Lock()
+		h.outputSchema = params.OutputSchema
+		h.mu.Unlock()
+	}
+	return h.client.UpdateGeneration(h.id, params)
+}
+
+// RecordChunkUsage records the incremental usage of one streaming chunk.
+// Nothing is sent per chunk: chunk usages are summed in the handle and only
+// sent as the generation's usage by End or EndWithParams, so a generation
+// that is never ended loses them. End it even when the stream fails, e.g.
+// with defer. The first recorded chunk also marks the CompletionStartTime.
+func (h *GenerationHandle) RecordChunkUsage(delta Usage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chunks == 0 {
+		h.firstChunk = time.Now()
+	}
+	h.chunks++
+	addUsage(&h.chunkUsage, delta)
+}
+
+// End sets the generation's EndTime to now
+func (h *GenerationHandle) End() error {
+	return h.EndWithParams(GenerationParams{})
+}
+
+// EndWithParams updates the generation with params and sets EndTime to now
+// unless params.EndTime is already set.
+// If chunk usage was recorded and params.Usage is nil, the aggregated chunk usage
+// is sent. An explicit params.Usage is treated as the final total and replaces the
+// chunk aggregate rather than being added to it, so it is never double-counted.
+// With Config.ValidateOutputSchema, params.Output is checked against the
+// generation's OutputSchema.
+func (h *GenerationHandle) EndWithParams(params GenerationParams) error {
+	if err := h.ending(); err != nil {
+		return err
+	}
+	params.EndTime = endTime(params.EndTime)
+
+	h.mu.Lock()
+	if h.chunks > 0 {
+		if params.Usage == nil {
+			var usage Usage
+			addUsage(&usage, h.chunkUsage) // copy so later chunks don't alter what was sent
+			params.Usage = &usage
+		}
+		if params.CompletionStartTime == nil {
+			firstChunk := h.firstChunk
+			params.CompletionStartTime = &firstChunk
+		}
+		metadata := make(map[string]interface{}, len(params.Metadata)+1)
+		for k, v := range params.Metadata {
+			metadata[k] = v
+		}
+		metadata[MetadataKeyUsageChunks] = h.chunks
+		params.Metadata = metadata
+	}
+	schema := h.outputSchema
+	h.mu.Unlock()
+
+	if !h.client.config.ValidateOutputSchema || schema == nil || params.Output == nil {
+		return h.ended(h.Update(params))
+	}
+
+	validationErr := ValidateJSONSchema(schema, params.Output)
+	if validationErr != nil {
+		h.client.config.logger().Debugf("Output of generation %s does not match its schema: %v", h.id, validationErr)
+	}
+	valid := validationErr == nil
+
+	metadata := make(map[string]interface{}, len(params.Metadata)+1)
+	for k, v := range params.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataKeySchemaValid] = valid
+	params.Metadata = metadata
+
+	if err := h.ended(h.Update(params)); err != nil {
+		return err
+	}
+	if name := h.client.config.OutputSchemaScoreName; name != "" {
+		value := 0.0
+		if valid {
+			value = 1
+		}
+		return h.Score(name, value, WithDataType(ScoreDataTypeBoolean))
+	}
+	return nil
+}
+
+// addUsage adds the token counts and costs of delta to total
+func addUsage(total *Usage, delta Usage) {
+	addInt := func(dst **int, v *int) {
+		if v == nil {
+			return
+		}
+		if *dst == nil {
+			*dst = ptr(0)
+		}
+		**dst += *v
+	}
+	addFloat := func(dst **float64, v *float64) {
+		if v == nil {
+			return
+		}
+		if *dst == nil {
+			*dst = ptr(0.0)
+		}
+		**dst += *v
+	}
+
+	addInt(&total.Input, delta.Input)
+	addInt(&total.Output, delta.Output)
+	addInt(&total.Total, delta.Total)
+	addFloat(&total.InputCost, delta.InputCost)
+	addFloat(&total.OutputCost, delta.OutputCost)
+	addFloat(&total.TotalCost, delta.TotalCost)
+	if total.Unit == nil {
+		total.Unit = delta.Unit
+	}
+}
+
+// ToolHandle is a handle to a tool observation created with Trace.StartTool
+type ToolHandle struct {
+	observationHandle
+}
+
+// StartTool creates a new tool observation and returns a handle to it
+func (t *Trace) StartTool(params ToolParams) (*ToolHandle, error) {
+	return t.startTool(params, t.client.enqueue)
+}
+
+// StartToolWithContext is like StartTool, but if the queue is full it waits
+// for space until ctx is done instead of dropping the tool observation
+func (t *Trace) StartToolWithContext(ctx context.Context, params ToolParams) (*ToolHandle, error) {
+	return t.startTool(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) startTool(params ToolParams, enqueue func(Event) error) (*ToolHandle, error) {
+	id, err := t.createTool(params, enqueue)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolHandle{observationHandle: t.handle(id, ObservationTypeTool)}, nil
+}
+
+// Update updates the tool observation
+func (h *ToolHandle) Update(params ToolParams) error {
+	return h.client.UpdateTool(h.id, params)
+}
+
+// End sets the tool observation's EndTime to now
+func (h *ToolHandle) End() error {
+	return h.EndWithParams(ToolParams{})
+}
+
+// EndWithParams updates the tool observation with params and sets EndTime to now
+// unless params.EndTime is already set
+func (h *ToolHandle) EndWithParams(params ToolParams) error {
+	if err := h.ending(); err != nil {
+		return err
+	}
+	params.EndTime = endTime(params.EndTime)
+	return h.ended(h.Update(params))
+}
+
+// AgentHandle is a handle to an agent observation created with Trace.StartAgent
+type AgentHandle struct {
+	observationHandle
+}
+
+// StartAgent creates a new agent observation and returns a handle to it
+func (t *Trace) StartAgent(params AgentParams) (*AgentHandle, error) {
+	return t.startAgent(params, t.client.enqueue)
+}
+
+// StartAgentWithContext is like StartAgent, but if the queue is full it waits
+// for space until ctx is done instead of dropping the agent observation
+func (t *Trace) StartAgentWithContext(ctx context.Context, params AgentParams) (*AgentHandle, error) {
+	return t.startAgent(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) startAgent(params AgentParams, enqueue func(Event) error) (*AgentHandle, error) {
+	id, err := t.createAgent(params, enqueue)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentHandle{observationHandle: t.handle(id, ObservationTypeAgent)}, nil
+}
+
+// Update updates the agent observation
+func (h *AgentHandle) Update(params AgentParams) error {
+	return h.client.UpdateObservation(h.id, ObservationTypeAgent, params.SpanParams)
+}
+
+// End sets the agent observation's EndTime to now
+func (h *AgentHandle) End() error {
+	return h.EndWithParams(AgentParams{})
+}
+
+// EndWithParams updates the agent observation with params and sets EndTime to
+// now unless params.EndTime is already set
+func (h *AgentHandle) EndWithParams(params AgentParams) error {
+	if err := h.ending(); err != nil {
+		return err
+	}
+	params.EndTime = endTime(params.EndTime)
+	return h.ended(h.Update(params))
+}
+
+// EvaluatorHandle is a handle to an evaluator observation created with Trace.StartEvaluator
+type EvaluatorHandle struct {
+	observationHandle
+}
+
+// StartEvaluator creates a new evaluator observation and returns a handle to it
+func (t *Trace) StartEvaluator(params EvaluatorParams) (*EvaluatorHandle, error) {
+	return t.startEvaluator(params, t.client.enqueue)
+}
+
+// StartEvaluatorWithContext is like StartEvaluator, but if the queue is full it waits
+// for space until ctx is done instead of dropping the evaluator observation
+func (t *Trace) StartEvaluatorWithContext(ctx context.Context, params EvaluatorParams) (*EvaluatorHandle, error) {
+	return t.startEvaluator(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) startEvaluator(params EvaluatorParams, enqueue func(Event) error) (*EvaluatorHandle, error) {
+	id, err := t.createEvaluator(params, enqueue)
+	if err != nil {
+		return nil, err
+	}
+	return &EvaluatorHandle{observationHandle: t.handle(id, ObservationTypeEvaluator)}, nil
+}
+
+// Update updates the evaluator observation
+func (h *EvaluatorHandle) Update(params EvaluatorParams) error {
+	return h.client.UpdateEvaluator(h.id, params)
+}
+
+// End sets the evaluator observation's EndTime to now
+func (h *EvaluatorHandle) End() error {
+	return h.EndWithParams(EvaluatorParams{})
+}
+
+// EndWithParams updates the evaluator observation with params and sets EndTime
+// to now unless params.EndTime is already set
+func (h *EvaluatorHandle) EndWithParams(params EvaluatorParams) error {
+	if err := h.ending(); err != nil {
+		return err
+	}
+	params.EndTime = endTime(params.EndTime)
+	return h.ended(h.Update(params))
+}
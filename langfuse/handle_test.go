@@ -0,0 +1,173 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecordChunkUsageIsSentOnEnd(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := trace.StartGeneration(NewGeneration("stream"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		gen.RecordChunkUsage(Usage{Output: Ptr(5), OutputCost: Ptr(0.001)})
+	}
+
+	flush(t, client)
+	if updates := server.eventsOfType(EventTypeGenerationUpdate); len(updates) != 0 {
+		t.Fatalf("chunk usage was sent before End: %v", updates)
+	}
+
+	if err := gen.End(); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := server.eventsOfType(EventTypeGenerationUpdate)
+	if len(updates) != 1 {
+		t.Fatalf("got %d generation updates, want 1", len(updates))
+	}
+	body := updates[0].Body
+	usage, _ := body["usage"].(map[string]interface{})
+	if usage["output"] != 15.0 {
+		t.Errorf("usage output = %v, want 15", usage["output"])
+	}
+	if cost, _ := usage["outputCost"].(float64); cost < 0.00299 || cost > 0.00301 {
+		t.Errorf("usage outputCost = %v, want 0.003", usage["outputCost"])
+	}
+	metadata, _ := body["metadata"].(map[string]interface{})
+	if metadata[MetadataKeyUsageChunks] != 3.0 {
+		t.Errorf("metadata %s = %v, want 3", MetadataKeyUsageChunks, metadata[MetadataKeyUsageChunks])
+	}
+	if body["completionStartTime"] == nil {
+		t.Error("completionStartTime was not set from the first chunk")
+	}
+}
+
+func TestEndWithParamsUsageReplacesChunkUsage(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, _ := client.CreateTrace(TraceParams{})
+	gen, err := trace.StartGeneration(GenerationParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.RecordChunkUsage(Usage{Output: Ptr(5)})
+	gen.RecordChunkUsage(Usage{Output: Ptr(5)})
+
+	if err := gen.EndWithParams(GenerationParams{Usage: &Usage{Output: Ptr(12)}}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := server.eventsOfType(EventTypeGenerationUpdate)
+	if len(updates) != 1 {
+		t.Fatalf("got %d generation updates, want 1", len(updates))
+	}
+	usage, _ := updates[0].Body["usage"].(map[string]interface{})
+	if usage["output"] != 12.0 {
+		t.Errorf("usage output = %v, want the explicit 12", usage["output"])
+	}
+}
+
+func TestStartWithContextWaitsForSpace(t *testing.T) {
+	starts := map[string]func(*Trace, context.Context) error{
+		"StartToolWithContext": func(trace *Trace, ctx context.Context) error {
+			_, err := trace.StartToolWithContext(ctx, NewTool("search"))
+			return err
+		},
+		"StartAgentWithContext": func(trace *Trace, ctx context.Context) error {
+			_, err := trace.StartAgentWithContext(ctx, NewAgent("planner"))
+			return err
+		},
+		"StartEvaluatorWithContext": func(trace *Trace, ctx context.Context) error {
+			_, err := trace.StartEvaluatorWithContext(ctx, NewEvaluator("judge"))
+			return err
+		},
+	}
+	for name, start := range starts {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, newTestServer(t), func(c *Config) {
+				c.MaxQueueSize = 1
+			})
+			trace, err := client.CreateTrace(TraceParams{}) // fills the queue
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := start(trace, ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("%s on a full queue = %v, want the context's error", name, err)
+			}
+
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				client.Flush(context.Background())
+			}()
+			if err := start(trace, context.Background()); err != nil {
+				t.Errorf("%s after a flush made room = %v", name, err)
+			}
+		})
+	}
+}
+
+func TestChildHandlesChainParentObservationID(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := trace.StartAgent(NewAgent("planner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	span, err := agent.CreateChildSpan(NewSpan("step"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := span.CreateChildGeneration(NewGeneration("answer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := gen.CreateChildTool(NewTool("search"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	parents := map[string]interface{}{}
+	for _, e := range server.Events() {
+		if id, ok := e.Body["id"].(string); ok && e.Type != EventTypeTraceCreate {
+			parents[id] = e.Body["parentObservationId"]
+		}
+	}
+	chain := []struct {
+		child, parent string
+	}{
+		{span.ObservationID(), agent.ObservationID()},
+		{gen.ObservationID(), span.ObservationID()},
+		{tool.ObservationID(), gen.ObservationID()},
+	}
+	for _, link := range chain {
+		if parents[link.child] != link.parent {
+			t.Errorf("observation %s has parentObservationId %v, want %s", link.child, parents[link.child], link.parent)
+		}
+	}
+	if parent, ok := parents[agent.ObservationID()]; !ok || parent != nil {
+		t.Errorf("root observation has parentObservationId %v, want none", parent)
+	}
+}
@@ -0,0 +1,112 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Defaults of the IsHealthy thresholds
+const (
+	// DefaultHealthWindow is the default HealthWindow
+	DefaultHealthWindow = 10
+
+	// DefaultHealthMinSuccessRate is the default HealthMinSuccessRate
+	DefaultHealthMinSuccessRate = 0.5
+)
+
+// healthTracker remembers whether the most recent ingestion requests succeeded
+type healthTracker struct {
+	mu       sync.Mutex
+	outcomes []bool // ring buffer of the last len(outcomes) requests
+	next     int
+	count    int
+}
+
+func newHealthTracker(window int) *healthTracker {
+	if window <= 0 {
+		window = DefaultHealthWindow
+	}
+	return &healthTracker{outcomes: make([]bool, window)}
+}
+
+// record records the outcome of one ingestion request
+func (h *healthTracker) record(ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.outcomes[h.next] = ok
+	h.next = (h.next + 1) % len(h.outcomes)
+	if h.count < len(h.outcomes) {
+		h.count++
+	}
+}
+
+// successRate returns the fraction of the remembered requests that succeeded,
+// and how many requests are remembered
+func (h *healthTracker) successRate() (rate float64, requests int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return 1, 0
+	}
+	succeeded := 0
+	for _, ok := range h.outcomes[:h.count] {
+		if ok {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(h.count), h.count
+}
+
+// healthMinSuccessRate returns HealthMinSuccessRate, or the default if it is not set
+func (c *Config) healthMinSuccessRate() float64 {
+	if c.HealthMinSuccessRate > 0 {
+		return c.HealthMinSuccessRate
+	}
+	return DefaultHealthMinSuccessRate
+}
+
+// IsHealthy reports whether the client is delivering events, e.g. for a
+// readiness probe. It is healthy unless it is closed, was disabled by
+// DisableOnAuthError, or fewer than Config.HealthMinSuccessRate of its last
+// Config.HealthWindow ingestion requests succeeded. A request counts as
+// succeeded when the API accepted it, even if it rejected some of its events.
+// A client that has not sent anything yet, or is not Enabled, is healthy.
+func (c *Client) IsHealthy() bool {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+
+	if closed || c.authDisabled.Load() {
+		return false
+	}
+	if !c.config.Enabled {
+		return true
+	}
+	rate, _ := c.health.successRate()
+	return rate >= c.config.healthMinSuccessRate()
+}
+
+// HealthCheck makes a cheap authenticated request to the API to confirm that
+// it is reachable and accepts the configured keys, e.g. to fail fast at
+// startup rather than losing the first batches. Wrong or revoked keys give a
+// *LangfuseError for which IsAuthError is true. With Config.DryRun set,
+// nothing is sent and HealthCheck returns nil.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if !c.config.Enabled {
+		return fmt.Errorf("client is disabled")
+	}
+	if c.config.DryRun {
+		return nil
+	}
+
+	// The projects endpoint returns the project of the keys, so it both
+	// reaches the API and checks them, unlike the unauthenticated health endpoint
+	fullURL := c.config.BaseURL + "/api/public/projects"
+	if _, err := c.doJSON(ctx, "GET", fullURL, nil, nil); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
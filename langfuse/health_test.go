@@ -0,0 +1,93 @@
+package langfuse
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsHealthyFollowsRecentFlushes(t *testing.T) {
+	server := newTestServer(t)
+	var failing atomic.Bool
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ingest(w, r)
+	})
+	client := newTestClient(t, server, func(c *Config) {
+		c.HealthWindow = 4
+		c.HealthMinSuccessRate = 0.5
+		c.MaxRetryAttempts = 0
+	})
+	send := func() {
+		createTraces(t, client, 1)
+		client.Flush(context.Background())
+	}
+
+	if !client.IsHealthy() {
+		t.Error("a client that has sent nothing is not healthy")
+	}
+
+	failing.Store(true)
+	for i := 0; i < 3; i++ {
+		send()
+	}
+	if client.IsHealthy() {
+		t.Error("healthy after 3 of 3 requests failed")
+	}
+
+	failing.Store(false)
+	for i := 0; i < 2; i++ {
+		send()
+	}
+	if !client.IsHealthy() {
+		t.Error("not healthy with 2 of the last 4 requests succeeded")
+	}
+
+	client.Close()
+	if client.IsHealthy() {
+		t.Error("a closed client is healthy")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantAuth bool // whether the error is an auth error
+		wantErr  bool
+	}{
+		{"accepted keys", http.StatusOK, false, false},
+		{"rejected keys", http.StatusUnauthorized, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			var authorized atomic.Bool
+			server.handle("GET", "/api/public/projects", func(w http.ResponseWriter, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				authorized.Store(ok && user == "pk-test" && pass == "sk-test")
+				if tt.status != http.StatusOK {
+					http.Error(w, "invalid credentials", tt.status)
+					return
+				}
+				writeJSON(w, map[string]interface{}{"data": []interface{}{map[string]interface{}{"id": "p1"}}})
+			})
+			client := newTestClient(t, server)
+
+			err := client.HealthCheck(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HealthCheck = %v, want error %v", err, tt.wantErr)
+			}
+			if IsAuthError(err) != tt.wantAuth {
+				t.Errorf("IsAuthError(%v) = %v, want %v", err, !tt.wantAuth, tt.wantAuth)
+			}
+			if !authorized.Load() {
+				t.Error("HealthCheck did not send the keys as basic auth")
+			}
+		})
+	}
+}
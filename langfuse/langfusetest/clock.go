@@ -0,0 +1,117 @@
+// Package langfusetest helps test code instrumented with Langfuse without
+// racing the client's background flush loop.
+//
+// Give the client a fake clock and advance it instead of sleeping. Ticks of a
+// fake clock are never delivered to the background loop; AdvanceClock runs the
+// flushes they would have triggered on the calling goroutine, so every event
+// queued before the call has been sent when it returns:
+//
+//	clock := langfusetest.NewClock(time.Now())
+//	config.Clock = clock
+//	config.FlushAt = 1000 // keep size-triggered flushes, which run in the background, out of the way
+//	client, _ := langfuse.NewClient(config)
+//
+//	runCodeUnderTest(client)
+//
+//	if err := langfusetest.AdvanceClock(client, config.FlushInterval); err != nil {
+//		t.Fatal(err)
+//	}
+//	// assert on what the test server received
+//
+// To flush without involving time at all, call client.ForceFlushSync.
+package langfusetest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// Clock is a fake langfuse.Clock that only moves when advanced
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+// NewClock returns a fake clock set to now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's current time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker that is due every d of fake time.
+// Its channel never delivers; see Advance.
+func (c *Clock) NewTicker(d time.Duration) langfuse.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{clock: c, period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and returns how many ticks of running
+// tickers fell due
+func (c *Clock) Advance(d time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	due := 0
+	for _, t := range c.tickers {
+		for t.period > 0 && !t.next.After(c.now) {
+			due++
+			t.next = t.next.Add(t.period)
+		}
+	}
+	return due
+}
+
+// ticker is a fake langfuse.Ticker
+type ticker struct {
+	clock  *Clock
+	period time.Duration
+	next   time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return nil
+}
+
+func (t *ticker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// AdvanceClock advances the fake clock of client by d and runs one flush on the
+// calling goroutine for every flush tick that fell due. The client must have been
+// created with a *Clock as Config.Clock.
+func AdvanceClock(client *langfuse.Client, d time.Duration) error {
+	clock, ok := client.Clock().(*Clock)
+	if !ok {
+		return errors.New("langfusetest: client is not using a langfusetest.Clock")
+	}
+
+	for due := clock.Advance(d); due > 0; due-- {
+		if err := client.ForceFlushSync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package langfusetest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// newClient returns a client on a fake clock, flushing every flushInterval of
+// fake time, and a function returning the events its server has received
+func newClient(t *testing.T, clock *Clock, flushInterval time.Duration) (*langfuse.Client, func() []langfuse.Event) {
+	var mu sync.Mutex
+	var events []langfuse.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req langfuse.IngestionRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		mu.Lock()
+		events = append(events, req.Batch...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"successes":[],"errors":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := langfuse.DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = server.URL
+	config.Clock = clock
+	config.FlushInterval = flushInterval
+	config.FlushAt = 1000
+	client, err := langfuse.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, func() []langfuse.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]langfuse.Event(nil), events...)
+	}
+}
+
+func TestAdvanceClockFlushesWhenTheIntervalPasses(t *testing.T) {
+	clock := NewClock(time.Now())
+	client, received := newClient(t, clock, 2*time.Second)
+
+	if _, err := client.CreateTrace(langfuse.TraceParams{Name: langfuse.Ptr("chat")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AdvanceClock(client, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(received()); n != 0 {
+		t.Fatalf("sent %d events before the flush interval passed", n)
+	}
+
+	if err := AdvanceClock(client, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	events := received()
+	if len(events) != 1 || events[0].Type != langfuse.EventTypeTraceCreate {
+		t.Errorf("sent %+v once the flush interval passed, want the trace", events)
+	}
+}
+
+func TestAdvanceClockCountsEveryTickThatFellDue(t *testing.T) {
+	clock := NewClock(time.Now())
+	client, _ := newClient(t, clock, time.Second)
+
+	start := clock.Now()
+	if err := AdvanceClock(client, 2500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got := clock.Now().Sub(start); got != 2500*time.Millisecond {
+		t.Errorf("clock moved by %s, want 2.5s", got)
+	}
+	if due := clock.Advance(500 * time.Millisecond); due != 1 {
+		t.Errorf("%d ticks fell due at 3s, want the third", due)
+	}
+}
+
+func TestForceFlushSyncSendsQueuedEvents(t *testing.T) {
+	client, received := newClient(t, NewClock(time.Now()), time.Hour)
+
+	if _, err := client.CreateTrace(langfuse.TraceParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ForceFlushSync(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(received()); n != 1 {
+		t.Errorf("sent %d events, want the trace", n)
+	}
+}
+
+func TestAdvanceClockNeedsAFakeClock(t *testing.T) {
+	config := langfuse.DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.Enabled = false
+	client, err := langfuse.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := AdvanceClock(client, time.Second); err == nil {
+		t.Error("AdvanceClock succeeded on a client with the real clock")
+	}
+}
@@ -0,0 +1,92 @@
+package langfuse
+
+// MetadataResolver is a metadata value that is evaluated when its event is
+// flushed instead of when the event is created, e.g. to record the queue depth
+// or memory usage at delivery time. A metadata value of type func() any is
+// evaluated the same way.
+//
+// Lazy values are resolved on the flush goroutine just before BeforeFlush runs
+// and the events are encoded, or when the event is queued if
+// Config.PersistentQueuePath is set, since the queue file holds encoded events.
+// Only top-level metadata values are resolved. A resolver that panics is
+// logged and its key is left out.
+type MetadataResolver interface {
+	Resolve() any
+}
+
+// MetadataFunc adapts a function to a MetadataResolver
+type MetadataFunc func() any
+
+// Resolve calls f
+func (f MetadataFunc) Resolve() any {
+	return f()
+}
+
+// resolveLazyMetadata returns event with the lazy values of its body's and its
+// own metadata resolved. Maps are copied rather than modified, since they may
+// be shared with the params they were built from.
+func resolveLazyMetadata(event Event, logger Logger) Event {
+	if metadata, ok := event.Body["metadata"].(map[string]interface{}); ok && hasLazyValues(metadata) {
+		body := make(map[string]interface{}, len(event.Body))
+		for k, v := range event.Body {
+			body[k] = v
+		}
+		body["metadata"] = resolveMetadataMap(metadata, logger)
+		event.Body = body
+	}
+	if hasLazyValues(event.Metadata) {
+		event.Metadata = resolveMetadataMap(event.Metadata, logger)
+	}
+	return event
+}
+
+// hasLazyValues reports whether metadata holds a value to resolve
+func hasLazyValues(metadata map[string]interface{}) bool {
+	for _, v := range metadata {
+		if isLazyValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLazyValue(v interface{}) bool {
+	switch v.(type) {
+	case MetadataResolver, func() any:
+		return true
+	}
+	return false
+}
+
+// resolveMetadataMap returns a copy of metadata with its lazy values resolved
+func resolveMetadataMap(metadata map[string]interface{}, logger Logger) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if !isLazyValue(v) {
+			resolved[k] = v
+			continue
+		}
+		if value, ok := resolveValue(k, v, logger); ok {
+			resolved[k] = value
+		}
+	}
+	return resolved
+}
+
+// resolveValue evaluates one lazy value, recovering from a panic
+func resolveValue(key string, v interface{}, logger Logger) (value interface{}, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("metadata resolver for %q panicked, leaving it out: %v", key, r)
+			value, ok = nil, false
+		}
+	}()
+
+	switch v := v.(type) {
+	case MetadataResolver:
+		return v.Resolve(), true
+	case func() any:
+		return v(), true
+	}
+	return v, true
+}
@@ -0,0 +1,42 @@
+package langfuse
+
+import "testing"
+
+func TestLazyMetadataIsResolvedAtFlush(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.Logger = &testLogger{}
+	})
+
+	depth := 1
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trace.StartSpan(NewSpan("step", WithMetadata(map[string]interface{}{
+		"static":     "x",
+		"queueDepth": MetadataFunc(func() any { return depth }),
+		"func":       func() any { return "called" },
+		"broken":     func() any { panic("resolver failed") },
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth = 7 // changed after the span was created, before the flush
+	flush(t, client)
+
+	spans := server.eventsOfType(EventTypeSpanCreate)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	metadata, _ := spans[0].Body["metadata"].(map[string]interface{})
+	if metadata["queueDepth"] != 7.0 {
+		t.Errorf("queueDepth = %v, want 7, its value at flush time", metadata["queueDepth"])
+	}
+	if metadata["func"] != "called" || metadata["static"] != "x" {
+		t.Errorf("metadata = %v, want the func resolved and the static value kept", metadata)
+	}
+	if _, ok := metadata["broken"]; ok {
+		t.Error("the panicking resolver's key was sent")
+	}
+}
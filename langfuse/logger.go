@@ -0,0 +1,48 @@
+package langfuse
+
+import "log"
+
+// Logger receives the SDK's log messages, so they can be routed to zap,
+// zerolog, slog or any other logging library. Messages are formatted like
+// fmt.Printf and have no trailing newline.
+//
+// Debug messages are always passed to a custom Logger, which decides which
+// levels to keep; Config.Debug only affects the default logger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// stdLogger is the default Logger. It writes to the standard log package with
+// a "[Langfuse]" prefix and drops debug messages unless debug is set.
+type stdLogger struct {
+	debug bool
+}
+
+func (l stdLogger) Debugf(format string, args ...interface{}) {
+	if l.debug {
+		log.Printf("[Langfuse] "+format, args...)
+	}
+}
+
+func (l stdLogger) Infof(format string, args ...interface{}) {
+	log.Printf("[Langfuse] "+format, args...)
+}
+
+func (l stdLogger) Warnf(format string, args ...interface{}) {
+	log.Printf("[Langfuse] WARNING: "+format, args...)
+}
+
+func (l stdLogger) Errorf(format string, args ...interface{}) {
+	log.Printf("[Langfuse] ERROR: "+format, args...)
+}
+
+// logger returns Config.Logger, or the default logger if it is not set
+func (c *Config) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return stdLogger{debug: c.Debug}
+}
@@ -0,0 +1,28 @@
+package langfuse
+
+// maskedFields are the event body fields passed through Config.MaskFunc
+var maskedFields = []string{"input", "output"}
+
+// maskBody returns a copy of body with its input and output passed through
+// mask. Bodies without them are returned as is. ClearField is never masked,
+// so clearing a field keeps working.
+func maskBody(body map[string]interface{}, mask func(any) any) map[string]interface{} {
+	var masked map[string]interface{}
+	for _, field := range maskedFields {
+		value, ok := body[field]
+		if !ok || value == nil || value == ClearField {
+			continue
+		}
+		if masked == nil {
+			masked = make(map[string]interface{}, len(body))
+			for k, v := range body {
+				masked[k] = v
+			}
+		}
+		masked[field] = mask(value)
+	}
+	if masked == nil {
+		return body
+	}
+	return masked
+}
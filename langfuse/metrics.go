@@ -1,6 +1,7 @@
 package langfuse
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -17,6 +18,8 @@ type Metrics struct {
 	eventsSucceeded int64
 	eventsFailed    int64
 	eventsDropped   int64
+	eventsSpilled   int64
+	eventsReplayed  int64
 
 	// Operation counters
 	flushCount int64
@@ -27,6 +30,64 @@ type Metrics struct {
 
 	// Failed events for monitoring (limited size)
 	failedEvents []FailedEvent
+
+	// Ingestion results per event type, guarded by mu
+	countsByType map[EventType]*EventTypeCounts
+
+	// Undelivered events per reason, guarded by mu
+	droppedByReason map[DropReason]int64
+}
+
+// DropReason tells why events were not delivered
+type DropReason string
+
+const (
+	// DropReasonQueueFull means the queue held MaxQueueSize events
+	DropReasonQueueFull DropReason = "queue_full"
+
+	// DropReasonExpired means the event was older than MaxEventAge
+	DropReasonExpired DropReason = "expired"
+
+	// DropReasonSampled means the event's trace was not sampled with SampleRate
+	DropReasonSampled DropReason = "sampled"
+
+	// DropReasonFiltered means BeforeFlush did not return the event
+	DropReasonFiltered DropReason = "filtered"
+
+	// DropReasonInvalid means BeforeFlush returned the event without an ID, type,
+	// timestamp or body
+	DropReasonInvalid DropReason = "invalid"
+
+	// DropReasonUnserializable means the event could not be encoded as JSON,
+	// e.g. because its input, output or metadata held a channel, a function
+	// or a cyclic structure
+	DropReasonUnserializable DropReason = "unserializable"
+
+	// DropReasonOversized means the event exceeded MaxBatchBytes on its own
+	DropReasonOversized DropReason = "oversized"
+
+	// DropReasonRejected means the API rejected the event or its batch with a
+	// non-retryable error
+	DropReasonRejected DropReason = "rejected"
+
+	// DropReasonRetryExhausted means sending failed MaxRetryAttempts retries
+	DropReasonRetryExhausted DropReason = "retry_exhausted"
+
+	// DropReasonAuthDisabled means the client was disabled by DisableOnAuthError
+	DropReasonAuthDisabled DropReason = "auth_disabled"
+
+	// DropReasonPurged means the event was discarded by Purge
+	DropReasonPurged DropReason = "purged"
+
+	// DropReasonShutdown means the event was still queued when the client was
+	// closed or stopped
+	DropReasonShutdown DropReason = "shutdown"
+)
+
+// EventTypeCounts counts the ingestion results of one event type
+type EventTypeCounts struct {
+	Success int64 `json:"success"`
+	Failed  int64 `json:"failed"`
 }
 
 // FailedEvent represents an event that failed to send
@@ -35,6 +96,7 @@ type FailedEvent struct {
 	Error     error
 	Attempt   int
 	Timestamp time.Time
+	Reason    DropReason // empty if recorded with RecordFailedEvent
 }
 
 // RecordEnqueued records that events were added to the queue
@@ -51,11 +113,62 @@ func (m *Metrics) RecordFlush(success, failed int) {
 	atomic.StoreInt64(&m.lastFlushTimeUnix, time.Now().UnixNano())
 }
 
+// RecordResults records the ingestion result of each event of a flushed batch
+// by event type. Events without an error in resp count as succeeded.
+func (m *Metrics) RecordResults(events []Event, resp *IngestionResponse) {
+	failed := make(map[string]bool)
+	if resp != nil {
+		for _, e := range resp.Errors {
+			failed[e.ID] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.countsByType == nil {
+		m.countsByType = make(map[EventType]*EventTypeCounts)
+	}
+	for _, e := range events {
+		counts := m.countsByType[e.Type]
+		if counts == nil {
+			counts = &EventTypeCounts{}
+			m.countsByType[e.Type] = counts
+		}
+		if failed[e.ID] {
+			counts.Failed++
+		} else {
+			counts.Success++
+		}
+	}
+}
+
 // RecordDropped records that events were dropped due to a full queue
 func (m *Metrics) RecordDropped(count int) {
 	atomic.AddInt64(&m.eventsDropped, int64(count))
 }
 
+// RecordDropReason records that events were not delivered for reason
+func (m *Metrics) RecordDropReason(reason DropReason, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.droppedByReason == nil {
+		m.droppedByReason = make(map[DropReason]int64)
+	}
+	m.droppedByReason[reason] += int64(count)
+}
+
+// RecordSpilled records events written to the overflow file (Config.OverflowToDisk)
+func (m *Metrics) RecordSpilled(count int) {
+	atomic.AddInt64(&m.eventsSpilled, int64(count))
+}
+
+// RecordReplayed records spilled events moved back into the queue
+func (m *Metrics) RecordReplayed(count int) {
+	atomic.AddInt64(&m.eventsReplayed, int64(count))
+}
+
 // RecordRetry records that a retry attempt was made
 func (m *Metrics) RecordRetry() {
 	atomic.AddInt64(&m.retryCount, 1)
@@ -63,6 +176,11 @@ func (m *Metrics) RecordRetry() {
 
 // RecordFailedEvent records a failed event for monitoring
 func (m *Metrics) RecordFailedEvent(event Event, err error, attempt int) {
+	m.RecordFailedEventWithReason(event, err, attempt, "")
+}
+
+// RecordFailedEventWithReason records a failed event and why it was given up on
+func (m *Metrics) RecordFailedEventWithReason(event Event, err error, attempt int, reason DropReason) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -71,6 +189,7 @@ func (m *Metrics) RecordFailedEvent(event Event, err error, attempt int) {
 		Error:     err,
 		Attempt:   attempt,
 		Timestamp: time.Now(),
+		Reason:    reason,
 	})
 
 	// Limit the size to prevent unbounded growth
@@ -87,16 +206,39 @@ func (m *Metrics) GetSnapshot() MetricsSnapshot {
 		lastFlush = time.Unix(0, lastFlushUnix)
 	}
 
+	m.mu.Lock()
+	failedEventCount := len(m.failedEvents)
+	var countsByType map[EventType]EventTypeCounts
+	if len(m.countsByType) > 0 {
+		countsByType = make(map[EventType]EventTypeCounts, len(m.countsByType))
+		for eventType, counts := range m.countsByType {
+			countsByType[eventType] = *counts
+		}
+	}
+	var droppedByReason map[DropReason]int64
+	if len(m.droppedByReason) > 0 {
+		droppedByReason = make(map[DropReason]int64, len(m.droppedByReason))
+		for reason, count := range m.droppedByReason {
+			droppedByReason[reason] = count
+		}
+	}
+	m.mu.Unlock()
+
 	return MetricsSnapshot{
-		EventsEnqueued:  atomic.LoadInt64(&m.eventsEnqueued),
-		EventsFlushed:   atomic.LoadInt64(&m.eventsFlushed),
-		EventsSucceeded: atomic.LoadInt64(&m.eventsSucceeded),
-		EventsFailed:    atomic.LoadInt64(&m.eventsFailed),
-		EventsDropped:   atomic.LoadInt64(&m.eventsDropped),
-		FlushCount:      atomic.LoadInt64(&m.flushCount),
-		RetryCount:      atomic.LoadInt64(&m.retryCount),
-		LastFlushTime:   lastFlush,
-		FailedEventCount: len(m.failedEvents),
+		SchemaVersion:    MetricsSchemaVersion,
+		EventsEnqueued:   atomic.LoadInt64(&m.eventsEnqueued),
+		EventsFlushed:    atomic.LoadInt64(&m.eventsFlushed),
+		EventsSucceeded:  atomic.LoadInt64(&m.eventsSucceeded),
+		EventsFailed:     atomic.LoadInt64(&m.eventsFailed),
+		EventsDropped:    atomic.LoadInt64(&m.eventsDropped),
+		EventsSpilled:    atomic.LoadInt64(&m.eventsSpilled),
+		EventsReplayed:   atomic.LoadInt64(&m.eventsReplayed),
+		FlushCount:       atomic.LoadInt64(&m.flushCount),
+		RetryCount:       atomic.LoadInt64(&m.retryCount),
+		LastFlushTime:    lastFlush,
+		FailedEventCount: failedEventCount,
+		CountsByType:     countsByType,
+		DroppedByReason:  droppedByReason,
 	}
 }
 
@@ -110,33 +252,108 @@ func (m *Metrics) GetFailedEvents() []FailedEvent {
 	return events
 }
 
-// Reset clears all metrics (useful for testing)
+// Reset clears all metrics (useful for testing); see Client.ResetMetrics
 func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.eventsEnqueued, 0)
 	atomic.StoreInt64(&m.eventsFlushed, 0)
 	atomic.StoreInt64(&m.eventsSucceeded, 0)
 	atomic.StoreInt64(&m.eventsFailed, 0)
 	atomic.StoreInt64(&m.eventsDropped, 0)
+	atomic.StoreInt64(&m.eventsSpilled, 0)
+	atomic.StoreInt64(&m.eventsReplayed, 0)
 	atomic.StoreInt64(&m.flushCount, 0)
 	atomic.StoreInt64(&m.retryCount, 0)
 	atomic.StoreInt64(&m.lastFlushTimeUnix, 0)
 
 	m.mu.Lock()
 	m.failedEvents = nil
+	m.countsByType = nil
+	m.droppedByReason = nil
 	m.mu.Unlock()
 }
 
-// MetricsSnapshot represents a point-in-time snapshot of metrics
+// MetricsSchemaVersion is the version of the MetricsSnapshot JSON schema.
+//
+// The schema only changes additively: fields may be added, but existing fields
+// are never renamed, removed or changed in meaning, so consumers of an older
+// version keep working. Groups of related metrics added later are nested under
+// their own object with its own "version" field rather than at the top level.
+// SchemaVersion is only incremented for a breaking change.
+const MetricsSchemaVersion = 1
+
+// MetricsSnapshot represents a point-in-time snapshot of metrics.
+// Its JSON encoding is stable, see MetricsSchemaVersion.
 type MetricsSnapshot struct {
-	EventsEnqueued   int64
-	EventsFlushed    int64
-	EventsSucceeded  int64
-	EventsFailed     int64
-	EventsDropped    int64
-	FlushCount       int64
-	RetryCount       int64
-	LastFlushTime    time.Time
-	FailedEventCount int
+	SchemaVersion    int       `json:"schemaVersion"`
+	EventsEnqueued   int64     `json:"eventsEnqueued,omitempty"`
+	EventsFlushed    int64     `json:"eventsFlushed,omitempty"`
+	EventsSucceeded  int64     `json:"eventsSucceeded,omitempty"`
+	EventsFailed     int64     `json:"eventsFailed,omitempty"`
+	EventsDropped    int64     `json:"eventsDropped,omitempty"`
+	FlushCount       int64     `json:"flushCount,omitempty"`
+	RetryCount       int64     `json:"retryCount,omitempty"`
+	LastFlushTime    time.Time `json:"lastFlushTime,omitempty"`
+	FailedEventCount int       `json:"failedEventCount,omitempty"`
+
+	// EventsSpilled and EventsReplayed count the events written to and read
+	// back from the overflow file of Config.OverflowToDisk
+	EventsSpilled  int64 `json:"eventsSpilled,omitempty"`
+	EventsReplayed int64 `json:"eventsReplayed,omitempty"`
+
+	// CountsByType breaks EventsSucceeded and EventsFailed down by event type.
+	// Errors the API returns without an event ID cannot be attributed and count
+	// as succeeded here. It is encoded as {"version": 1, "counts": {...}}.
+	CountsByType map[EventType]EventTypeCounts `json:"-"`
+
+	// DroppedByReason counts the events that were not delivered by reason:
+	// both those in EventsDropped and those in EventsFailed. It is encoded as
+	// {"version": 1, "counts": {...}}.
+	DroppedByReason map[DropReason]int64 `json:"-"`
+}
+
+// countsByTypeVersion is the version of the countsByType JSON object
+const countsByTypeVersion = 1
+
+// droppedByReasonVersion is the version of the droppedByReason JSON object
+const droppedByReasonVersion = 1
+
+// MarshalJSON encodes the snapshot with LastFlushTime as RFC 3339 in UTC.
+// Zero values, including a zero LastFlushTime, are omitted; SchemaVersion is always present.
+func (s MetricsSnapshot) MarshalJSON() ([]byte, error) {
+	type Alias MetricsSnapshot
+	aux := struct {
+		Alias
+		LastFlushTime   string               `json:"lastFlushTime,omitempty"`
+		CountsByType    *countsByTypeJSON    `json:"countsByType,omitempty"`
+		DroppedByReason *droppedByReasonJSON `json:"droppedByReason,omitempty"`
+	}{
+		Alias: Alias(s),
+	}
+	if aux.SchemaVersion == 0 {
+		aux.SchemaVersion = MetricsSchemaVersion
+	}
+	if !s.LastFlushTime.IsZero() {
+		aux.LastFlushTime = s.LastFlushTime.UTC().Format(time.RFC3339Nano)
+	}
+	if len(s.CountsByType) > 0 {
+		aux.CountsByType = &countsByTypeJSON{Version: countsByTypeVersion, Counts: s.CountsByType}
+	}
+	if len(s.DroppedByReason) > 0 {
+		aux.DroppedByReason = &droppedByReasonJSON{Version: droppedByReasonVersion, Counts: s.DroppedByReason}
+	}
+	return json.Marshal(aux)
+}
+
+// countsByTypeJSON is the versioned JSON encoding of MetricsSnapshot.CountsByType
+type countsByTypeJSON struct {
+	Version int                           `json:"version"`
+	Counts  map[EventType]EventTypeCounts `json:"counts"`
+}
+
+// droppedByReasonJSON is the versioned JSON encoding of MetricsSnapshot.DroppedByReason
+type droppedByReasonJSON struct {
+	Version int                  `json:"version"`
+	Counts  map[DropReason]int64 `json:"counts"`
 }
 
 // String returns a formatted string representation of the snapshot
@@ -0,0 +1,182 @@
+// Package lfmux provides Langfuse tracing middleware for the gorilla/mux router.
+package lfmux
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// Option configures Middleware
+type Option func(*options)
+
+type options struct {
+	traceName    func(r *http.Request) string
+	skip         func(r *http.Request) bool
+	recordPanics bool
+	recordQuery  bool
+}
+
+// WithTraceName overrides the trace name, which defaults to the method and the
+// route's path template, e.g. "GET /items/{id}"
+func WithTraceName(name func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.traceName = name
+	}
+}
+
+// WithSkip skips tracing for requests for which skip returns true,
+// e.g. health checks
+func WithSkip(skip func(r *http.Request) bool) Option {
+	return func(o *options) {
+		o.skip = skip
+	}
+}
+
+// WithPanicRecording records a panic in the handler on the request's trace
+// with Trace.RecoverAndEnd before letting it continue to net/http
+func WithPanicRecording() Option {
+	return func(o *options) {
+		o.recordPanics = true
+	}
+}
+
+// WithQueryString records the request's query string in the trace input,
+// which only holds the method and path by default, since query strings
+// often carry tokens or personal data
+func WithQueryString() Option {
+	return func(o *options) {
+		o.recordQuery = true
+	}
+}
+
+// TraceFromContext returns the trace created by Middleware for the request,
+// or nil if the request is not traced. It is the same as langfuse.TraceFromContext.
+func TraceFromContext(ctx context.Context) *langfuse.Trace {
+	return langfuse.TraceFromContext(ctx)
+}
+
+// Middleware returns a mux middleware that creates a trace for every request.
+// The trace is named after the matched route template rather than the raw path,
+// so requests to the same route are grouped, and the route variables are
+// recorded in its metadata. The response status is recorded as the trace output.
+// Handlers can get the trace with TraceFromContext to add observations to it,
+// and the langfuse FromContext helpers use it.
+func Middleware(client *langfuse.Client, opts ...Option) mux.MiddlewareFunc {
+	o := options{traceName: defaultTraceName}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.skip != nil && o.skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			metadata := map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}
+			if vars := mux.Vars(r); len(vars) > 0 {
+				metadata["routeVars"] = vars
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					metadata["routeTemplate"] = template
+				}
+			}
+
+			input := map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}
+			if o.recordQuery && r.URL.RawQuery != "" {
+				input["query"] = r.URL.RawQuery
+			}
+			trace, err := client.CreateTrace(langfuse.TraceParams{
+				Name:     langfuse.Ptr(o.traceName(r)),
+				Input:    input,
+				Metadata: metadata,
+			})
+			if err != nil {
+				// Tracing must never break the request
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if o.recordPanics {
+				defer trace.RecoverAndEnd()
+			}
+
+			recorder, rw := newStatusRecorder(w)
+			next.ServeHTTP(rw, r.WithContext(langfuse.ContextWithTrace(r.Context(), trace)))
+
+			_ = trace.Update(langfuse.TraceParams{
+				Output: map[string]interface{}{"status": recorder.status},
+			})
+		})
+	}
+}
+
+// defaultTraceName names a trace after the request method and route template,
+// falling back to the raw path when no route matched
+func defaultTraceName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + template
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// hijackingStatusRecorder is a statusRecorder for a ResponseWriter that can
+// be hijacked, e.g. for WebSockets
+type hijackingStatusRecorder struct {
+	*statusRecorder
+}
+
+// newStatusRecorder wraps w in a statusRecorder, and returns it with the
+// ResponseWriter to pass to the handler, which is also an http.Hijacker if
+// w is one
+func newStatusRecorder(w http.ResponseWriter) (*statusRecorder, http.ResponseWriter) {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if _, ok := w.(http.Hijacker); ok {
+		return recorder, hijackingStatusRecorder{recorder}
+	}
+	return recorder, recorder
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends buffered data to the client, if the underlying ResponseWriter
+// supports it, so streamed responses such as server-sent events keep working
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack lets the handler take over the connection
+func (r hijackingStatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.ResponseWriter.(http.Hijacker).Hijack()
+}
@@ -0,0 +1,130 @@
+package lfmux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// newClient returns a client sending to a fake ingestion API, and a function
+// returning the bodies of the trace-create events it received
+func newClient(t *testing.T) (*langfuse.Client, func() []map[string]interface{}) {
+	var mu sync.Mutex
+	var traces []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req langfuse.IngestionRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		mu.Lock()
+		for _, e := range req.Batch {
+			if e.Type == langfuse.EventTypeTraceCreate {
+				traces = append(traces, e.Body)
+			}
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"successes":[],"errors":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := langfuse.DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = server.URL
+	config.FlushInterval = time.Hour
+	client, err := langfuse.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, func() []map[string]interface{} {
+		if err := client.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return traces
+	}
+}
+
+func TestMiddlewareRecordsPathWithoutQuery(t *testing.T) {
+	for _, recordQuery := range []bool{false, true} {
+		client, traces := newClient(t)
+		var opts []Option
+		if recordQuery {
+			opts = append(opts, WithQueryString())
+		}
+		router := mux.NewRouter()
+		router.Use(Middleware(client, opts...))
+		router.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/1?token=secret", nil))
+
+		var input map[string]interface{}
+		for _, trace := range traces() {
+			if value, ok := trace["input"].(map[string]interface{}); ok {
+				input = value
+			}
+		}
+		if input["path"] != "/items/1" {
+			t.Errorf("input = %v, want the path /items/1", input)
+		}
+		if query, ok := input["query"]; ok != recordQuery || (ok && query != "token=secret") {
+			t.Errorf("WithQueryString %v: input = %v", recordQuery, input)
+		}
+	}
+}
+
+// hijackableRecorder is a ResponseRecorder that can be hijacked
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestMiddlewareKeepsFlushAndHijack(t *testing.T) {
+	client, _ := newClient(t)
+	var flushed, hijackable bool
+	router := mux.NewRouter()
+	router.Use(Middleware(client))
+	router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+			flushed = true
+		}
+		if hijacker, ok := w.(http.Hijacker); ok {
+			hijackable = true
+			hijacker.Hijack()
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/stream", nil))
+	if !flushed || !recorder.Flushed {
+		t.Error("handler could not flush the response")
+	}
+	if hijackable {
+		t.Error("handler could hijack a ResponseWriter that does not support it")
+	}
+
+	hijackRecorder := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	router.ServeHTTP(hijackRecorder, httptest.NewRequest("GET", "/stream", nil))
+	if !hijackRecorder.hijacked {
+		t.Error("handler could not hijack a ResponseWriter that supports it")
+	}
+}
@@ -1,6 +1,8 @@
 package langfuse
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
@@ -41,6 +43,10 @@ type ObservationParams struct {
 
 	// Environment is the environment name
 	Environment *string
+
+	// Clear lists fields to erase on the server, named as in the API body
+	// (e.g. "name", "metadata", "statusMessage"). They are sent as null.
+	Clear []string
 }
 
 // SpanParams contains parameters for creating a span
@@ -56,6 +62,10 @@ type EventParams struct {
 	ObservationParams
 }
 
+// MetadataKeyProvider is the metadata key holding the model provider of a
+// generation or embedding, see GenerationParams.Provider
+const MetadataKeyProvider = "provider"
+
 // GenerationParams contains parameters for creating a generation
 type GenerationParams struct {
 	SpanParams
@@ -63,12 +73,20 @@ type GenerationParams struct {
 	// Model is the model name/identifier
 	Model *string
 
+	// Provider is the model provider, e.g. "openai", "anthropic" or "local".
+	// It is recorded in the metadata under MetadataKeyProvider, so
+	// generations can be grouped by provider independently of the model name.
+	Provider *string
+
 	// ModelParameters are parameters passed to the model
 	ModelParameters map[string]interface{}
 
 	// Usage contains token usage information
 	Usage *Usage
 
+	// AudioUsage contains the audio token usage of audio models
+	AudioUsage *AudioUsage
+
 	// PromptName is the name of the prompt used
 	PromptName *string
 
@@ -77,6 +95,11 @@ type GenerationParams struct {
 
 	// CompletionStartTime is when the completion started streaming
 	CompletionStartTime *time.Time
+
+	// OutputSchema is the JSON schema the output should conform to, e.g. of
+	// structured outputs (see ResponseFormatSchema). It is recorded in the
+	// metadata under MetadataKeyOutputSchema.
+	OutputSchema interface{}
 }
 
 // AgentParams contains parameters for creating an agent observation
@@ -111,13 +134,23 @@ type EmbeddingParams struct {
 	// EmbeddingModel is the embedding model name/identifier
 	EmbeddingModel *string
 
+	// Provider is the embedding model provider, recorded like
+	// GenerationParams.Provider
+	Provider *string
+
 	// EmbeddingModelParameters are parameters passed to the embedding model
 	EmbeddingModelParameters map[string]interface{}
+
+	// Usage contains token usage information
+	Usage *Usage
 }
 
 // GuardrailParams contains parameters for creating a guardrail observation
 type GuardrailParams struct {
 	ObservationParams
+
+	// EndTime is when the guardrail check ended
+	EndTime *time.Time
 }
 
 // SdkLogParams contains parameters for creating SDK log events
@@ -126,14 +159,32 @@ type SdkLogParams struct {
 	Log interface{}
 }
 
-// CreateSpan creates a new span observation
+// CreateSpan creates a new span observation and returns its ID
+//
+// Deprecated: use StartSpan, which returns a handle to update and end it.
 func (t *Trace) CreateSpan(params SpanParams) (string, error) {
-	return t.client.CreateSpan(t.id, params)
+	t.client.warnDeprecated("Trace.CreateSpan", "Trace.StartSpan")
+	return t.createSpan(params, t.client.enqueue)
+}
+
+// createSpan creates a new span observation with enqueue and records it in
+// the trace's log
+func (t *Trace) createSpan(params SpanParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createSpan(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeSpan, params.ObservationParams)
 }
 
 // CreateSpan creates a new span observation
 func (c *Client) CreateSpan(traceID string, params SpanParams) (string, error) {
-	id := generateID()
+	return c.createSpan(traceID, params, c.enqueue)
+}
+
+// createSpan creates a new span observation, queuing its event with enqueue
+func (c *Client) createSpan(traceID string, params SpanParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -147,13 +198,13 @@ func (c *Client) CreateSpan(traceID string, params SpanParams) (string, error) {
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeSpanCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
@@ -162,12 +213,31 @@ func (c *Client) CreateSpan(traceID string, params SpanParams) (string, error) {
 
 // CreateEvent creates a new event observation
 func (t *Trace) CreateEvent(params EventParams) (string, error) {
-	return t.client.CreateEvent(t.id, params)
+	return t.createEvent(params, t.client.enqueue)
+}
+
+// CreateEventWithContext is like CreateEvent, but if the queue is full it
+// waits for space until ctx is done instead of dropping the event
+func (t *Trace) CreateEventWithContext(ctx context.Context, params EventParams) (string, error) {
+	return t.createEvent(params, t.client.enqueueWaiting(ctx))
+}
+
+func (t *Trace) createEvent(params EventParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createEvent(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeEvent, params.ObservationParams)
 }
 
 // CreateEvent creates a new event observation
 func (c *Client) CreateEvent(traceID string, params EventParams) (string, error) {
-	id := generateID()
+	return c.createEvent(traceID, params, c.enqueue)
+}
+
+// createEvent creates a new event observation, queuing its event with enqueue
+func (c *Client) createEvent(traceID string, params EventParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -177,27 +247,46 @@ func (c *Client) CreateEvent(traceID string, params EventParams) (string, error)
 	body := observationToBody(params.ObservationParams, id)
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeEventCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
 
-// CreateGeneration creates a new generation observation
+// CreateGeneration creates a new generation observation and returns its ID
+//
+// Deprecated: use StartGeneration, which returns a handle to update and end it.
 func (t *Trace) CreateGeneration(params GenerationParams) (string, error) {
-	return t.client.CreateGeneration(t.id, params)
+	t.client.warnDeprecated("Trace.CreateGeneration", "Trace.StartGeneration")
+	return t.createGeneration(params, t.client.enqueue)
+}
+
+// createGeneration creates a new generation observation with enqueue and
+// records it in the trace's log
+func (t *Trace) createGeneration(params GenerationParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createGeneration(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeGeneration, params.ObservationParams)
 }
 
 // CreateGeneration creates a new generation observation
 func (c *Client) CreateGeneration(traceID string, params GenerationParams) (string, error) {
-	id := generateID()
+	return c.createGeneration(traceID, params, c.enqueue)
+}
+
+// createGeneration creates a new generation observation, queuing its event
+// with enqueue
+func (c *Client) createGeneration(traceID string, params GenerationParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -210,83 +299,139 @@ func (c *Client) CreateGeneration(traceID string, params GenerationParams) (stri
 		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
 	}
 
-	if params.Model != nil {
-		body["model"] = *params.Model
-	}
-
-	if params.ModelParameters != nil {
-		body["modelParameters"] = params.ModelParameters
-	}
-
-	if params.Usage != nil {
-		body["usage"] = params.Usage
-	}
-
-	if params.PromptName != nil {
-		body["promptName"] = *params.PromptName
-	}
-
-	if params.PromptVersion != nil {
-		body["promptVersion"] = *params.PromptVersion
-	}
-
-	if params.CompletionStartTime != nil {
-		body["completionStartTime"] = params.CompletionStartTime.Format(time.RFC3339Nano)
-	}
+	c.addGenerationFields(body, params)
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeGenerationCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
+	if c.config.WarnIncompleteGenerations {
+		c.generations.created(id, params)
+	}
+
 	return id, nil
 }
 
 // UpdateSpan updates an existing span
 func (c *Client) UpdateSpan(spanID string, params SpanParams) error {
-	body := observationToBody(params.ObservationParams, spanID)
+	return c.UpdateObservation(spanID, ObservationTypeSpan, params)
+}
 
-	if params.EndTime != nil {
-		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
+// UpdateEvent updates an existing event observation, e.g. to raise its level
+// or add metadata after the fact. The server merges the fields into the event.
+// params.TraceID must be set to the event's trace so the upsert stays on that trace.
+func (c *Client) UpdateEvent(eventID string, params EventParams) error {
+	return c.UpdateObservation(eventID, ObservationTypeEvent, SpanParams{ObservationParams: params.ObservationParams})
+}
+
+// UpdateGeneration updates an existing generation
+func (c *Client) UpdateGeneration(generationID string, params GenerationParams) error {
+	event, err := c.observationUpdateEvent(generationID, ObservationTypeGeneration, params.SpanParams)
+	if err != nil {
+		return err
 	}
 
-	event := Event{
-		ID:        generateID(),
-		Type:      EventTypeSpanUpdate,
-		Timestamp: time.Now(),
-		Body:      body,
+	c.addGenerationFields(event.Body, params)
+
+	if err := c.enqueue(event); err != nil {
+		return err
 	}
 
-	return c.enqueue(event)
+	if c.config.WarnIncompleteGenerations {
+		c.generations.updated(generationID, params)
+	}
+
+	return nil
 }
 
-// UpdateGeneration updates an existing generation
-func (c *Client) UpdateGeneration(generationID string, params GenerationParams) error {
-	body := observationToBody(params.ObservationParams, generationID)
+// UpdateObservation updates an existing observation of any type with the
+// common span fields, e.g. when the type is only known at runtime.
+// Type-specific fields such as a generation's model or usage can only be set
+// with the typed Update methods.
+func (c *Client) UpdateObservation(id string, obsType ObservationType, params SpanParams) error {
+	event, err := c.observationUpdateEvent(id, obsType, params)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(event)
+}
 
-	if params.EndTime != nil {
-		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
+// observationUpdateEvent builds the event that updates an observation of the given type
+func (c *Client) observationUpdateEvent(id string, obsType ObservationType, params SpanParams) (Event, error) {
+	event := Event{
+		ID:        c.generateID(),
+		Timestamp: time.Now(),
 	}
 
-	if params.Model != nil {
+	switch obsType {
+	case ObservationTypeGeneration:
+		event.Type = EventTypeGenerationUpdate
+	case ObservationTypeSpan, ObservationTypeAgent, ObservationTypeTool, ObservationTypeChain,
+		ObservationTypeRetriever, ObservationTypeEvaluator, ObservationTypeGuardrail:
+		// These are kinds of span, so they use span-update
+		event.Type = EventTypeSpanUpdate
+	case ObservationTypeEvent:
+		// There is no event-update; the server upserts by ID, and an upsert
+		// without a trace ID would move the event off its trace
+		if params.TraceID == "" {
+			return Event{}, fmt.Errorf("params.TraceID is required to update an event")
+		}
+		event.Type = EventTypeEventCreate
+		event.upsert = true
+	case ObservationTypeEmbedding:
+		// There is no embedding-update; the server upserts by ID
+		event.Type = EventTypeEmbeddingCreate
+		event.upsert = true
+	default:
+		return Event{}, fmt.Errorf("unknown observation type %q", obsType)
+	}
+
+	event.Body = observationToBody(params.ObservationParams, id)
+	if params.EndTime != nil && obsType != ObservationTypeEvent {
+		event.Body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
+	}
+
+	return event, nil
+}
+
+// addGenerationFields adds the generation-specific fields of params to body
+func (c *Client) addGenerationFields(body map[string]interface{}, params GenerationParams) {
+	if params.Model != nil && *params.Model != "" {
 		body["model"] = *params.Model
 	}
 
-	if params.ModelParameters != nil {
+	if params.Provider != nil && *params.Provider != "" {
+		addMetadata(body, MetadataKeyProvider, *params.Provider)
+	}
+
+	if len(params.ModelParameters) > 0 {
 		body["modelParameters"] = params.ModelParameters
 	}
 
 	if params.Usage != nil {
-		body["usage"] = params.Usage
+		body["usage"] = roundedUsage(params.Usage, c.config.CostPrecision)
+
+		if params.Usage.Currency != nil {
+			addMetadata(body, MetadataKeyCostCurrency, *params.Usage.Currency)
+		}
+	}
+
+	if params.OutputSchema != nil {
+		addMetadata(body, MetadataKeyOutputSchema, params.OutputSchema)
 	}
 
-	if params.PromptName != nil {
+	if params.AudioUsage != nil {
+		body["audioUsage"] = params.AudioUsage
+	}
+
+	if params.PromptName != nil && *params.PromptName != "" {
 		body["promptName"] = *params.PromptName
 	}
 
@@ -297,15 +442,18 @@ func (c *Client) UpdateGeneration(generationID string, params GenerationParams)
 	if params.CompletionStartTime != nil {
 		body["completionStartTime"] = params.CompletionStartTime.Format(time.RFC3339Nano)
 	}
+}
 
-	event := Event{
-		ID:        generateID(),
-		Type:      EventTypeGenerationUpdate,
-		Timestamp: time.Now(),
-		Body:      body,
+// addMetadata sets a metadata key in an event body, copying the metadata so
+// the caller's map is not modified
+func addMetadata(body map[string]interface{}, key string, value interface{}) {
+	existing, _ := body["metadata"].(map[string]interface{})
+	metadata := make(map[string]interface{}, len(existing)+1)
+	for k, v := range existing {
+		metadata[k] = v
 	}
-
-	return c.enqueue(event)
+	metadata[key] = value
+	body["metadata"] = metadata
 }
 
 // observationToBody converts observation params to event body
@@ -317,11 +465,11 @@ func observationToBody(params ObservationParams, id string) map[string]interface
 		body["traceId"] = params.TraceID
 	}
 
-	if params.ParentObservationID != nil {
+	if params.ParentObservationID != nil && *params.ParentObservationID != "" {
 		body["parentObservationId"] = *params.ParentObservationID
 	}
 
-	if params.Name != nil {
+	if params.Name != nil && *params.Name != "" {
 		body["name"] = *params.Name
 	}
 
@@ -329,7 +477,7 @@ func observationToBody(params ObservationParams, id string) map[string]interface
 		body["startTime"] = params.StartTime.Format(time.RFC3339Nano)
 	}
 
-	if params.Metadata != nil {
+	if len(params.Metadata) > 0 {
 		body["metadata"] = params.Metadata
 	}
 
@@ -341,21 +489,23 @@ func observationToBody(params ObservationParams, id string) map[string]interface
 		body["output"] = params.Output
 	}
 
-	if params.Level != nil {
+	if params.Level != nil && *params.Level != "" {
 		body["level"] = string(*params.Level)
 	}
 
-	if params.StatusMessage != nil {
+	if params.StatusMessage != nil && *params.StatusMessage != "" {
 		body["statusMessage"] = *params.StatusMessage
 	}
 
-	if params.Version != nil {
+	if params.Version != nil && *params.Version != "" {
 		body["version"] = *params.Version
 	}
 
-	if params.Environment != nil {
+	if params.Environment != nil && *params.Environment != "" {
 		body["environment"] = *params.Environment
 	}
 
+	applyClear(body, params.Clear)
+
 	return body
 }
@@ -4,14 +4,32 @@ import (
 	"time"
 )
 
-// CreateAgent creates a new agent observation
+// CreateAgent creates a new agent observation and returns its ID
+//
+// Deprecated: use StartAgent, which returns a handle to update and end it.
 func (t *Trace) CreateAgent(params AgentParams) (string, error) {
-	return t.client.CreateAgent(t.id, params)
+	t.client.warnDeprecated("Trace.CreateAgent", "Trace.StartAgent")
+	return t.createAgent(params, t.client.enqueue)
+}
+
+// createAgent creates a new agent observation with enqueue and records it in
+// the trace's log
+func (t *Trace) createAgent(params AgentParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createAgent(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeAgent, params.ObservationParams)
 }
 
 // CreateAgent creates a new agent observation
 func (c *Client) CreateAgent(traceID string, params AgentParams) (string, error) {
-	id := generateID()
+	return c.createAgent(traceID, params, c.enqueue)
+}
+
+// createAgent creates a new agent observation, queuing its event with enqueue
+func (c *Client) createAgent(traceID string, params AgentParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -24,27 +42,45 @@ func (c *Client) CreateAgent(traceID string, params AgentParams) (string, error)
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeAgentCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
 
-// CreateTool creates a new tool observation
+// CreateTool creates a new tool observation and returns its ID
+//
+// Deprecated: use StartTool, which returns a handle to update and end it.
 func (t *Trace) CreateTool(params ToolParams) (string, error) {
-	return t.client.CreateTool(t.id, params)
+	t.client.warnDeprecated("Trace.CreateTool", "Trace.StartTool")
+	return t.createTool(params, t.client.enqueue)
+}
+
+// createTool creates a new tool observation with enqueue and records it in
+// the trace's log
+func (t *Trace) createTool(params ToolParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createTool(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeTool, params.ObservationParams)
 }
 
 // CreateTool creates a new tool observation
 func (c *Client) CreateTool(traceID string, params ToolParams) (string, error) {
-	id := generateID()
+	return c.createTool(traceID, params, c.enqueue)
+}
+
+// createTool creates a new tool observation, queuing its event with enqueue
+func (c *Client) createTool(traceID string, params ToolParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -57,13 +93,13 @@ func (c *Client) CreateTool(traceID string, params ToolParams) (string, error) {
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeToolCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
@@ -72,12 +108,16 @@ func (c *Client) CreateTool(traceID string, params ToolParams) (string, error) {
 
 // CreateChain creates a new chain observation
 func (t *Trace) CreateChain(params ChainParams) (string, error) {
-	return t.client.CreateChain(t.id, params)
+	if err := t.sendCreate(t.client.enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.CreateChain(t.id, params)
+	return t.logObservation(id, err, ObservationTypeChain, params.ObservationParams)
 }
 
 // CreateChain creates a new chain observation
 func (c *Client) CreateChain(traceID string, params ChainParams) (string, error) {
-	id := generateID()
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -90,7 +130,7 @@ func (c *Client) CreateChain(traceID string, params ChainParams) (string, error)
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeChainCreate,
 		Timestamp: time.Now(),
 		Body:      body,
@@ -105,12 +145,16 @@ func (c *Client) CreateChain(traceID string, params ChainParams) (string, error)
 
 // CreateRetriever creates a new retriever observation
 func (t *Trace) CreateRetriever(params RetrieverParams) (string, error) {
-	return t.client.CreateRetriever(t.id, params)
+	if err := t.sendCreate(t.client.enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.CreateRetriever(t.id, params)
+	return t.logObservation(id, err, ObservationTypeRetriever, params.ObservationParams)
 }
 
 // CreateRetriever creates a new retriever observation
 func (c *Client) CreateRetriever(traceID string, params RetrieverParams) (string, error) {
-	id := generateID()
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -123,7 +167,7 @@ func (c *Client) CreateRetriever(traceID string, params RetrieverParams) (string
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeRetrieverCreate,
 		Timestamp: time.Now(),
 		Body:      body,
@@ -136,14 +180,32 @@ func (c *Client) CreateRetriever(traceID string, params RetrieverParams) (string
 	return id, nil
 }
 
-// CreateEvaluator creates a new evaluator observation
+// CreateEvaluator creates a new evaluator observation and returns its ID
+//
+// Deprecated: use StartEvaluator, which returns a handle to update and end it.
 func (t *Trace) CreateEvaluator(params EvaluatorParams) (string, error) {
-	return t.client.CreateEvaluator(t.id, params)
+	t.client.warnDeprecated("Trace.CreateEvaluator", "Trace.StartEvaluator")
+	return t.createEvaluator(params, t.client.enqueue)
+}
+
+// createEvaluator creates a new evaluator observation with enqueue and records it in
+// the trace's log
+func (t *Trace) createEvaluator(params EvaluatorParams, enqueue func(Event) error) (string, error) {
+	if err := t.sendCreate(enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.createEvaluator(t.id, params, enqueue)
+	return t.logObservation(id, err, ObservationTypeEvaluator, params.ObservationParams)
 }
 
 // CreateEvaluator creates a new evaluator observation
 func (c *Client) CreateEvaluator(traceID string, params EvaluatorParams) (string, error) {
-	id := generateID()
+	return c.createEvaluator(traceID, params, c.enqueue)
+}
+
+// createEvaluator creates a new evaluator observation, queuing its event with enqueue
+func (c *Client) createEvaluator(traceID string, params EvaluatorParams, enqueue func(Event) error) (string, error) {
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -156,13 +218,13 @@ func (c *Client) CreateEvaluator(traceID string, params EvaluatorParams) (string
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeEvaluatorCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
@@ -171,12 +233,16 @@ func (c *Client) CreateEvaluator(traceID string, params EvaluatorParams) (string
 
 // CreateEmbedding creates a new embedding observation
 func (t *Trace) CreateEmbedding(params EmbeddingParams) (string, error) {
-	return t.client.CreateEmbedding(t.id, params)
+	if err := t.sendCreate(t.client.enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.CreateEmbedding(t.id, params)
+	return t.logObservation(id, err, ObservationTypeEmbedding, params.ObservationParams)
 }
 
 // CreateEmbedding creates a new embedding observation
 func (c *Client) CreateEmbedding(traceID string, params EmbeddingParams) (string, error) {
-	id := generateID()
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -188,16 +254,10 @@ func (c *Client) CreateEmbedding(traceID string, params EmbeddingParams) (string
 		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
 	}
 
-	if params.EmbeddingModel != nil {
-		body["model"] = *params.EmbeddingModel
-	}
-
-	if params.EmbeddingModelParameters != nil {
-		body["modelParameters"] = params.EmbeddingModelParameters
-	}
+	c.addEmbeddingFields(body, params)
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeEmbeddingCreate,
 		Timestamp: time.Now(),
 		Body:      body,
@@ -210,14 +270,51 @@ func (c *Client) CreateEmbedding(traceID string, params EmbeddingParams) (string
 	return id, nil
 }
 
+// UpdateEmbedding updates an existing embedding observation,
+// e.g. to record the output and end time once the embedding call completes.
+// Set params.TraceID to the embedding's trace so the upsert stays on that trace.
+func (c *Client) UpdateEmbedding(embeddingID string, params EmbeddingParams) error {
+	event, err := c.observationUpdateEvent(embeddingID, ObservationTypeEmbedding, params.SpanParams)
+	if err != nil {
+		return err
+	}
+
+	c.addEmbeddingFields(event.Body, params)
+
+	return c.enqueue(event)
+}
+
+// addEmbeddingFields adds the embedding model fields and usage to an event body
+func (c *Client) addEmbeddingFields(body map[string]interface{}, params EmbeddingParams) {
+	if params.EmbeddingModel != nil {
+		body["model"] = *params.EmbeddingModel
+	}
+
+	if params.Provider != nil && *params.Provider != "" {
+		addMetadata(body, MetadataKeyProvider, *params.Provider)
+	}
+
+	if params.EmbeddingModelParameters != nil {
+		body["modelParameters"] = params.EmbeddingModelParameters
+	}
+
+	if params.Usage != nil {
+		body["usage"] = roundedUsage(params.Usage, c.config.CostPrecision)
+	}
+}
+
 // CreateGuardrail creates a new guardrail observation
 func (t *Trace) CreateGuardrail(params GuardrailParams) (string, error) {
-	return t.client.CreateGuardrail(t.id, params)
+	if err := t.sendCreate(t.client.enqueue); err != nil {
+		return "", err
+	}
+	id, err := t.client.CreateGuardrail(t.id, params)
+	return t.logObservation(id, err, ObservationTypeGuardrail, params.ObservationParams)
 }
 
 // CreateGuardrail creates a new guardrail observation
 func (c *Client) CreateGuardrail(traceID string, params GuardrailParams) (string, error) {
-	id := generateID()
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -225,8 +322,12 @@ func (c *Client) CreateGuardrail(traceID string, params GuardrailParams) (string
 	params.TraceID = traceID
 	body := observationToBody(params.ObservationParams, id)
 
+	if params.EndTime != nil {
+		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
+	}
+
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeGuardrailCreate,
 		Timestamp: time.Now(),
 		Body:      body,
@@ -246,7 +347,7 @@ func (c *Client) CreateSdkLog(params SdkLogParams) error {
 	}
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeSdkLog,
 		Timestamp: time.Now(),
 		Body:      body,
@@ -257,18 +358,31 @@ func (c *Client) CreateSdkLog(params SdkLogParams) error {
 
 // UpdateTool updates an existing tool observation
 func (c *Client) UpdateTool(toolID string, params ToolParams) error {
-	body := observationToBody(params.ObservationParams, toolID)
+	return c.UpdateObservation(toolID, ObservationTypeTool, params.SpanParams)
+}
 
-	if params.EndTime != nil {
-		body["endTime"] = params.EndTime.Format(time.RFC3339Nano)
-	}
+// UpdateChain updates an existing chain observation
+func (c *Client) UpdateChain(chainID string, params ChainParams) error {
+	return c.UpdateObservation(chainID, ObservationTypeChain, params.SpanParams)
+}
 
-	event := Event{
-		ID:        generateID(),
-		Type:      EventTypeSpanUpdate,  // Tool 是 Span 的一种，使用 span-update
-		Timestamp: time.Now(),
-		Body:      body,
-	}
+// UpdateRetriever updates an existing retriever observation,
+// e.g. to record the retrieved documents once retrieval completes
+func (c *Client) UpdateRetriever(retrieverID string, params RetrieverParams) error {
+	return c.UpdateObservation(retrieverID, ObservationTypeRetriever, params.SpanParams)
+}
 
-	return c.enqueue(event)
+// UpdateGuardrail updates an existing guardrail observation,
+// e.g. to record the verdict and triggered rules once the check completes
+func (c *Client) UpdateGuardrail(guardrailID string, params GuardrailParams) error {
+	return c.UpdateObservation(guardrailID, ObservationTypeGuardrail, SpanParams{
+		ObservationParams: params.ObservationParams,
+		EndTime:           params.EndTime,
+	})
+}
+
+// UpdateEvaluator updates an existing evaluator observation,
+// e.g. to record the verdict and rationale once the evaluation completes
+func (c *Client) UpdateEvaluator(evaluatorID string, params EvaluatorParams) error {
+	return c.UpdateObservation(evaluatorID, ObservationTypeEvaluator, params.SpanParams)
 }
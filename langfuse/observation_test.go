@@ -0,0 +1,157 @@
+package langfuse
+
+import (
+	"testing"
+	"time"
+)
+
+// onlyEvent returns the body of the single event of type typ the server received
+func onlyEvent(t *testing.T, server *testServer, typ EventType) map[string]interface{} {
+	t.Helper()
+	events := server.eventsOfType(typ)
+	if len(events) != 1 {
+		t.Fatalf("got %d %s events, want 1", len(events), typ)
+	}
+	return events[0].Body
+}
+
+func TestUpdateChainSendsSpanUpdate(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainID, err := trace.CreateChain(NewChain("pipeline"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Date(2026, 1, 2, 3, 4, 5, 120000000, time.FixedZone("CET", 3600))
+	if err := client.UpdateChain(chainID, NewChain("", WithOutput("summary"), WithLevel(LevelWarning), WithEndTime(end))); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	body := onlyEvent(t, server, EventTypeSpanUpdate)
+	if body["id"] != chainID {
+		t.Errorf("update id = %v, want %s", body["id"], chainID)
+	}
+	if body["output"] != "summary" || body["level"] != string(LevelWarning) {
+		t.Errorf("update body = %v, want output summary and level WARNING", body)
+	}
+	if body["endTime"] != "2026-01-02T03:04:05.12+01:00" {
+		t.Errorf("update endTime = %v, want 2026-01-02T03:04:05.12+01:00", body["endTime"])
+	}
+}
+
+func TestUpdateEvaluatorSendsSpanUpdate(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator, err := trace.StartEvaluator(NewEvaluator("judge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verdict := map[string]interface{}{"pass": true}
+	end := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	if err := client.UpdateEvaluator(evaluator.ObservationID(), NewEvaluator("", WithOutput(verdict), WithEndTime(end))); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	body := onlyEvent(t, server, EventTypeSpanUpdate)
+	if body["id"] != evaluator.ObservationID() {
+		t.Errorf("update id = %v, want %s", body["id"], evaluator.ObservationID())
+	}
+	output, _ := body["output"].(map[string]interface{})
+	if output["pass"] != true {
+		t.Errorf("update output = %v, want %v", body["output"], verdict)
+	}
+	if body["endTime"] != "2026-01-02T03:04:05.123456789Z" {
+		t.Errorf("update endTime = %v, want 2026-01-02T03:04:05.123456789Z", body["endTime"])
+	}
+}
+
+func TestUpdateEventUpsertsTheEvent(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventID, err := trace.CreateEvent(NewEvent("cache-miss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := NewEvent("", WithLevel(LevelError), WithStatusMessage("stale"))
+	if err := client.UpdateEvent(eventID, update); err == nil {
+		t.Error("UpdateEvent without a trace ID succeeded")
+	}
+	update.TraceID = trace.ID()
+	if err := client.UpdateEvent(eventID, update); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	events := server.eventsOfType(EventTypeEventCreate)
+	if len(events) != 2 {
+		t.Fatalf("got %d event-create events, want the create and the update", len(events))
+	}
+	body := events[1].Body
+	if body["id"] != eventID {
+		t.Errorf("update id = %v, want %s", body["id"], eventID)
+	}
+	if body["traceId"] != trace.ID() {
+		t.Errorf("update traceId = %v, want %s", body["traceId"], trace.ID())
+	}
+	if body["level"] != string(LevelError) {
+		t.Errorf("update level = %v, want ERROR", body["level"])
+	}
+	if body["statusMessage"] != "stale" {
+		t.Errorf("update statusMessage = %v, want stale", body["statusMessage"])
+	}
+}
+
+func TestProviderIsRecordedInMetadata(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trace.StartGeneration(NewGeneration("chat",
+		WithModel("claude-sonnet"),
+		WithProvider("anthropic"),
+		WithMetadata(map[string]interface{}{"team": "search"}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trace.CreateEmbedding(EmbeddingParams{EmbeddingModel: Ptr("nomic-embed"), Provider: Ptr("local")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	generation := onlyEvent(t, server, EventTypeGenerationCreate)
+	metadata, _ := generation["metadata"].(map[string]interface{})
+	if metadata[MetadataKeyProvider] != "anthropic" || metadata["team"] != "search" {
+		t.Errorf("generation metadata = %v, want the provider next to the caller's metadata", metadata)
+	}
+	if generation["model"] != "claude-sonnet" {
+		t.Errorf("generation model = %v, want it unchanged by the provider", generation["model"])
+	}
+
+	embedding := onlyEvent(t, server, EventTypeEmbeddingCreate)
+	metadata, _ = embedding["metadata"].(map[string]interface{})
+	if metadata[MetadataKeyProvider] != "local" {
+		t.Errorf("embedding metadata = %v, want provider local", metadata)
+	}
+}
@@ -0,0 +1,80 @@
+package langfuse
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// Observe runs fn inside a span named name on trace. The span starts just
+// before fn is called and always gets its EndTime when fn returns, with fn's
+// result as Output. If fn returns an error, the span gets Level ERROR and the
+// error as StatusMessage instead. If fn panics, the span is ended with the
+// panic value and stack trace (see Trace.RecoverAndEnd), the client is flushed
+// and the panic continues.
+//
+// The span is nested under the current observation of ctx, and fn gets a
+// context carrying the trace and the span as the current observation.
+// If trace is nil, the trace carried by ctx is used.
+//
+// fn runs even if the span cannot be created, so tracing never changes what
+// the instrumented code does.
+func Observe(ctx context.Context, trace *Trace, name string, fn func(ctx context.Context) (any, error)) (any, error) {
+	return observe(ctx, trace, name, nil, fn)
+}
+
+// ObserveT is like Observe for a function with a typed input and output.
+// The input is recorded as the span's Input.
+func ObserveT[I, O any](ctx context.Context, trace *Trace, name string, input I, fn func(ctx context.Context, input I) (O, error)) (O, error) {
+	return observe(ctx, trace, name, input, func(ctx context.Context) (O, error) {
+		return fn(ctx, input)
+	})
+}
+
+// observe implements Observe and ObserveT
+func observe[O any](ctx context.Context, trace *Trace, name string, input interface{}, fn func(ctx context.Context) (O, error)) (out O, err error) {
+	if trace == nil {
+		trace = TraceFromContext(ctx)
+	}
+	if trace == nil {
+		return fn(ctx)
+	}
+
+	start := time.Now()
+	span, spanErr := trace.StartSpan(SpanParams{
+		ObservationParams: ObservationParams{
+			Name:                &name,
+			StartTime:           &start,
+			Input:               input,
+			ParentObservationID: parentFromContext(ctx, trace),
+		},
+	})
+	if spanErr != nil {
+		trace.client.config.logger().Debugf("Observe %s: failed to create span: %v", name, spanErr)
+		return fn(ctx)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if endErr := span.EndWithParams(panicParams(r, debug.Stack())); endErr != nil {
+				trace.client.config.logger().Debugf("Observe %s: failed to end span: %v", name, endErr)
+			}
+			flushAfterPanic(trace.client)
+			panic(r)
+		}
+
+		end := time.Now()
+		params := SpanParams{EndTime: &end}
+		if err != nil {
+			params.Level = ptr(LevelError)
+			params.StatusMessage = ptr(err.Error())
+		} else {
+			params.Output = out
+		}
+		if endErr := span.EndWithParams(params); endErr != nil {
+			trace.client.config.logger().Debugf("Observe %s: failed to end span: %v", name, endErr)
+		}
+	}()
+
+	return fn(ContextWithObservation(ContextWithTrace(ctx, trace), span.id))
+}
@@ -0,0 +1,293 @@
+// Package lfopenai records OpenAI chat completions made with
+// github.com/sashabaranov/go-openai as Langfuse observations.
+package lfopenai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// DefaultMaxTurns is the default number of model calls RunToolLoop makes
+// before giving up
+const DefaultMaxTurns = 10
+
+// ErrMaxTurns is returned by RunToolLoop when the model still asks for tool
+// calls after the maximum number of turns
+var ErrMaxTurns = errors.New("lfopenai: model did not finish within the maximum number of turns")
+
+// ChatCompleter creates chat completions. *openai.Client implements it; tests
+// can pass a fake model.
+type ChatCompleter interface {
+	CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+// Option configures RunToolLoop
+type Option func(*options)
+
+type options struct {
+	name     string
+	maxTurns int
+}
+
+// WithName overrides the name of the span that groups the loop's
+// observations, which defaults to "tool-loop"
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithMaxTurns overrides the maximum number of model calls, DefaultMaxTurns
+func WithMaxTurns(n int) Option {
+	return func(o *options) {
+		o.maxTurns = n
+	}
+}
+
+// Result is the outcome of RunToolLoop
+type Result struct {
+	// Response is the last model response, whose first choice has no tool calls
+	Response openai.ChatCompletionResponse
+
+	// Messages is the whole conversation: the request's messages followed by
+	// the assistant messages and tool results of the loop
+	Messages []openai.ChatCompletionMessage
+
+	// Usage is the token usage summed over all model calls
+	Usage openai.Usage
+}
+
+// Content returns the content of the final assistant message
+func (r *Result) Content() string {
+	if len(r.Response.Choices) == 0 {
+		return ""
+	}
+	return r.Response.Choices[0].Message.Content
+}
+
+// RunToolLoop drives a tool-calling conversation: it sends req to client,
+// runs the tools the model asks for with the handler of the same name in
+// toolHandlers, appends their results to the messages and calls the model
+// again, until the model answers without tool calls. A handler gets the JSON
+// arguments chosen by the model; a string result is passed to the model as is,
+// anything else is encoded as JSON.
+//
+// Everything is recorded on trace under one span: a generation per model call,
+// with its messages, tools, model parameters and usage, and a tool observation
+// per tool call, with its arguments and result. The span gets the final answer
+// as Output and the summed usage in its metadata. It is nested under the
+// current observation of ctx, as with langfuse.Observe.
+//
+// A tool that fails or has no handler is recorded with Level ERROR, and its
+// error is passed to the model as the tool result, so the model can recover.
+// A failed model call ends the loop with its error, as does reaching the
+// maximum number of turns (ErrMaxTurns).
+func RunToolLoop(ctx context.Context, trace *langfuse.Trace, client ChatCompleter, req openai.ChatCompletionRequest, toolHandlers map[string]func(args json.RawMessage) (any, error), opts ...Option) (*Result, error) {
+	o := options{name: "tool-loop", maxTurns: DefaultMaxTurns}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if req.Stream {
+		return nil, fmt.Errorf("lfopenai: RunToolLoop does not support streaming requests")
+	}
+
+	spanOpts := []langfuse.SpanOption{langfuse.WithInput(req.Messages)}
+	if ctxTrace := langfuse.TraceFromContext(ctx); ctxTrace == nil || ctxTrace == trace {
+		if parent := langfuse.ObservationIDFromContext(ctx); parent != "" {
+			spanOpts = append(spanOpts, langfuse.WithParent(parent))
+		}
+	}
+	span, err := trace.StartSpan(langfuse.NewSpan(o.name, spanOpts...))
+	if err != nil {
+		return nil, err
+	}
+
+	loop := &toolLoop{trace: trace, parent: span.ObservationID(), client: client, handlers: toolHandlers}
+	result, err := loop.run(ctx, req, o.maxTurns)
+
+	end := langfuse.SpanParams{
+		ObservationParams: langfuse.ObservationParams{
+			Metadata: map[string]interface{}{"usage": result.Usage},
+		},
+	}
+	if err != nil {
+		end.Level = langfuse.Ptr(langfuse.LevelError)
+		end.StatusMessage = langfuse.Ptr(err.Error())
+	} else {
+		end.Output = result.Content()
+	}
+	if endErr := span.EndWithParams(end); endErr != nil && err == nil {
+		err = endErr
+	}
+	return result, err
+}
+
+// toolLoop holds the state of one RunToolLoop call
+type toolLoop struct {
+	trace    *langfuse.Trace
+	parent   string
+	client   ChatCompleter
+	handlers map[string]func(args json.RawMessage) (any, error)
+}
+
+// run calls the model and the tools it asks for until the model answers
+func (l *toolLoop) run(ctx context.Context, req openai.ChatCompletionRequest, maxTurns int) (*Result, error) {
+	result := &Result{Messages: append([]openai.ChatCompletionMessage(nil), req.Messages...)}
+
+	for turn := 1; turn <= maxTurns; turn++ {
+		req.Messages = result.Messages
+		resp, err := l.generate(ctx, req, turn)
+		if err != nil {
+			return result, err
+		}
+		result.Response = resp
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+
+		if len(resp.Choices) == 0 {
+			return result, fmt.Errorf("lfopenai: model returned no choices")
+		}
+		message := resp.Choices[0].Message
+		result.Messages = append(result.Messages, message)
+		if len(message.ToolCalls) == 0 {
+			return result, nil
+		}
+
+		for _, call := range message.ToolCalls {
+			result.Messages = append(result.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    l.callTool(call),
+				ToolCallID: call.ID,
+			})
+		}
+	}
+	return result, ErrMaxTurns
+}
+
+// generate makes one model call, recorded as a generation
+func (l *toolLoop) generate(ctx context.Context, req openai.ChatCompletionRequest, turn int) (openai.ChatCompletionResponse, error) {
+	input := map[string]interface{}{"messages": req.Messages}
+	if len(req.Tools) > 0 {
+		input["tools"] = req.Tools
+	}
+	params := langfuse.NewGeneration("chat-completion",
+		langfuse.WithParent(l.parent),
+		langfuse.WithStartTime(time.Now()),
+		langfuse.WithInput(input),
+		langfuse.WithMetadata(map[string]interface{}{"turn": turn}),
+		langfuse.WithModel(req.Model),
+		langfuse.WithProvider("openai"),
+	)
+	params.ModelParameters = modelParameters(req)
+
+	generation, genErr := l.trace.StartGeneration(params)
+	resp, err := l.client.CreateChatCompletion(ctx, req)
+	if genErr != nil {
+		return resp, err
+	}
+
+	end := langfuse.GenerationParams{}
+	if err != nil {
+		end.Level = langfuse.Ptr(langfuse.LevelError)
+		end.StatusMessage = langfuse.Ptr(err.Error())
+	} else {
+		if len(resp.Choices) > 0 {
+			end.Output = resp.Choices[0].Message
+		}
+		usage := langfuse.UsageFromOpenAI(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens)
+		end.Usage = &usage
+	}
+	generation.EndWithParams(end)
+	return resp, err
+}
+
+// callTool runs one tool call, recorded as a tool observation, and returns
+// the content of the tool message for the model
+func (l *toolLoop) callTool(call openai.ToolCall) string {
+	start := time.Now()
+	tool, toolErr := l.trace.StartTool(langfuse.NewTool(call.Function.Name,
+		langfuse.WithParent(l.parent),
+		langfuse.WithStartTime(start),
+		langfuse.WithInput(map[string]interface{}{
+			"tool_call_id": call.ID,
+			"arguments":    toolArguments(call.Function.Arguments),
+		}),
+	))
+
+	content, err := l.runHandler(call)
+
+	if toolErr == nil {
+		end := langfuse.ToolParams{}
+		if err != nil {
+			end.Level = langfuse.Ptr(langfuse.LevelError)
+			end.StatusMessage = langfuse.Ptr(err.Error())
+		} else {
+			end.Output = content
+		}
+		tool.EndWithParams(end)
+	}
+
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return content
+}
+
+// runHandler runs the handler of a tool call and encodes its result
+func (l *toolLoop) runHandler(call openai.ToolCall) (string, error) {
+	handler, ok := l.handlers[call.Function.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	value, err := handler(json.RawMessage(call.Function.Arguments))
+	if err != nil {
+		return "", err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result of tool %q: %w", call.Function.Name, err)
+	}
+	return string(data), nil
+}
+
+// toolArguments decodes a tool call's arguments for recording, keeping them
+// as a string if they are not valid JSON
+func toolArguments(arguments string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(arguments), &decoded); err != nil {
+		return arguments
+	}
+	return decoded
+}
+
+// modelParameters returns the sampling parameters set on req
+func modelParameters(req openai.ChatCompletionRequest) map[string]interface{} {
+	params := make(map[string]interface{})
+	if req.Temperature != 0 {
+		params["temperature"] = req.Temperature
+	}
+	if req.TopP != 0 {
+		params["top_p"] = req.TopP
+	}
+	if req.MaxTokens != 0 {
+		params["max_tokens"] = req.MaxTokens
+	}
+	if req.Seed != nil {
+		params["seed"] = *req.Seed
+	}
+	if req.ToolChoice != nil {
+		params["tool_choice"] = req.ToolChoice
+	}
+	return params
+}
@@ -0,0 +1,164 @@
+package lfopenai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/voicefoxai/langfuse-gosdk/langfuse"
+)
+
+// newClient returns a client sending to a fake ingestion API, and a function
+// flushing it and returning the events it received
+func newClient(t *testing.T) (*langfuse.Client, func() []langfuse.Event) {
+	var mu sync.Mutex
+	var events []langfuse.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req langfuse.IngestionRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		mu.Lock()
+		events = append(events, req.Batch...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"successes":[],"errors":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := langfuse.DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = server.URL
+	config.FlushInterval = time.Hour
+	client, err := langfuse.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, func() []langfuse.Event {
+		if err := client.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]langfuse.Event(nil), events...)
+	}
+}
+
+// fakeModel answers with its responses in turn
+type fakeModel struct {
+	responses []openai.ChatCompletionResponse
+	requests  []openai.ChatCompletionRequest
+}
+
+func (m *fakeModel) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	m.requests = append(m.requests, req)
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	return resp, nil
+}
+
+// reply returns a response with one assistant message
+func reply(message openai.ChatCompletionMessage, tokens int) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: message}},
+		Usage:   openai.Usage{PromptTokens: tokens, CompletionTokens: tokens, TotalTokens: 2 * tokens},
+	}
+}
+
+func TestRunToolLoop(t *testing.T) {
+	client, events := newClient(t)
+	trace, err := client.CreateTrace(langfuse.TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := &fakeModel{responses: []openai.ChatCompletionResponse{
+		reply(openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{
+				{ID: "call-1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call-2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "book_flight", Arguments: `{}`}},
+			},
+		}, 10),
+		reply(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Sunny in Paris"}, 5),
+	}}
+	var city string
+	handlers := map[string]func(args json.RawMessage) (any, error){
+		"get_weather": func(args json.RawMessage) (any, error) {
+			var params struct{ City string }
+			json.Unmarshal(args, &params)
+			city = params.City
+			return map[string]string{"forecast": "sunny"}, nil
+		},
+	}
+
+	result, err := RunToolLoop(context.Background(), trace, model, openai.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Weather in Paris?"}},
+	}, handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Content() != "Sunny in Paris" {
+		t.Errorf("Content = %q, want the final answer", result.Content())
+	}
+	if result.Usage.TotalTokens != 30 {
+		t.Errorf("Usage.TotalTokens = %d, want 30 summed over both calls", result.Usage.TotalTokens)
+	}
+	if city != "Paris" {
+		t.Errorf("get_weather got city %q, want Paris", city)
+	}
+	if len(result.Messages) != 5 {
+		t.Errorf("got %d messages, want the user message, two answers and two tool results", len(result.Messages))
+	}
+	second := model.requests[1].Messages
+	if got := second[len(second)-2].Content; got != `{"forecast":"sunny"}` {
+		t.Errorf("tool result passed to the model = %q", got)
+	}
+	if got := second[len(second)-1].Content; got != `error: unknown tool "book_flight"` {
+		t.Errorf("missing tool result passed to the model = %q", got)
+	}
+
+	var spanID string
+	parents := map[langfuse.EventType][]interface{}{}
+	levels := map[string]interface{}{}
+	for _, e := range events() {
+		switch e.Type {
+		case langfuse.EventTypeSpanCreate:
+			spanID, _ = e.Body["id"].(string)
+		case langfuse.EventTypeGenerationCreate, langfuse.EventTypeToolCreate:
+			parents[e.Type] = append(parents[e.Type], e.Body["parentObservationId"])
+		case langfuse.EventTypeSpanUpdate:
+			id, _ := e.Body["id"].(string)
+			levels[id] = e.Body["level"]
+		}
+	}
+	if len(parents[langfuse.EventTypeGenerationCreate]) != 2 || len(parents[langfuse.EventTypeToolCreate]) != 2 {
+		t.Fatalf("recorded %d generations and %d tools, want 2 of each",
+			len(parents[langfuse.EventTypeGenerationCreate]), len(parents[langfuse.EventTypeToolCreate]))
+	}
+	for typ, ids := range parents {
+		for _, parent := range ids {
+			if parent != spanID {
+				t.Errorf("%s has parent %v, want the loop span %s", typ, parent, spanID)
+			}
+		}
+	}
+	var failed int
+	for _, level := range levels {
+		if level == string(langfuse.LevelError) {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d observations ended with level ERROR, want the unknown tool", failed)
+	}
+}
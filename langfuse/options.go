@@ -0,0 +1,259 @@
+package langfuse
+
+import (
+	"time"
+)
+
+// SpanOption configures the params built by NewSpan, NewTool and NewGeneration
+type SpanOption func(*SpanParams)
+
+// GenerationOption configures the params built by NewGeneration.
+// Every SpanOption is also a GenerationOption.
+type GenerationOption interface {
+	applyGeneration(*GenerationParams)
+}
+
+func (o SpanOption) applyGeneration(p *GenerationParams) {
+	o(&p.SpanParams)
+}
+
+// generationOption is a GenerationOption that only applies to generations
+type generationOption func(*GenerationParams)
+
+func (o generationOption) applyGeneration(p *GenerationParams) {
+	o(p)
+}
+
+// NewSpan returns SpanParams with the given name and options applied
+func NewSpan(name string, opts ...SpanOption) SpanParams {
+	var params SpanParams
+	if name != "" {
+		params.Name = &name
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return params
+}
+
+// NewTool returns ToolParams with the given name and options applied
+func NewTool(name string, opts ...SpanOption) ToolParams {
+	return ToolParams{SpanParams: NewSpan(name, opts...)}
+}
+
+// NewAgent returns AgentParams with the given name and options applied
+func NewAgent(name string, opts ...SpanOption) AgentParams {
+	return AgentParams{SpanParams: NewSpan(name, opts...)}
+}
+
+// NewChain returns ChainParams with the given name and options applied
+func NewChain(name string, opts ...SpanOption) ChainParams {
+	return ChainParams{SpanParams: NewSpan(name, opts...)}
+}
+
+// NewRetriever returns RetrieverParams with the given name and options applied
+func NewRetriever(name string, opts ...SpanOption) RetrieverParams {
+	return RetrieverParams{SpanParams: NewSpan(name, opts...)}
+}
+
+// NewEvaluator returns EvaluatorParams with the given name and options applied
+func NewEvaluator(name string, opts ...SpanOption) EvaluatorParams {
+	return EvaluatorParams{SpanParams: NewSpan(name, opts...)}
+}
+
+// NewEvent returns EventParams with the given name and options applied.
+// Events have no end time, so WithEndTime is ignored.
+func NewEvent(name string, opts ...SpanOption) EventParams {
+	return EventParams{ObservationParams: NewSpan(name, opts...).ObservationParams}
+}
+
+// NewGeneration returns GenerationParams with the given name and options applied
+func NewGeneration(name string, opts ...GenerationOption) GenerationParams {
+	var params GenerationParams
+	if name != "" {
+		params.Name = &name
+	}
+	for _, opt := range opts {
+		opt.applyGeneration(&params)
+	}
+	return params
+}
+
+// WithInput sets the observation input
+func WithInput(input interface{}) SpanOption {
+	return func(p *SpanParams) {
+		p.Input = input
+	}
+}
+
+// WithOutput sets the observation output
+func WithOutput(output interface{}) SpanOption {
+	return func(p *SpanParams) {
+		p.Output = output
+	}
+}
+
+// WithID sets the observation ID instead of generating one
+func WithID(id string) SpanOption {
+	return func(p *SpanParams) {
+		p.ID = &id
+	}
+}
+
+// WithEndTime sets the observation end time
+func WithEndTime(t time.Time) SpanOption {
+	return func(p *SpanParams) {
+		p.EndTime = &t
+	}
+}
+
+// WithVersion sets the observation version
+func WithVersion(version string) SpanOption {
+	return func(p *SpanParams) {
+		p.Version = &version
+	}
+}
+
+// WithEnvironment sets the observation environment
+func WithEnvironment(environment string) SpanOption {
+	return func(p *SpanParams) {
+		p.Environment = &environment
+	}
+}
+
+// WithStartTime sets the observation start time
+func WithStartTime(t time.Time) SpanOption {
+	return func(p *SpanParams) {
+		p.StartTime = &t
+	}
+}
+
+// WithMetadata adds metadata to the observation.
+// Multiple WithMetadata options are merged, later keys win.
+func WithMetadata(metadata map[string]interface{}) SpanOption {
+	return func(p *SpanParams) {
+		if p.Metadata == nil {
+			p.Metadata = make(map[string]interface{}, len(metadata))
+		}
+		for k, v := range metadata {
+			p.Metadata[k] = v
+		}
+	}
+}
+
+// WithParent sets the parent observation ID
+func WithParent(parentObservationID string) SpanOption {
+	return func(p *SpanParams) {
+		p.ParentObservationID = &parentObservationID
+	}
+}
+
+// WithLevel sets the observation level
+func WithLevel(level ObservationLevel) SpanOption {
+	return func(p *SpanParams) {
+		p.Level = &level
+	}
+}
+
+// WithStatusMessage sets the observation status message
+func WithStatusMessage(message string) SpanOption {
+	return func(p *SpanParams) {
+		p.StatusMessage = &message
+	}
+}
+
+// WithModel sets the generation model
+func WithModel(model string) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.Model = &model
+	})
+}
+
+// WithProvider sets the generation's model provider, e.g. "openai"
+func WithProvider(provider string) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.Provider = &provider
+	})
+}
+
+// WithModelParameters sets the parameters passed to the model
+func WithModelParameters(modelParameters map[string]interface{}) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.ModelParameters = modelParameters
+	})
+}
+
+// WithUsage sets the generation token usage
+func WithUsage(usage Usage) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.Usage = &usage
+	})
+}
+
+// WithAudioUsage sets the audio token usage of audio models
+func WithAudioUsage(usage AudioUsage) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.AudioUsage = &usage
+	})
+}
+
+// WithCompletionStartTime sets when the completion started streaming
+func WithCompletionStartTime(t time.Time) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.CompletionStartTime = &t
+	})
+}
+
+// WithPromptVersion links the generation to a prompt by name and version,
+// when the Prompt itself is not at hand (see WithPrompt)
+func WithPromptVersion(name string, version int) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.PromptName = &name
+		p.PromptVersion = &version
+	})
+}
+
+// WithOutputSchema sets the JSON schema the generation's output should conform to
+func WithOutputSchema(schema interface{}) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.OutputSchema = schema
+	})
+}
+
+// EndSpan sets the span's EndTime to now, unless WithEndTime is given, along
+// with any fields set by opts (e.g. WithOutput, WithLevel, WithStatusMessage).
+// It sends the same event as UpdateSpan. With a SpanHandle at hand, use its End
+// or EndWithParams instead.
+func (c *Client) EndSpan(spanID string, opts ...SpanOption) error {
+	return c.UpdateSpan(spanID, endSpanParams(opts))
+}
+
+// EndGeneration sets the generation's EndTime to now, unless WithEndTime is
+// given, along with any fields set by opts (e.g. WithOutput, WithUsage). It
+// sends the same event as UpdateGeneration. With a GenerationHandle at hand,
+// use its End or EndWithParams instead, which also sends recorded chunk usage.
+func (c *Client) EndGeneration(generationID string, opts ...GenerationOption) error {
+	params := NewGeneration("", opts...)
+	params.EndTime = endTime(params.EndTime)
+	return c.UpdateGeneration(generationID, params)
+}
+
+// EndTool sets the tool observation's EndTime to now, along with any fields set
+// by opts. It sends the same event as UpdateTool.
+func (c *Client) EndTool(toolID string, opts ...SpanOption) error {
+	return c.UpdateTool(toolID, ToolParams{SpanParams: endSpanParams(opts)})
+}
+
+// EndAgent sets the agent observation's EndTime to now, along with any fields
+// set by opts
+func (c *Client) EndAgent(agentID string, opts ...SpanOption) error {
+	return c.UpdateObservation(agentID, ObservationTypeAgent, endSpanParams(opts))
+}
+
+// endSpanParams returns SpanParams with the options applied and EndTime set
+// to now unless WithEndTime was given
+func endSpanParams(opts []SpanOption) SpanParams {
+	params := NewSpan("", opts...)
+	params.EndTime = endTime(params.EndTime)
+	return params
+}
@@ -0,0 +1,59 @@
+package langfuse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndSpanSendsTheUpdateSpanEvent(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	end := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := client.EndSpan("span-1", WithOutput("done"), WithLevel(LevelWarning), WithEndTime(end)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateSpan("span-2", NewSpan("", WithOutput("done"), WithLevel(LevelWarning), WithEndTime(end))); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := server.eventsOfType(EventTypeSpanUpdate)
+	if len(updates) != 2 {
+		t.Fatalf("got %d span updates, want 2", len(updates))
+	}
+	ended, updated := updates[0].Body, updates[1].Body
+	for _, key := range []string{"output", "level", "endTime"} {
+		if ended[key] != updated[key] {
+			t.Errorf("EndSpan sent %s = %v, UpdateSpan %v", key, ended[key], updated[key])
+		}
+	}
+}
+
+func TestEndGenerationDefaultsEndTimeToNow(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	before := time.Now()
+	if err := client.EndGeneration("gen-1", WithOutput("hi"), WithUsage(Usage{Output: Ptr(3)})); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := server.eventsOfType(EventTypeGenerationUpdate)
+	if len(updates) != 1 {
+		t.Fatalf("got %d generation updates, want 1", len(updates))
+	}
+	body := updates[0].Body
+	endTime, err := time.Parse(time.RFC3339Nano, body["endTime"].(string))
+	if err != nil {
+		t.Fatalf("endTime %v: %v", body["endTime"], err)
+	}
+	if endTime.Before(before.Truncate(time.Millisecond)) {
+		t.Errorf("endTime = %v, want now", endTime)
+	}
+	usage, _ := body["usage"].(map[string]interface{})
+	if usage["output"] != 3.0 {
+		t.Errorf("usage output = %v, want 3", usage["output"])
+	}
+}
@@ -0,0 +1,75 @@
+package langfuse
+
+import (
+	"sort"
+	"time"
+)
+
+// TracesChronological returns a copy of the session's traces sorted oldest
+// first. The server returns session traces newest first; see sortTraces for
+// how traces are ordered.
+func (s *SessionWithTraces) TracesChronological() []TraceWithFullDetails {
+	return sortTraces(s.Traces, true)
+}
+
+// SortedByTimestamp returns a copy of the page's traces sorted by timestamp,
+// oldest first if asc is true and newest first otherwise.
+// See sortTraces for how traces are ordered.
+func (p *PaginatedTraces) SortedByTimestamp(asc bool) []TraceWithFullDetails {
+	return sortTraces(p.Data, asc)
+}
+
+// sortTraces returns a sorted copy of traces.
+// Traces are ordered by Timestamp (when the trace started), falling back to
+// CreatedAt (when the server stored it) if Timestamp is missing or invalid.
+// Ties are broken by CreatedAt; traces with neither time come last, in their
+// original order.
+func sortTraces(traces []TraceWithFullDetails, asc bool) []TraceWithFullDetails {
+	type keyedTrace struct {
+		trace       TraceWithFullDetails
+		at, created time.Time
+		ok          bool
+	}
+
+	keyed := make([]keyedTrace, len(traces))
+	for i, t := range traces {
+		created, createdOK := parseTime(t.CreatedAt)
+		at, ok := parseTime(&t.Timestamp)
+		if !ok {
+			at, ok = created, createdOK
+		}
+		keyed[i] = keyedTrace{trace: t, at: at, created: created, ok: ok}
+	}
+
+	sort.SliceStable(keyed, func(i, j int) bool {
+		a, b := keyed[i], keyed[j]
+		if a.ok != b.ok {
+			return a.ok
+		}
+		if !a.at.Equal(b.at) {
+			return a.at.Before(b.at) == asc
+		}
+		if !a.created.Equal(b.created) {
+			return a.created.Before(b.created) == asc
+		}
+		return false
+	})
+
+	sorted := make([]TraceWithFullDetails, len(keyed))
+	for i, k := range keyed {
+		sorted[i] = k.trace
+	}
+	return sorted
+}
+
+// parseTime parses an RFC 3339 API timestamp
+func parseTime(s *string) (time.Time, bool) {
+	if s == nil || *s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, *s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
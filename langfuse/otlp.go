@@ -0,0 +1,748 @@
+package langfuse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport selects the API events are sent to
+type Transport string
+
+const (
+	// TransportIngestion sends events to the batch ingestion API
+	TransportIngestion Transport = "ingestion"
+
+	// TransportOTLP sends observations as OTLP spans to the OpenTelemetry
+	// endpoint, with the attributes of their trace. Scores, SDK logs and
+	// traces without an observation in the batch, which have no OTLP form,
+	// are still sent to the ingestion API, under the OTLP IDs.
+	TransportOTLP Transport = "otlp"
+)
+
+// Langfuse stores OTLP spans under their OTLP IDs: trace IDs are 32 and span
+// IDs 16 hex characters. UUID trace IDs map to their hex digits; other trace
+// IDs and observation IDs that are not already 16 hex characters are hashed.
+// With TransportOTLP, the client maps the IDs it is given to OTLP IDs in
+// GetTrace, GetObservation, ListScores, DeleteTrace, DeleteTraces and
+// TraceURL, so the IDs of Trace and the observation handles can be used as
+// they are. Use OTLPTraceID and OTLPSpanID to look up traces and observations
+// elsewhere, e.g. in the Langfuse UI.
+
+// OTLPTraceID returns the ID Langfuse stores a trace under when it was sent
+// with TransportOTLP
+func OTLPTraceID(traceID string) string {
+	hexID := strings.ToLower(strings.ReplaceAll(traceID, "-", ""))
+	if len(hexID) == 32 {
+		if _, err := hex.DecodeString(hexID); err == nil {
+			return hexID
+		}
+	}
+	sum := sha256.Sum256([]byte(traceID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// OTLPSpanID returns the ID Langfuse stores an observation under when it was
+// sent with TransportOTLP
+func OTLPSpanID(observationID string) string {
+	if len(observationID) == 16 && strings.ToLower(observationID) == observationID {
+		if _, err := hex.DecodeString(observationID); err == nil {
+			return observationID
+		}
+	}
+	sum := sha256.Sum256([]byte(observationID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// serverTraceID returns the ID Langfuse stores a trace created by the client
+// under: its OTLP ID with TransportOTLP, and otherwise the ID itself
+func (c *clientCore) serverTraceID(traceID string) string {
+	if c.config.Transport == TransportOTLP {
+		return OTLPTraceID(traceID)
+	}
+	return traceID
+}
+
+// serverObservationID is serverTraceID for observations
+func (c *clientCore) serverObservationID(observationID string) string {
+	if c.config.Transport == TransportOTLP {
+		return OTLPSpanID(observationID)
+	}
+	return observationID
+}
+
+// Span attributes read by the Langfuse OpenTelemetry endpoint
+const (
+	otlpAttrObservationType          = "langfuse.observation.type"
+	otlpAttrObservationInput         = "langfuse.observation.input"
+	otlpAttrObservationOutput        = "langfuse.observation.output"
+	otlpAttrObservationMetadata      = "langfuse.observation.metadata."
+	otlpAttrObservationLevel         = "langfuse.observation.level"
+	otlpAttrObservationStatusMessage = "langfuse.observation.status_message"
+	otlpAttrObservationModel         = "langfuse.observation.model.name"
+	otlpAttrObservationModelParams   = "langfuse.observation.model.parameters"
+	otlpAttrObservationUsage         = "langfuse.observation.usage_details"
+	otlpAttrObservationCost          = "langfuse.observation.cost_details"
+	otlpAttrObservationPromptName    = "langfuse.observation.prompt.name"
+	otlpAttrObservationPromptVersion = "langfuse.observation.prompt.version"
+	otlpAttrObservationCompletion    = "langfuse.observation.completion_start_time"
+	otlpAttrVersion                  = "langfuse.version"
+	otlpAttrRelease                  = "langfuse.release"
+	otlpAttrEnvironment              = "langfuse.environment"
+	otlpAttrTraceName                = "langfuse.trace.name"
+	otlpAttrTraceInput               = "langfuse.trace.input"
+	otlpAttrTraceOutput              = "langfuse.trace.output"
+	otlpAttrTraceMetadata            = "langfuse.trace.metadata."
+	otlpAttrTraceTags                = "langfuse.trace.tags"
+	otlpAttrTracePublic              = "langfuse.trace.public"
+	otlpAttrUserID                   = "user.id"
+	otlpAttrSessionID                = "session.id"
+)
+
+// OTLP/JSON export request, see opentelemetry-proto's trace_service.proto
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpStatusError is STATUS_CODE_ERROR
+const otlpStatusError = 2
+
+// otlpSpanKindInternal is SPAN_KIND_INTERNAL
+const otlpSpanKindInternal = 1
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string    `json:"stringValue,omitempty"`
+	BoolValue   *bool      `json:"boolValue,omitempty"`
+	IntValue    *string    `json:"intValue,omitempty"` // int64 as a decimal string
+	ArrayValue  *otlpArray `json:"arrayValue,omitempty"`
+}
+
+type otlpArray struct {
+	Values []otlpValue `json:"values"`
+}
+
+type otlpExportResponse struct {
+	PartialSuccess *struct {
+		RejectedSpans json.Number `json:"rejectedSpans"`
+		ErrorMessage  string      `json:"errorMessage"`
+	} `json:"partialSuccess,omitempty"`
+}
+
+// otlpSpanInfo is what an update needs to know about the span it updates
+type otlpSpanInfo struct {
+	traceID  string // OTLP trace ID
+	parentID string // OTLP span ID of the parent, if any
+	name     string
+	obsType  string
+	start    time.Time
+}
+
+// maxOTLPSpanInfos bounds the remembered spans; the oldest are forgotten first
+const maxOTLPSpanInfos = 10000
+
+// otlpState remembers the spans sent with TransportOTLP, because an OTLP span
+// must carry its trace, parent, name and start time even when it only updates
+// an observation
+type otlpState struct {
+	mu    sync.Mutex
+	spans map[string]otlpSpanInfo // by observation ID
+	order []string                // observation IDs, oldest first
+}
+
+func newOTLPState() *otlpState {
+	return &otlpState{spans: make(map[string]otlpSpanInfo)}
+}
+
+// remember stores the info of an observation's span
+func (s *otlpState) remember(id string, info otlpSpanInfo) {
+	if _, ok := s.spans[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.spans[id] = info
+	for len(s.order) > maxOTLPSpanInfos {
+		delete(s.spans, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// otlpGroup collects the events of one trace or observation in a batch
+type otlpGroup struct {
+	id      string
+	isTrace bool
+	obsType string // set by a create event or generation-update
+	created bool   // whether the batch has a create event for it
+	body    map[string]interface{}
+	first   time.Time
+	events  []Event
+}
+
+// otlpObservationTypes maps create events to the observation type Langfuse expects
+var otlpObservationTypes = map[EventType]string{
+	EventTypeSpanCreate:       "span",
+	EventTypeEventCreate:      "event",
+	EventTypeGenerationCreate: "generation",
+	EventTypeAgentCreate:      "agent",
+	EventTypeToolCreate:       "tool",
+	EventTypeChainCreate:      "chain",
+	EventTypeRetrieverCreate:  "retriever",
+	EventTypeEvaluatorCreate:  "evaluator",
+	EventTypeEmbeddingCreate:  "embedding",
+	EventTypeGuardrailCreate:  "guardrail",
+}
+
+// convert turns the trace and observation events of a batch into OTLP spans,
+// merging the events of each observation into one span and putting the
+// attributes of a trace on one of its spans. It returns the spans, the events
+// they represent, the events that must go to the ingestion API, and the
+// events that cannot be sent. Scores, and traces without a span in the batch,
+// go to the ingestion API with their IDs rewritten to OTLP IDs, as do SDK
+// logs. Updates of observations whose trace or start time is unknown, e.g.
+// because they were created by an earlier process, cannot be sent: a span
+// without them would be stored as a new observation.
+func (s *otlpState) convert(events []Event) (spans []otlpSpan, sent, rest, unsendable []Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var groups []*otlpGroup
+	byKey := make(map[string]*otlpGroup)
+	for _, e := range events {
+		_, isCreate := otlpObservationTypes[e.Type]
+		isObservation := isCreate || e.Type == EventTypeSpanUpdate || e.Type == EventTypeGenerationUpdate
+		if e.Type == EventTypeScoreCreate {
+			rest = append(rest, otlpScoreEvent(e))
+			continue
+		}
+		if e.Type != EventTypeTraceCreate && !isObservation {
+			rest = append(rest, e)
+			continue
+		}
+
+		body, err := normalizeBody(e.Body)
+		id, _ := body["id"].(string)
+		if err != nil || id == "" {
+			rest = append(rest, e)
+			continue
+		}
+
+		key := "observation:" + id
+		if e.Type == EventTypeTraceCreate {
+			key = "trace:" + id
+		}
+		group := byKey[key]
+		if group == nil {
+			group = &otlpGroup{id: id, isTrace: e.Type == EventTypeTraceCreate, body: body, first: e.Timestamp}
+			byKey[key] = group
+			groups = append(groups, group)
+		} else {
+			mergeBody(group.body, body)
+		}
+		if obsType, ok := otlpObservationTypes[e.Type]; ok {
+			group.obsType = obsType
+			group.created = true
+		}
+		if e.Type == EventTypeGenerationUpdate && group.obsType == "" {
+			group.obsType = "generation"
+		}
+		group.events = append(group.events, e)
+	}
+
+	// Observations first, so trace attributes can be put on one of their spans
+	var traces []*otlpGroup
+	spanOfTrace := make(map[string]int)
+	for _, group := range groups {
+		if group.isTrace {
+			traces = append(traces, group)
+			continue
+		}
+		span, ok := s.observationSpan(group)
+		if !ok {
+			unsendable = append(unsendable, group.events...)
+			continue
+		}
+		if _, seen := spanOfTrace[span.TraceID]; !seen {
+			spanOfTrace[span.TraceID] = len(spans)
+		}
+		spans = append(spans, span)
+		sent = append(sent, group.events...)
+	}
+
+	for _, group := range traces {
+		i, ok := spanOfTrace[OTLPTraceID(group.id)]
+		if !ok {
+			// Without a span to carry them, the trace's attributes would
+			// need a span that is not an observation of the trace
+			for _, e := range group.events {
+				rest = append(rest, otlpTraceEvent(e))
+			}
+			continue
+		}
+		spans[i].Attributes = append(spans[i].Attributes, traceAttributes(group.body)...)
+		sent = append(sent, group.events...)
+	}
+
+	return spans, sent, rest, unsendable
+}
+
+// observationSpan builds the span of an observation, completing updates with
+// what is remembered from earlier spans. It returns false if the span's trace,
+// type or start time is unknown.
+func (s *otlpState) observationSpan(group *otlpGroup) (otlpSpan, bool) {
+	body := group.body
+	info, known := s.spans[group.id]
+
+	if traceID, ok := body["traceId"].(string); ok && traceID != "" {
+		info.traceID = OTLPTraceID(traceID)
+	}
+	if parentID, ok := body["parentObservationId"].(string); ok && parentID != "" {
+		info.parentID = OTLPSpanID(parentID)
+	}
+	if name, ok := body["name"].(string); ok && name != "" {
+		info.name = name
+	}
+	if group.obsType != "" {
+		info.obsType = group.obsType
+	}
+	if !known && !group.created {
+		return otlpSpan{}, false // an update of a span that was never seen
+	}
+	if _, ok := body["startTime"]; ok || !known {
+		info.start = bodyTime(body, "startTime", group.first)
+	}
+	if info.traceID == "" || info.obsType == "" {
+		return otlpSpan{}, false
+	}
+	s.remember(group.id, info)
+
+	end := bodyTime(body, "endTime", info.start)
+	name := info.name
+	if name == "" {
+		name = info.obsType
+	}
+
+	span := otlpSpan{
+		TraceID:           info.traceID,
+		SpanID:            OTLPSpanID(group.id),
+		ParentSpanID:      info.parentID,
+		Name:              name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(info.start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes:        observationAttributes(info.obsType, body),
+	}
+	if level, _ := body["level"].(string); level == string(LevelError) {
+		message, _ := body["statusMessage"].(string)
+		span.Status = &otlpStatus{Code: otlpStatusError, Message: message}
+	}
+	return span, true
+}
+
+// observationAttributes maps an observation body to span attributes
+func observationAttributes(obsType string, body map[string]interface{}) []otlpAttribute {
+	attributes := []otlpAttribute{stringAttribute(otlpAttrObservationType, obsType)}
+
+	for key, value := range body {
+		switch key {
+		case "id", "traceId", "parentObservationId", "name", "startTime", "endTime":
+			// Part of the span itself
+		case "input":
+			attributes = append(attributes, ioAttribute(otlpAttrObservationInput, value))
+		case "output":
+			attributes = append(attributes, ioAttribute(otlpAttrObservationOutput, value))
+		case "metadata":
+			attributes = append(attributes, metadataAttributes(otlpAttrObservationMetadata, value)...)
+		case "level":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationLevel, value))
+		case "statusMessage":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationStatusMessage, value))
+		case "model":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationModel, value))
+		case "modelParameters":
+			attributes = append(attributes, jsonAttribute(otlpAttrObservationModelParams, value))
+		case "usage", "audioUsage":
+			// Merged below
+		case "promptName":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationPromptName, value))
+		case "promptVersion":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationPromptVersion, value))
+		case "completionStartTime":
+			attributes = append(attributes, valueAttribute(otlpAttrObservationCompletion, value))
+		case "version":
+			attributes = append(attributes, valueAttribute(otlpAttrVersion, value))
+		case "environment":
+			attributes = append(attributes, valueAttribute(otlpAttrEnvironment, value))
+		default:
+			attributes = append(attributes, valueAttribute(otlpAttrObservationMetadata+key, value))
+		}
+	}
+
+	usage, cost := usageDetails(body)
+	if len(usage) > 0 {
+		attributes = append(attributes, jsonAttribute(otlpAttrObservationUsage, usage))
+	}
+	if len(cost) > 0 {
+		attributes = append(attributes, jsonAttribute(otlpAttrObservationCost, cost))
+	}
+	return attributes
+}
+
+// usageDetails splits the usage and audio usage of a body into the token
+// counts and costs Langfuse expects as usage_details and cost_details
+func usageDetails(body map[string]interface{}) (usage, cost map[string]interface{}) {
+	usage = make(map[string]interface{})
+	cost = make(map[string]interface{})
+
+	fields := map[string]struct {
+		details map[string]interface{}
+		key     string
+	}{
+		"input":             {usage, "input"},
+		"output":            {usage, "output"},
+		"total":             {usage, "total"},
+		"inputCost":         {cost, "input"},
+		"outputCost":        {cost, "output"},
+		"totalCost":         {cost, "total"},
+		"inputAudioTokens":  {usage, "input_audio"},
+		"outputAudioTokens": {usage, "output_audio"},
+		"inputAudioCost":    {cost, "input_audio"},
+		"outputAudioCost":   {cost, "output_audio"},
+	}
+	for _, key := range []string{"usage", "audioUsage"} {
+		values, _ := body[key].(map[string]interface{})
+		for field, value := range values {
+			if target, ok := fields[field]; ok {
+				target.details[target.key] = value
+			}
+		}
+	}
+	return usage, cost
+}
+
+// traceAttributes maps a trace body to span attributes
+func traceAttributes(body map[string]interface{}) []otlpAttribute {
+	var attributes []otlpAttribute
+	for key, value := range body {
+		switch key {
+		case "id", "timestamp":
+			// Part of the span itself
+		case "name":
+			attributes = append(attributes, valueAttribute(otlpAttrTraceName, value))
+		case "input":
+			attributes = append(attributes, ioAttribute(otlpAttrTraceInput, value))
+		case "output":
+			attributes = append(attributes, ioAttribute(otlpAttrTraceOutput, value))
+		case "metadata":
+			attributes = append(attributes, metadataAttributes(otlpAttrTraceMetadata, value)...)
+		case "userId":
+			attributes = append(attributes, valueAttribute(otlpAttrUserID, value))
+		case "sessionId":
+			attributes = append(attributes, valueAttribute(otlpAttrSessionID, value))
+		case "version":
+			attributes = append(attributes, valueAttribute(otlpAttrVersion, value))
+		case "release":
+			attributes = append(attributes, valueAttribute(otlpAttrRelease, value))
+		case "environment":
+			attributes = append(attributes, valueAttribute(otlpAttrEnvironment, value))
+		case "tags":
+			attributes = append(attributes, valueAttribute(otlpAttrTraceTags, value))
+		case "public":
+			attributes = append(attributes, valueAttribute(otlpAttrTracePublic, value))
+		default:
+			attributes = append(attributes, valueAttribute(otlpAttrTraceMetadata+key, value))
+		}
+	}
+	return attributes
+}
+
+// metadataAttributes flattens metadata into one attribute per key
+func metadataAttributes(prefix string, metadata interface{}) []otlpAttribute {
+	values, ok := metadata.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	attributes := make([]otlpAttribute, 0, len(values))
+	for key, value := range values {
+		attributes = append(attributes, valueAttribute(prefix+key, value))
+	}
+	return attributes
+}
+
+// valueAttribute returns an attribute holding a string, integer, bool or list
+// of strings as such, and anything else JSON-encoded
+func valueAttribute(key string, value interface{}) otlpAttribute {
+	switch v := value.(type) {
+	case string:
+		return stringAttribute(key, v)
+	case bool:
+		return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &v}}
+	case float64:
+		if v == float64(int64(v)) {
+			i := strconv.FormatInt(int64(v), 10)
+			return otlpAttribute{Key: key, Value: otlpValue{IntValue: &i}}
+		}
+	case []interface{}:
+		values := make([]otlpValue, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return jsonAttribute(key, value)
+			}
+			values = append(values, otlpValue{StringValue: &s})
+		}
+		return otlpAttribute{Key: key, Value: otlpValue{ArrayValue: &otlpArray{Values: values}}}
+	}
+	return jsonAttribute(key, value)
+}
+
+// ioAttribute returns an input or output attribute: strings as they are,
+// anything else JSON-encoded
+func ioAttribute(key string, value interface{}) otlpAttribute {
+	if s, ok := value.(string); ok {
+		return stringAttribute(key, s)
+	}
+	return jsonAttribute(key, value)
+}
+
+// jsonAttribute returns an attribute holding the JSON encoding of value
+func jsonAttribute(key string, value interface{}) otlpAttribute {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return stringAttribute(key, fmt.Sprint(value))
+	}
+	return stringAttribute(key, string(data))
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// otlpTraceEvent rewrites the ID of a trace event to the OTLP ID its trace
+// is stored under
+func otlpTraceEvent(e Event) Event {
+	body := make(map[string]interface{}, len(e.Body))
+	for k, v := range e.Body {
+		body[k] = v
+	}
+	if traceID, ok := body["id"].(string); ok {
+		body["id"] = OTLPTraceID(traceID)
+	}
+	e.Body = body
+	return e
+}
+
+// otlpScoreEvent rewrites the trace and observation IDs of a score event to
+// the OTLP IDs its trace and observation are stored under
+func otlpScoreEvent(e Event) Event {
+	body := make(map[string]interface{}, len(e.Body))
+	for k, v := range e.Body {
+		body[k] = v
+	}
+	if traceID, ok := body["traceId"].(string); ok {
+		body["traceId"] = OTLPTraceID(traceID)
+	}
+	if observationID, ok := body["observationId"].(string); ok {
+		body["observationId"] = OTLPSpanID(observationID)
+	}
+	e.Body = body
+	return e
+}
+
+// normalizeBody converts an event body to its JSON representation, so values
+// such as *Usage become maps
+func normalizeBody(body map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// mergeBody merges a later event body into an earlier one; metadata is merged by key
+func mergeBody(into, body map[string]interface{}) {
+	for key, value := range body {
+		if key == "metadata" {
+			existing, ok1 := into[key].(map[string]interface{})
+			update, ok2 := value.(map[string]interface{})
+			if ok1 && ok2 {
+				for k, v := range update {
+					existing[k] = v
+				}
+				continue
+			}
+		}
+		into[key] = value
+	}
+}
+
+// bodyTime parses an RFC 3339 time field of a body, or returns fallback
+func bodyTime(body map[string]interface{}, key string, fallback time.Time) time.Time {
+	if s, ok := body[key].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t
+		}
+	}
+	return fallback
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// sendOTLP sends the trace and observation events of a batch as OTLP spans and
+// the rest to the ingestion API, and reports the result as one IngestionResponse
+func (c *clientCore) sendOTLP(ctx context.Context, events []Event) (*IngestionResponse, error) {
+	spans, sent, rest, unsendable := c.otlp.convert(events)
+
+	result := &IngestionResponse{}
+	if len(unsendable) > 0 {
+		c.config.logger().Warnf("cannot send %d updates of observations whose spans are unknown with TransportOTLP, e.g. because they were created by another client", len(unsendable))
+		for _, e := range unsendable {
+			result.Errors = append(result.Errors, ErrorResult{
+				ID:      e.ID,
+				Status:  http.StatusBadRequest,
+				Error:   "unknown span",
+				Message: "update of an observation whose span is unknown",
+			})
+		}
+	}
+	if len(rest) > 0 {
+		resp, err := c.sendIngestion(ctx, &IngestionRequest{Batch: rest})
+		if err != nil {
+			return nil, err
+		}
+		result.Successes = append(result.Successes, resp.Successes...)
+		result.Errors = append(result.Errors, resp.Errors...)
+	}
+	if len(spans) == 0 {
+		return result, nil
+	}
+
+	rejected, message, err := c.exportSpans(ctx, spans)
+	if err != nil {
+		return nil, err
+	}
+
+	// The endpoint only reports how many spans it rejected, not which
+	for i := 0; i < rejected; i++ {
+		result.Errors = append(result.Errors, ErrorResult{Status: http.StatusBadRequest, Error: "span rejected", Message: message})
+	}
+	for _, e := range sent {
+		result.Successes = append(result.Successes, SuccessResult{ID: e.ID, Status: http.StatusOK})
+	}
+	return result, nil
+}
+
+// exportSpans posts spans to the OTLP endpoint and returns how many it rejected
+func (c *clientCore) exportSpans(ctx context.Context, spans []otlpSpan) (int, string, error) {
+	if !c.config.Enabled {
+		return 0, "", nil
+	}
+
+	url := c.config.ingestionBaseURL() + "/api/public/otel/v1/traces"
+	serviceName := "langfuse-go"
+	req := otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: []otlpAttribute{stringAttribute("service.name", serviceName)}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: serviceName, Version: c.config.SDKVersion},
+				Spans: spans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", c.makeAuthHeader())
+	httpReq.Header.Set("X-Langfuse-Sdk-Name", "langfuse-go")
+	httpReq.Header.Set("X-Langfuse-Sdk-Version", c.config.SDKVersion)
+
+	c.config.logger().Debugf("Sending %d spans to %s", len(spans), url)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, "", NewNetworkError(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", NewNetworkError(err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, "", newResponseError(resp, respBody)
+	}
+
+	var exportResp otlpExportResponse
+	if len(respBody) > 0 && json.Unmarshal(respBody, &exportResp) == nil && exportResp.PartialSuccess != nil {
+		rejected, _ := exportResp.PartialSuccess.RejectedSpans.Int64()
+		if rejected > 0 {
+			c.config.logger().Debugf("OTLP endpoint rejected %d spans: %s", rejected, exportResp.PartialSuccess.ErrorMessage)
+		}
+		return int(rejected), exportResp.PartialSuccess.ErrorMessage, nil
+	}
+	return 0, "", nil
+}
@@ -0,0 +1,438 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// storedObservation and storedTrace are what Langfuse stores of an
+// observation and a trace, whichever transport they were sent with
+type storedObservation struct {
+	Type     string
+	Name     string
+	TraceID  string
+	ParentID string
+	Input    interface{}
+	Output   interface{}
+	Level    string
+	Model    string
+	Usage    map[string]interface{}
+	Metadata map[string]interface{}
+}
+
+type storedTrace struct {
+	Name      string
+	UserID    string
+	SessionID string
+	Tags      interface{}
+	Input     interface{}
+	Output    interface{}
+}
+
+type storedScore struct {
+	Name    string
+	TraceID string
+	Value   interface{}
+}
+
+// stored is the server-side representation of what a client sent
+type stored struct {
+	traces       map[string]*storedTrace
+	observations map[string]*storedObservation
+	scores       []storedScore
+}
+
+// otlpServer is a testServer that also accepts OTLP spans
+type otlpServer struct {
+	*testServer
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func newOTLPServer(t *testing.T) *otlpServer {
+	s := &otlpServer{testServer: newTestServer(t)}
+	s.handle("POST", "/api/public/otel/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		var req otlpExportRequest
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		for _, resourceSpans := range req.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				s.spans = append(s.spans, scopeSpans.Spans...)
+			}
+		}
+		s.mu.Unlock()
+		writeJSON(w, otlpExportResponse{})
+	})
+	return s
+}
+
+// stored returns the representation of the spans and events received, in order
+func (s *otlpServer) stored() stored {
+	st := stored{traces: make(map[string]*storedTrace), observations: make(map[string]*storedObservation)}
+	s.mu.Lock()
+	for _, span := range s.spans {
+		st.addSpan(span)
+	}
+	s.mu.Unlock()
+	for _, e := range s.Events() {
+		st.addEvent(e)
+	}
+	return st
+}
+
+func (st *stored) trace(id string) *storedTrace {
+	if st.traces[id] == nil {
+		st.traces[id] = &storedTrace{}
+	}
+	return st.traces[id]
+}
+
+func (st *stored) observation(id string) *storedObservation {
+	if st.observations[id] == nil {
+		st.observations[id] = &storedObservation{}
+	}
+	return st.observations[id]
+}
+
+// addEvent stores an ingestion event
+func (st *stored) addEvent(e Event) {
+	body, _ := normalizeBody(e.Body)
+	id, _ := body["id"].(string)
+	str := func(key string) string {
+		s, _ := body[key].(string)
+		return s
+	}
+
+	switch e.Type {
+	case EventTypeTraceCreate:
+		trace := st.trace(id)
+		for key, value := range body {
+			switch key {
+			case "name":
+				trace.Name = value.(string)
+			case "userId":
+				trace.UserID = value.(string)
+			case "sessionId":
+				trace.SessionID = value.(string)
+			case "tags":
+				trace.Tags = value
+			case "input":
+				trace.Input = value
+			case "output":
+				trace.Output = value
+			}
+		}
+	case EventTypeScoreCreate:
+		st.scores = append(st.scores, storedScore{Name: str("name"), TraceID: str("traceId"), Value: body["value"]})
+	default:
+		obs := st.observation(id)
+		if obsType, ok := otlpObservationTypes[e.Type]; ok {
+			obs.Type = obsType
+		}
+		for key, value := range body {
+			switch key {
+			case "name":
+				obs.Name = value.(string)
+			case "traceId":
+				obs.TraceID = value.(string)
+			case "parentObservationId":
+				obs.ParentID = value.(string)
+			case "input":
+				obs.Input = value
+			case "output":
+				obs.Output = value
+			case "level":
+				obs.Level = value.(string)
+			case "model":
+				obs.Model = value.(string)
+			case "usage":
+				obs.Usage = usageCounts(value.(map[string]interface{}))
+			case "metadata":
+				obs.Metadata = value.(map[string]interface{})
+			}
+		}
+	}
+}
+
+// addSpan stores an OTLP span
+func (st *stored) addSpan(span otlpSpan) {
+	obs := st.observation(span.SpanID)
+	obs.Name = span.Name
+	obs.TraceID = span.TraceID
+	obs.ParentID = span.ParentSpanID
+	trace := st.trace(span.TraceID)
+
+	for _, attr := range span.Attributes {
+		value := attributeValue(attr.Value)
+		str, _ := value.(string)
+		switch {
+		case attr.Key == otlpAttrObservationType:
+			obs.Type = str
+		case attr.Key == otlpAttrObservationInput:
+			obs.Input = decodeIO(str)
+		case attr.Key == otlpAttrObservationOutput:
+			obs.Output = decodeIO(str)
+		case attr.Key == otlpAttrObservationLevel:
+			obs.Level = str
+		case attr.Key == otlpAttrObservationModel:
+			obs.Model = str
+		case attr.Key == otlpAttrObservationUsage:
+			var usage map[string]interface{}
+			json.Unmarshal([]byte(str), &usage)
+			obs.Usage = usageCounts(usage)
+		case strings.HasPrefix(attr.Key, otlpAttrObservationMetadata):
+			if obs.Metadata == nil {
+				obs.Metadata = make(map[string]interface{})
+			}
+			obs.Metadata[strings.TrimPrefix(attr.Key, otlpAttrObservationMetadata)] = value
+		case attr.Key == otlpAttrTraceName:
+			trace.Name = str
+		case attr.Key == otlpAttrUserID:
+			trace.UserID = str
+		case attr.Key == otlpAttrSessionID:
+			trace.SessionID = str
+		case attr.Key == otlpAttrTraceTags:
+			trace.Tags = value
+		case attr.Key == otlpAttrTraceInput:
+			trace.Input = decodeIO(str)
+		case attr.Key == otlpAttrTraceOutput:
+			trace.Output = decodeIO(str)
+		}
+	}
+}
+
+// attributeValue returns the value of an attribute as it would be in JSON
+func attributeValue(v otlpValue) interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		var n float64
+		json.Unmarshal([]byte(*v.IntValue), &n)
+		return n
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i, item := range v.ArrayValue.Values {
+			values[i] = attributeValue(item)
+		}
+		return values
+	}
+	return nil
+}
+
+// decodeIO decodes an input or output attribute, which holds strings as they
+// are and anything else as JSON
+func decodeIO(s string) interface{} {
+	var value interface{}
+	if json.Unmarshal([]byte(s), &value) != nil {
+		return s
+	}
+	return value
+}
+
+// usageCounts keeps the token counts of a usage
+func usageCounts(usage map[string]interface{}) map[string]interface{} {
+	counts := make(map[string]interface{})
+	for _, key := range []string{"input", "output", "total"} {
+		if value, ok := usage[key]; ok {
+			counts[key] = value
+		}
+	}
+	return counts
+}
+
+// withOTLPIDs returns st with its IDs mapped to the OTLP IDs Langfuse stores
+// OTLP data under
+func (st stored) withOTLPIDs() stored {
+	mapped := stored{traces: make(map[string]*storedTrace), observations: make(map[string]*storedObservation)}
+	for id, trace := range st.traces {
+		mapped.traces[OTLPTraceID(id)] = trace
+	}
+	for id, obs := range st.observations {
+		o := *obs
+		o.TraceID = OTLPTraceID(o.TraceID)
+		if o.ParentID != "" {
+			o.ParentID = OTLPSpanID(o.ParentID)
+		}
+		mapped.observations[OTLPSpanID(id)] = &o
+	}
+	for _, score := range st.scores {
+		score.TraceID = OTLPTraceID(score.TraceID)
+		mapped.scores = append(mapped.scores, score)
+	}
+	return mapped
+}
+
+// sendReferenceTrace sends a trace with nested observations, a score, and
+// updates in a later batch than the observations they update
+func sendReferenceTrace(t *testing.T, client *Client) *Trace {
+	t.Helper()
+
+	trace, err := client.CreateTrace(TraceParams{
+		ID:        Ptr("4bf92f35-77b3-4da6-a3ce-929d0e0e4736"),
+		Name:      Ptr("reference"),
+		UserID:    Ptr("user-1"),
+		SessionID: Ptr("session-1"),
+		Tags:      []string{"a", "b"},
+		Input:     map[string]interface{}{"question": "weather?"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span, err := trace.StartSpan(NewSpan("retrieve",
+		WithID("span-1"),
+		WithInput(map[string]interface{}{"city": "Paris"}),
+		WithMetadata(map[string]interface{}{"index": "cities"}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := trace.StartGeneration(NewGeneration("answer",
+		WithID("generation-1"),
+		WithParent(span.ObservationID()),
+		WithModel("gpt-4o"),
+		WithInput("What is the weather in Paris?"),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trace.CreateEvent(NewEvent("checkpoint", WithID("event-1"), WithLevel(LevelWarning))); err != nil {
+		t.Fatal(err)
+	}
+	if err := span.End(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateScore(ScoreParams{ID: Ptr("score-1"), TraceID: Ptr(trace.ID()), Name: "quality", Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	// Updates of a trace and an observation sent in an earlier batch
+	if err := gen.EndWithParams(NewGeneration("",
+		WithOutput("Sunny"),
+		WithLevel(LevelError),
+		WithUsage(Usage{Input: Ptr(10), Output: Ptr(5), Total: Ptr(15)}),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+	if err := trace.Update(TraceParams{Output: map[string]interface{}{"answer": "Sunny"}}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+	return trace
+}
+
+func TestOTLPTransportStoresTheSameAsIngestion(t *testing.T) {
+	ingestionServer := newOTLPServer(t)
+	sendReferenceTrace(t, newTestClient(t, ingestionServer.testServer))
+	want := ingestionServer.stored().withOTLPIDs()
+
+	otlpServer := newOTLPServer(t)
+	sendReferenceTrace(t, newTestClient(t, otlpServer.testServer, func(c *Config) {
+		c.Transport = TransportOTLP
+	}))
+	got := otlpServer.stored()
+
+	if len(want.observations) != 3 {
+		t.Fatalf("ingestion stored %d observations, want 3", len(want.observations))
+	}
+	if !reflect.DeepEqual(got.traces, want.traces) {
+		t.Errorf("OTLP stored traces:\n%s\nwant:\n%s", dump(got.traces), dump(want.traces))
+	}
+	if !reflect.DeepEqual(got.observations, want.observations) {
+		t.Errorf("OTLP stored observations:\n%s\nwant:\n%s", dump(got.observations), dump(want.observations))
+	}
+	if !reflect.DeepEqual(got.scores, want.scores) {
+		t.Errorf("OTLP stored scores %v, want %v", got.scores, want.scores)
+	}
+}
+
+func dump(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data)
+}
+
+func TestOTLPTransportMapsIDsOfFetchesAndURLs(t *testing.T) {
+	server := newOTLPServer(t)
+	client := newTestClient(t, server.testServer, func(c *Config) {
+		c.Transport = TransportOTLP
+	})
+	trace := sendReferenceTrace(t, client)
+	span, err := trace.StartSpan(NewSpan("late"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.handle("GET", "/api/public/traces/"+OTLPTraceID(trace.ID()), func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, TraceWithFullDetails{})
+	})
+	server.handle("GET", "/api/public/observations/"+OTLPSpanID(span.ObservationID()), func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, ObservationDetails{})
+	})
+	if _, err := client.GetTrace(context.Background(), GetTraceParams{TraceID: trace.ID()}); err != nil {
+		t.Errorf("GetTrace(trace.ID()): %v", err)
+	}
+	if _, err := client.GetObservation(context.Background(), span.ObservationID()); err != nil {
+		t.Errorf("GetObservation(span.ObservationID()): %v", err)
+	}
+	// IDs returned by the API are already OTLP IDs
+	if _, err := client.GetObservation(context.Background(), OTLPSpanID(span.ObservationID())); err != nil {
+		t.Errorf("GetObservation(OTLP ID): %v", err)
+	}
+	if url := trace.URL(); !strings.HasSuffix(url, "/trace/"+OTLPTraceID(trace.ID())) {
+		t.Errorf("trace.URL() = %q, want a link to the OTLP trace ID", url)
+	}
+}
+
+func TestOTLPTransportSendsTraceWithoutObservationsWithoutSpan(t *testing.T) {
+	server := newOTLPServer(t)
+	client := newTestClient(t, server.testServer, func(c *Config) {
+		c.Transport = TransportOTLP
+	})
+	trace, err := client.CreateTrace(TraceParams{Name: Ptr("lonely")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	st := server.stored()
+	if len(st.observations) != 0 {
+		t.Errorf("stored observations %s, want none", dump(st.observations))
+	}
+	if got := st.traces[OTLPTraceID(trace.ID())]; got == nil || got.Name != "lonely" {
+		t.Errorf("stored traces %s, want the trace under its OTLP ID", dump(st.traces))
+	}
+}
+
+func TestOTLPTransportRejectsUpdatesOfUnknownSpans(t *testing.T) {
+	server := newOTLPServer(t)
+	client := newTestClient(t, server.testServer, func(c *Config) {
+		c.Transport = TransportOTLP
+		c.MetricsEnabled = true
+	})
+	if err := client.UpdateSpan("started-by-another-process", NewSpan("late")); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	if st := server.stored(); len(st.observations) != 0 {
+		t.Errorf("stored observations %s, want none", dump(st.observations))
+	}
+	if failed := client.GetMetrics().EventsFailed; failed != 1 {
+		t.Errorf("EventsFailed = %d, want 1", failed)
+	}
+}
@@ -0,0 +1,229 @@
+package langfuse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxOverflowBytes is the default MaxOverflowBytes
+const DefaultMaxOverflowBytes = 64 << 20
+
+// overflowQueueSuffix is appended to Config.PersistentQueuePath to name the
+// file of Config.OverflowToDisk
+const overflowQueueSuffix = ".overflow"
+
+// maxOverflowBytes returns MaxOverflowBytes, or DefaultMaxOverflowBytes if it is not set
+func (c *Config) maxOverflowBytes() int64 {
+	if c.MaxOverflowBytes > 0 {
+		return c.MaxOverflowBytes
+	}
+	return DefaultMaxOverflowBytes
+}
+
+// overflowQueue holds the events that did not fit in a full queue
+// (Config.OverflowToDisk) in a file of JSON lines, in the persistentQueue
+// format, until the batcher has room for them again. Like the persistent
+// queue, the file outlives the client, so spilled events that were not
+// replayed are picked up by the next client using the same path.
+//
+// Events are appended at the end and read from offset on, so replaying does
+// not rewrite the file. The file is compacted once the events already read
+// make up half of it, and emptied once all are read. Events read since the
+// last compaction are replayed again by a client started after a crash,
+// which is safe because the ingestion API deduplicates events by ID.
+type overflowQueue struct {
+	file     *persistentQueue
+	count    int   // events after offset
+	bytes    int64 // size of the file
+	offset   int64 // start of the events not yet taken
+	maxBytes int64
+}
+
+// openOverflowQueue opens or creates the overflow file at path
+func openOverflowQueue(fs queueFS, path string, maxBytes int64, logger Logger) (*overflowQueue, error) {
+	file, events, err := openPersistentQueue(fs, path, logger)
+	if err != nil {
+		return nil, err
+	}
+	q := &overflowQueue{file: file, count: len(events), maxBytes: maxBytes}
+	if err := q.stat(); err != nil {
+		file.close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// stat reads the size of the file after it was rewritten
+func (q *overflowQueue) stat() error {
+	info, err := q.file.fs.Stat(q.file.path)
+	if err != nil {
+		return err
+	}
+	q.bytes = info.Size()
+	return nil
+}
+
+// spill appends an event to the file, unless it would grow past maxBytes or
+// cannot be written, and reports whether it did
+func (q *overflowQueue) spill(e Event) bool {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return false
+	}
+	size := int64(len(data) + 1)
+	if q.bytes+size > q.maxBytes {
+		return false
+	}
+	if err := q.file.appendLine(data); err != nil {
+		return false
+	}
+	q.count++
+	q.bytes += size
+	return true
+}
+
+// take removes up to n of the oldest events from the file and returns them
+func (q *overflowQueue) take(n int) ([]Event, error) {
+	if q.count == 0 || n <= 0 {
+		return nil, nil
+	}
+	events, read, err := q.read(n)
+	if err != nil {
+		return nil, err
+	}
+	q.offset += read
+	q.count -= len(events)
+	if q.offset >= q.bytes {
+		q.count = 0 // the rest were malformed
+	}
+
+	switch {
+	case q.count == 0:
+		_, err = q.clear()
+	case q.offset >= q.bytes/2:
+		err = q.compact()
+	}
+	return events, err
+}
+
+// read reads up to n events after offset, or all of them if n is negative,
+// and returns them with the number of bytes read. Malformed records are
+// skipped, and an incomplete last line is left unread.
+func (q *overflowQueue) read(n int) ([]Event, int64, error) {
+	f, err := q.file.fs.Open(q.file.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open overflow file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(q.offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to read overflow file: %w", err)
+	}
+
+	var events []Event
+	var read int64
+	r := bufio.NewReader(f)
+	for n < 0 || len(events) < n {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read overflow file: %w", err)
+		}
+		read += int64(len(line))
+		var e Event
+		if json.Unmarshal(line, &e) == nil && e.ID != "" && e.Type != "" {
+			events = append(events, e)
+		}
+	}
+	return events, read, nil
+}
+
+// compact rewrites the file without the events already taken
+func (q *overflowQueue) compact() error {
+	events, _, err := q.read(-1)
+	if err != nil {
+		return err
+	}
+	if err := q.file.rewrite(events); err != nil {
+		return err
+	}
+	q.count = len(events)
+	q.offset = 0
+	return q.stat()
+}
+
+// clear empties the file and returns how many events it held
+func (q *overflowQueue) clear() (int, error) {
+	cleared := q.count
+	if err := q.file.rewrite(nil); err != nil {
+		return 0, err
+	}
+	q.count = 0
+	q.bytes = 0
+	q.offset = 0
+	return cleared, nil
+}
+
+// spillLocked writes an event that does not fit in the full queue to the
+// overflow file, and reports whether it did. The caller must hold b.mu.
+func (b *Batcher) spillLocked(event Event) bool {
+	// The overflow file holds encoded events, so lazy values are resolved now
+	event = resolveLazyMetadata(event, b.config.logger())
+	if !b.overflow.spill(event) {
+		return false
+	}
+
+	b.config.logger().Debugf("Queue is full (%d events), spilled event to disk", len(b.queue))
+	if b.config.MetricsEnabled {
+		b.client.metrics.RecordSpilled(1)
+	}
+	return true
+}
+
+// replayOverflow runs in the background while there is an overflow file: it
+// moves spilled events back into the queue whenever a flush makes room
+func (b *Batcher) replayOverflow() {
+	defer b.wg.Done()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		b.refillLocked()
+		if err := b.waitForSpaceLocked(context.Background()); err != nil {
+			return
+		}
+	}
+}
+
+// refillLocked moves as many spilled events into the queue as fit. The
+// caller must hold b.mu.
+func (b *Batcher) refillLocked() {
+	events, err := b.overflow.take(b.config.MaxQueueSize - len(b.queue))
+	if err != nil {
+		b.config.logger().Warnf("failed to replay overflow file: %v", err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	b.queue = append(b.queue, events...)
+	if b.persist != nil {
+		for _, e := range events {
+			if err := b.persist.append(e); err != nil {
+				b.config.logger().Warnf("failed to persist event: %v", err)
+			}
+		}
+	}
+	b.config.logger().Debugf("Replayed %d spilled events, %d still on disk", len(events), b.overflow.count)
+	if b.config.MetricsEnabled {
+		b.client.metrics.RecordReplayed(len(events))
+	}
+
+	b.autoFlushLocked()
+}
@@ -0,0 +1,161 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// overflowConfig sets up OverflowToDisk with a queue of size events
+func overflowConfig(t *testing.T, size int) func(*Config) {
+	path := filepath.Join(t.TempDir(), "queue")
+	return func(c *Config) {
+		c.MaxQueueSize = size
+		c.PersistentQueuePath = path
+		c.OverflowToDisk = true
+		c.MetricsEnabled = true
+	}
+}
+
+// drain flushes until the server received want trace creates
+func drain(t *testing.T, client *Client, server *testServer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.eventsOfType(EventTypeTraceCreate)) < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d traces, want %d", len(server.eventsOfType(EventTypeTraceCreate)), want)
+		}
+		flush(t, client)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOverflowSpillsUnderPressureAndReplays(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, overflowConfig(t, 5))
+
+	ids := createTraces(t, client, 50)
+	if got := client.batcher.Len(); got > 5 {
+		t.Errorf("queue holds %d events, more than MaxQueueSize", got)
+	}
+	if got := client.GetMetrics().EventsSpilled; got != 45 {
+		t.Errorf("EventsSpilled = %d, want 45", got)
+	}
+
+	drain(t, client, server, len(ids))
+	counts := traceCreateCounts(server)
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times, want 1", id, counts[id])
+		}
+	}
+	snapshot := client.GetMetrics()
+	if snapshot.EventsReplayed != 45 || snapshot.EventsDropped != 0 {
+		t.Errorf("EventsReplayed = %d, EventsDropped = %d, want 45 and 0", snapshot.EventsReplayed, snapshot.EventsDropped)
+	}
+}
+
+func TestOverflowSpillsInsteadOfBlocking(t *testing.T) {
+	server := newTestServer(t)
+	spill := overflowConfig(t, 2)
+	client := newTestClient(t, server, spill, func(c *Config) {
+		c.QueueFullBehavior = QueueFullBlock
+		c.QueueFullTimeout = time.Hour
+	})
+
+	start := time.Now()
+	ids := createTraces(t, client, 10)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("creating traces took %v, want them spilled without waiting", elapsed)
+	}
+	if got := client.GetMetrics().EventsSpilled; got != 8 {
+		t.Errorf("EventsSpilled = %d, want 8", got)
+	}
+	drain(t, client, server, len(ids))
+}
+
+func TestOverflowIsBoundedByMaxOverflowBytes(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, overflowConfig(t, 1), func(c *Config) {
+		c.MaxOverflowBytes = 1 // no event fits
+	})
+
+	createTraces(t, client, 1)
+	_, err := client.CreateTrace(TraceParams{})
+	var queueFull *QueueFullError
+	if !errors.As(err, &queueFull) {
+		t.Errorf("CreateTrace = %v, want a *QueueFullError", err)
+	}
+}
+
+func TestOverflowIsReplayedAfterRestart(t *testing.T) {
+	spill := overflowConfig(t, 3)
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = "http://127.0.0.1:1" // unreachable
+	config.FlushAt = 1000
+	config.MaxRetryAttempts = 1000
+	spill(config)
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := createTraces(t, client, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	report, _ := client.CloseWithContext(ctx)
+	if report.Spooled != len(ids) {
+		t.Fatalf("Spooled = %d, want %d", report.Spooled, len(ids))
+	}
+
+	server := newTestServer(t)
+	restarted := newTestClient(t, server, spill)
+	drain(t, restarted, server, len(ids))
+	counts := traceCreateCounts(server)
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times after restart, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestOverflowQueueCompactsAfterHalfIsTaken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overflow")
+	q, err := openOverflowQueue(osFS{}, path, DefaultMaxOverflowBytes, stdLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.file.close()
+
+	for i := 0; i < 10; i++ {
+		if !q.spill(Event{ID: string(rune('a' + i)), Type: EventTypeTraceCreate}) {
+			t.Fatal("spill failed")
+		}
+	}
+	size := q.bytes
+
+	taken, err := q.take(4)
+	if err != nil || len(taken) != 4 || taken[0].ID != "a" {
+		t.Fatalf("take(4) = %v, %v", taken, err)
+	}
+	if q.bytes != size || q.offset == 0 {
+		t.Errorf("taking less than half rewrote the file")
+	}
+
+	taken, err = q.take(2)
+	if err != nil || len(taken) != 2 || taken[0].ID != "e" {
+		t.Fatalf("take(2) = %v, %v", taken, err)
+	}
+	if q.offset != 0 || q.count != 4 || q.bytes >= size {
+		t.Errorf("after taking half: offset %d, count %d, %d of %d bytes; want the file compacted", q.offset, q.count, q.bytes, size)
+	}
+
+	taken, err = q.take(10)
+	if err != nil || len(taken) != 4 || taken[0].ID != "g" || q.count != 0 || q.bytes != 0 {
+		t.Fatalf("take(10) = %v, %v; %d events and %d bytes left", taken, err, q.count, q.bytes)
+	}
+}
@@ -0,0 +1,95 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Metadata keys set on observations and traces that were ended by a panic
+const (
+	// MetadataKeyPanic holds the panic value
+	MetadataKeyPanic = "panic"
+
+	// MetadataKeyPanicStack holds the stack trace of the panicking goroutine
+	MetadataKeyPanicStack = "panicStack"
+)
+
+// RecoverAndEnd records a panic on the trace and then panics again with the
+// same value. Defer it right after creating the trace:
+//
+//	trace, _ := client.CreateTrace(params)
+//	defer trace.RecoverAndEnd()
+//
+// On a panic, every observation started with a Start method and not yet ended
+// gets its EndTime, Level ERROR and the panic value as StatusMessage, the trace
+// is ended with the panic value and stack trace in its metadata, and the client
+// is flushed, so nothing is left looking like it is still running. Without a
+// panic RecoverAndEnd does nothing.
+func (t *Trace) RecoverAndEnd() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	t.recordPanic(r, debug.Stack())
+	panic(r)
+}
+
+// recordPanic ends the trace's open observations and the trace itself with the
+// panic and flushes the client
+func (t *Trace) recordPanic(value interface{}, stack []byte) {
+	t.mu.Lock()
+	open := t.open
+	t.open = nil
+	alreadyEnded := t.ended
+	t.ended = true
+	t.mu.Unlock()
+
+	for id, obsType := range open {
+		if err := t.client.UpdateObservation(id, obsType, panicParams(value, stack)); err != nil {
+			t.client.config.logger().Debugf("Failed to end observation %s after panic: %v", id, err)
+		}
+	}
+
+	if !alreadyEnded {
+		err := t.Update(TraceParams{Metadata: panicMetadata(value, stack)})
+		if err != nil {
+			t.client.config.logger().Debugf("Failed to end trace %s after panic: %v", t.id, err)
+		}
+	}
+
+	flushAfterPanic(t.client)
+}
+
+// panicParams returns the params that end an observation interrupted by a panic
+func panicParams(value interface{}, stack []byte) SpanParams {
+	now := time.Now()
+	return SpanParams{
+		ObservationParams: ObservationParams{
+			Level:         ptr(LevelError),
+			StatusMessage: ptr(fmt.Sprintf("panic: %v", value)),
+			Metadata:      panicMetadata(value, stack),
+		},
+		EndTime: &now,
+	}
+}
+
+// panicMetadata returns the metadata describing a panic
+func panicMetadata(value interface{}, stack []byte) map[string]interface{} {
+	return map[string]interface{}{
+		MetadataKeyPanic:      fmt.Sprint(value),
+		MetadataKeyPanicStack: string(stack),
+	}
+}
+
+// flushAfterPanic sends the queued events before a panic continues, since the
+// process may be about to exit. It waits at most Config.Timeout.
+func flushAfterPanic(c *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+
+	if err := c.Flush(ctx); err != nil {
+		c.config.logger().Debugf("Failed to flush after panic: %v", err)
+	}
+}
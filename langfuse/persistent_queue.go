@@ -0,0 +1,221 @@
+package langfuse
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// persistentQueue mirrors the batcher's queue in an append-only file of JSON
+// lines (Config.PersistentQueuePath), so events that were not delivered when
+// the process exited are sent by the next client using the same file.
+//
+// Enqueued events are appended. After a flush or drop changed the queue, the
+// file is rewritten with what is still queued, by writing and syncing a
+// temporary file and renaming it over the old one, so a crash leaves either
+// the old or the new file. Appends are not synced, so an operating system
+// crash may lose the events queued since the last rewrite. Events whose
+// delivery was not yet recorded are sent again, which is safe because the
+// ingestion API deduplicates events by ID.
+type persistentQueue struct {
+	fs   queueFS
+	path string
+	file queueFile
+}
+
+// queueFS is the file system holding the queue files. The client uses osFS;
+// tests substitute one that fails mid-write to check crash consistency.
+type queueFS interface {
+	Open(name string) (queueFile, error)
+	OpenFile(name string, flag int, perm os.FileMode) (queueFile, error)
+	CreateTemp(dir, pattern string) (queueFile, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+}
+
+// queueFile is an open file of a queueFS
+type queueFile interface {
+	io.ReadWriteCloser
+	io.Seeker
+	Name() string
+	Sync() error
+}
+
+// osFS is the queueFS of the operating system
+type osFS struct{}
+
+func (osFS) Open(name string) (queueFile, error) {
+	return os.Open(name)
+}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (queueFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFS) CreateTemp(dir, pattern string) (queueFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// openPersistentQueue opens or creates the queue file at path and returns the
+// events it holds. Malformed records, such as a line cut short by a crash,
+// are skipped.
+func openPersistentQueue(fs queueFS, path string, logger Logger) (*persistentQueue, []Event, error) {
+	events, skipped, err := readQueueFile(fs, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if skipped > 0 {
+		logger.Warnf("skipped %d malformed records in persistent queue %s", skipped, path)
+	}
+	if len(events) > 0 {
+		logger.Debugf("Restored %d events from persistent queue %s", len(events), path)
+	}
+
+	q := &persistentQueue{fs: fs, path: path}
+	if err := q.rewrite(events); err != nil {
+		return nil, nil, err
+	}
+	return q, events, nil
+}
+
+// readQueueFile reads the events of a queue file and counts the malformed records.
+// A missing file holds no events.
+func readQueueFile(fs queueFS, path string) (events []Event, skipped int, err error) {
+	f, err := fs.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open persistent queue: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Event
+			if json.Unmarshal(line, &e) != nil || e.ID == "" || e.Type == "" {
+				skipped++
+			} else {
+				events = append(events, e)
+			}
+		}
+		if readErr == io.EOF {
+			return events, skipped, nil
+		}
+		if readErr != nil {
+			return nil, 0, fmt.Errorf("failed to read persistent queue: %w", readErr)
+		}
+	}
+}
+
+// append adds an event to the end of the file
+func (q *persistentQueue) append(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return q.appendLine(data)
+}
+
+// appendLine adds an encoded event to the end of the file
+func (q *persistentQueue) appendLine(data []byte) error {
+	if q.file == nil {
+		return errors.New("persistent queue is closed")
+	}
+	_, err := q.file.Write(append(data, '\n'))
+	return err
+}
+
+// rewrite replaces the file's contents with events
+func (q *persistentQueue) rewrite(events []Event) error {
+	tmp, err := q.fs.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+	defer q.fs.Remove(tmp.Name()) // no-op after a successful rename
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue // cannot be sent either
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+	if err := q.fs.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+	if err := syncDir(q.fs, filepath.Dir(q.path)); err != nil {
+		return fmt.Errorf("failed to write persistent queue: %w", err)
+	}
+
+	q.file, err = q.fs.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open persistent queue: %w", err)
+	}
+	return nil
+}
+
+// sync commits the appended events to stable storage
+func (q *persistentQueue) sync() error {
+	if q.file == nil {
+		return nil
+	}
+	return q.file.Sync()
+}
+
+// close closes the file, leaving its events for the next client
+func (q *persistentQueue) close() error {
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
+
+// syncDir syncs a directory, making a rename in it durable
+func syncDir(fs queueFS, dir string) error {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
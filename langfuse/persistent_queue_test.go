@@ -0,0 +1,445 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestPersistentQueueIsOnlyRewrittenWhenChanged(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "queue")
+	client := newTestClient(t, server, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("flushing an empty queue rewrote the queue file")
+	}
+
+	if _, err := client.CreateTrace(TraceParams{}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+	after, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(before, after) {
+		t.Error("flushing queued events did not rewrite the queue file")
+	}
+	if after.Size() != 0 {
+		t.Errorf("queue file holds %d bytes after all events were delivered", after.Size())
+	}
+}
+
+func TestPersistentQueueSurvivesRestart(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "queue")
+
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = "http://127.0.0.1:1" // unreachable
+	config.FlushAt = 1000
+	config.PersistentQueuePath = path
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := createTraces(t, client, 5)
+	report, _ := client.CloseWithContext(context.Background())
+	if report.Spooled != len(ids) || report.Abandoned != 0 {
+		t.Fatalf("report = %+v, want %d spooled and none abandoned", report, len(ids))
+	}
+
+	restarted := newTestClient(t, server, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+	flush(t, restarted)
+
+	counts := traceCreateCounts(server)
+	if len(counts) != len(ids) {
+		t.Fatalf("restarted client sent %d traces, want %d", len(counts), len(ids))
+	}
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times, want 1", id, counts[id])
+		}
+	}
+}
+
+// errCrashed is returned by every operation of a faultFS after its crash
+var errCrashed = errors.New("crashed")
+
+// faultFS is the file system of the OS until it crashes: the operation
+// chosen with crashOn fails, a write writing only half its bytes, and so do
+// all operations after it, as if the process had died.
+type faultFS struct {
+	osFS
+
+	mu      sync.Mutex
+	crashOn string // "write" or "rename", or empty to not crash
+	crashed bool
+}
+
+// crashAt makes the next op ("write" or "rename") crash the file system
+func (fs *faultFS) crashAt(op string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.crashOn = op
+}
+
+// fail reports whether op fails, crashing the file system if it is the chosen one
+func (fs *faultFS) fail(op string) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if !fs.crashed && fs.crashOn == op {
+		fs.crashed = true
+		return true
+	}
+	return fs.crashed
+}
+
+func (fs *faultFS) Open(name string) (queueFile, error) {
+	if fs.fail("open") {
+		return nil, errCrashed
+	}
+	f, err := fs.osFS.Open(name)
+	return fs.wrap(f, err)
+}
+
+func (fs *faultFS) OpenFile(name string, flag int, perm os.FileMode) (queueFile, error) {
+	if fs.fail("open") {
+		return nil, errCrashed
+	}
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	return fs.wrap(f, err)
+}
+
+func (fs *faultFS) CreateTemp(dir, pattern string) (queueFile, error) {
+	if fs.fail("create") {
+		return nil, errCrashed
+	}
+	f, err := fs.osFS.CreateTemp(dir, pattern)
+	return fs.wrap(f, err)
+}
+
+func (fs *faultFS) Rename(oldpath, newpath string) error {
+	if fs.fail("rename") {
+		return errCrashed
+	}
+	return fs.osFS.Rename(oldpath, newpath)
+}
+
+func (fs *faultFS) Remove(name string) error {
+	if fs.fail("remove") {
+		return errCrashed
+	}
+	return fs.osFS.Remove(name)
+}
+
+func (fs *faultFS) wrap(f queueFile, err error) (queueFile, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{queueFile: f, fs: fs}, nil
+}
+
+// faultFile is a file of a faultFS
+type faultFile struct {
+	queueFile
+	fs *faultFS
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	crashed, crashing := f.fs.crashed, !f.fs.crashed && f.fs.crashOn == "write"
+	if crashing {
+		f.fs.crashed = true
+	}
+	f.fs.mu.Unlock()
+
+	switch {
+	case crashing:
+		n, _ := f.queueFile.Write(p[:len(p)/2])
+		return n, errCrashed
+	case crashed:
+		return 0, errCrashed
+	}
+	return f.queueFile.Write(p)
+}
+
+func (f *faultFile) Sync() error {
+	if f.fs.fail("sync") {
+		return errCrashed
+	}
+	return f.queueFile.Sync()
+}
+
+// kill stops a client whose file system crashed without delivering anything more
+func kill(client *Client) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.CloseWithContext(ctx)
+}
+
+// createTraces creates n traces and returns their IDs
+func createTraces(t *testing.T, client *Client, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := 0; i < n; i++ {
+		trace, err := client.CreateTrace(TraceParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, trace.ID())
+	}
+	return ids
+}
+
+// traceCreateCounts counts the trace-create events the server received per trace ID
+func traceCreateCounts(server *testServer) map[string]int {
+	counts := make(map[string]int)
+	for _, e := range server.eventsOfType(EventTypeTraceCreate) {
+		id, _ := e.Body["id"].(string)
+		counts[id]++
+	}
+	return counts
+}
+
+// recoverQueue starts a client on the queue file at path and flushes what it restored
+func recoverQueue(t *testing.T, path string) *testServer {
+	t.Helper()
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+	flush(t, client)
+	return server
+}
+
+func TestPersistentQueueRecoversFromCrashDuringRewrite(t *testing.T) {
+	server := newTestServer(t)
+	failed := false
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		if !failed {
+			failed = true
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ingest(w, r)
+	})
+	fs := &faultFS{}
+	path := filepath.Join(t.TempDir(), "queue")
+	client := newTestClientFS(t, server, fs, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+
+	ids := createTraces(t, client, 3)
+	// The failed flush requeues the events and rewrites the file with them
+	fs.crashAt("write")
+	client.Flush(context.Background())
+	kill(client)
+
+	recovered := recoverQueue(t, path)
+	counts := traceCreateCounts(recovered)
+	if len(counts) != len(ids) {
+		t.Fatalf("recovered %d traces, want %d", len(counts), len(ids))
+	}
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times after recovery, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestPersistentQueueRecoversFromCrashBeforeRename(t *testing.T) {
+	server := newTestServer(t)
+	fs := &faultFS{}
+	path := filepath.Join(t.TempDir(), "queue")
+	client := newTestClientFS(t, server, fs, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+
+	ids := createTraces(t, client, 3)
+	// The events are delivered, but the crash keeps the file from recording it
+	fs.crashAt("rename")
+	flush(t, client)
+	kill(client)
+
+	if got := len(server.eventsOfType(EventTypeTraceCreate)); got != len(ids) {
+		t.Fatalf("delivered %d traces before the crash, want %d", got, len(ids))
+	}
+	// They are sent again with the same IDs, for the API to deduplicate
+	counts := traceCreateCounts(recoverQueue(t, path))
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times after recovery, want 1", id, counts[id])
+		}
+	}
+	if len(counts) != len(ids) {
+		t.Errorf("recovered %d traces, want %d", len(counts), len(ids))
+	}
+}
+
+func TestPersistentQueueRecoversFromCrashDuringAppend(t *testing.T) {
+	server := newTestServer(t)
+	fs := &faultFS{}
+	path := filepath.Join(t.TempDir(), "queue")
+	client := newTestClientFS(t, server, fs, func(c *Config) {
+		c.PersistentQueuePath = path
+	})
+
+	ids := createTraces(t, client, 2)
+	fs.crashAt("write")
+	createTraces(t, client, 1) // torn line
+	kill(client)
+
+	counts := traceCreateCounts(recoverQueue(t, path))
+	if len(counts) != len(ids) {
+		t.Fatalf("recovered %d traces, want the %d written before the crash", len(counts), len(ids))
+	}
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times after recovery, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestPersistentQueueRecoversFromCrashDuringSpool(t *testing.T) {
+	fs := &faultFS{}
+	path := filepath.Join(t.TempDir(), "queue")
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = "http://127.0.0.1:1" // unreachable
+	config.FlushAt = 1000
+	config.PersistentQueuePath = path
+	client, err := newClient(config, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := createTraces(t, client, 4)
+	fs.crashAt("write")
+	client.CloseWithContext(context.Background())
+
+	counts := traceCreateCounts(recoverQueue(t, path))
+	if len(counts) != len(ids) {
+		t.Fatalf("recovered %d traces, want %d", len(counts), len(ids))
+	}
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("trace %s was sent %d times after recovery, want 1", id, counts[id])
+		}
+	}
+}
+
+// trackingFS is an osFS that keeps count of the files it has open
+type trackingFS struct {
+	osFS
+
+	mu   sync.Mutex
+	open map[string]int
+}
+
+func (fs *trackingFS) OpenFile(name string, flag int, perm os.FileMode) (queueFile, error) {
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	return fs.track(f, err)
+}
+
+func (fs *trackingFS) CreateTemp(dir, pattern string) (queueFile, error) {
+	f, err := fs.osFS.CreateTemp(dir, pattern)
+	return fs.track(f, err)
+}
+
+func (fs *trackingFS) track(f queueFile, err error) (queueFile, error) {
+	if err != nil {
+		return nil, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.open == nil {
+		fs.open = make(map[string]int)
+	}
+	fs.open[f.Name()]++
+	return &trackedFile{queueFile: f, fs: fs}, nil
+}
+
+// openFiles returns the names of the files still open
+func (fs *trackingFS) openFiles() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var names []string
+	for name, n := range fs.open {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// trackedFile is a file of a trackingFS
+type trackedFile struct {
+	queueFile
+	fs *trackingFS
+}
+
+func (f *trackedFile) Close() error {
+	f.fs.mu.Lock()
+	f.fs.open[f.Name()]--
+	f.fs.mu.Unlock()
+	return f.queueFile.Close()
+}
+
+func TestFailedClientClosesTheFilesItOpened(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(config *Config, dir string)
+	}{
+		{"overflow file fails", func(config *Config, dir string) {
+			config.OverflowToDisk = true
+			// A directory where the overflow file belongs can't be opened as one
+			if err := os.Mkdir(config.PersistentQueuePath+overflowQueueSuffix, 0o700); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"dead-letter file fails", func(config *Config, dir string) {
+			config.OverflowToDisk = true
+			config.DeadLetterPath = filepath.Join(dir, "missing", "dead.jsonl")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := DefaultConfig()
+			config.PublicKey = "pk-test"
+			config.SecretKey = "sk-test"
+			config.PersistentQueuePath = filepath.Join(dir, "queue.jsonl")
+			tt.configure(config, dir)
+
+			fs := &trackingFS{}
+			if client, err := newClient(config, fs); err == nil {
+				client.Close()
+				t.Fatal("newClient succeeded")
+			}
+			if open := fs.openFiles(); len(open) > 0 {
+				t.Errorf("files left open after newClient failed: %v", open)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Prompt types
+const (
+	PromptTypeText = "text"
+	PromptTypeChat = "chat"
+)
+
+// Prompt represents a versioned prompt template retrieved from the API
+type Prompt struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Type    string `json:"type"` // text or chat
+
+	// Prompt is a string for text prompts and []ChatMessage for chat prompts
+	Prompt interface{} `json:"prompt"`
+
+	Config map[string]interface{} `json:"config,omitempty"`
+	Labels []string               `json:"labels,omitempty"`
+	Tags   []string               `json:"tags,omitempty"`
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for Prompt
+// to decode the prompt field as a string or a list of chat messages
+func (p *Prompt) UnmarshalJSON(data []byte) error {
+	type Alias Prompt
+	aux := &struct {
+		Prompt json.RawMessage `json:"prompt"`
+		*Alias
+	}{
+		Alias: (*Alias)(p),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	p.Prompt = nil
+	if len(aux.Prompt) == 0 || string(aux.Prompt) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(aux.Prompt, &text); err == nil {
+		p.Prompt = text
+		return nil
+	}
+
+	var messages []ChatMessage
+	if err := json.Unmarshal(aux.Prompt, &messages); err != nil {
+		return fmt.Errorf("prompt must be a string or a list of chat messages: %w", err)
+	}
+	p.Prompt = messages
+	return nil
+}
+
+// GetPromptParams represents parameters for fetching a prompt
+type GetPromptParams struct {
+	// Name is the prompt name (required)
+	Name string
+
+	// Version fetches a specific version
+	Version *int
+
+	// Label fetches the version with this label (the server defaults to "production")
+	Label *string
+}
+
+// CreatePromptParams represents parameters for creating a new prompt version
+type CreatePromptParams struct {
+	// Name is the prompt name (required)
+	Name string
+
+	// Type is the prompt type (default: inferred from Prompt)
+	Type *string
+
+	// Prompt is a string for text prompts or []ChatMessage for chat prompts (required)
+	Prompt interface{}
+
+	// Config is arbitrary configuration stored with the prompt (model, temperature, ...)
+	Config map[string]interface{}
+
+	// Labels are labels for this version, e.g. "production"
+	Labels []string
+
+	// Tags are tags for the prompt
+	Tags []string
+
+	// CommitMessage describes the change in this version
+	CommitMessage *string
+}
+
+// GetPrompt retrieves a prompt by name, optionally pinned to a version or label
+func (c *Client) GetPrompt(ctx context.Context, params GetPromptParams) (*Prompt, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if params.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	queryParams := url.Values{}
+	if params.Version != nil {
+		queryParams.Set("version", strconv.Itoa(*params.Version))
+	}
+	if params.Label != nil {
+		queryParams.Set("label", *params.Label)
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/v2/prompts/%s", c.config.BaseURL, url.PathEscape(params.Name))
+	if len(queryParams) > 0 {
+		fullURL += "?" + queryParams.Encode()
+	}
+
+	prompt, err := c.fetchJSON(ctx, fullURL, &Prompt{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	}
+
+	return prompt.(*Prompt), nil
+}
+
+// CreatePrompt creates a new version of a prompt
+func (c *Client) CreatePrompt(ctx context.Context, params CreatePromptParams) (*Prompt, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if params.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if params.Prompt == nil {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	promptType := PromptTypeChat
+	if _, ok := params.Prompt.(string); ok {
+		promptType = PromptTypeText
+	}
+	if params.Type != nil {
+		promptType = *params.Type
+	}
+
+	body := map[string]interface{}{
+		"name":   params.Name,
+		"type":   promptType,
+		"prompt": params.Prompt,
+	}
+	if params.Config != nil {
+		body["config"] = params.Config
+	}
+	if params.Labels != nil {
+		body["labels"] = params.Labels
+	}
+	if params.Tags != nil {
+		body["tags"] = params.Tags
+	}
+	if params.CommitMessage != nil {
+		body["commitMessage"] = *params.CommitMessage
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/v2/prompts", c.config.BaseURL)
+
+	prompt, err := c.doJSON(ctx, "POST", fullURL, body, &Prompt{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prompt: %w", err)
+	}
+
+	return prompt.(*Prompt), nil
+}
+
+// WithPrompt links the generation to the prompt version that produced it
+func WithPrompt(prompt *Prompt) GenerationOption {
+	return generationOption(func(p *GenerationParams) {
+		p.PromptName = &prompt.Name
+		p.PromptVersion = &prompt.Version
+	})
+}
+
+// promptVariablePattern matches {{variable}} placeholders, allowing inner spaces
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// CompileOption configures Prompt.Compile and Prompt.CompileChat
+type CompileOption func(*compileOptions)
+
+type compileOptions struct {
+	strict bool
+}
+
+// WithStrictVariables makes compilation fail when a placeholder has no value.
+// By default unknown placeholders are left untouched.
+func WithStrictVariables() CompileOption {
+	return func(o *compileOptions) {
+		o.strict = true
+	}
+}
+
+// Compile replaces the {{variable}} placeholders of a text prompt.
+// Strings are inserted as is; other values are JSON encoded.
+func (p *Prompt) Compile(variables map[string]interface{}, opts ...CompileOption) (string, error) {
+	text, ok := p.Prompt.(string)
+	if !ok {
+		return "", fmt.Errorf("prompt %q is not a text prompt, use CompileChat", p.Name)
+	}
+
+	var missing []string
+	compiled := compilePromptText(text, variables, &missing)
+
+	if err := checkMissingVariables(p.Name, missing, opts); err != nil {
+		return "", err
+	}
+	return compiled, nil
+}
+
+// CompileChat replaces the {{variable}} placeholders in the string contents and
+// text parts of a chat prompt and returns the compiled messages
+func (p *Prompt) CompileChat(variables map[string]interface{}, opts ...CompileOption) ([]ChatMessage, error) {
+	messages, ok := p.Prompt.([]ChatMessage)
+	if !ok {
+		return nil, fmt.Errorf("prompt %q is not a chat prompt, use Compile", p.Name)
+	}
+
+	var missing []string
+	compiled := make([]ChatMessage, len(messages))
+	for i, msg := range messages {
+		switch content := msg.Content.(type) {
+		case string:
+			msg.Content = compilePromptText(content, variables, &missing)
+		case []ContentPart:
+			parts := make([]ContentPart, len(content))
+			for j, part := range content {
+				if part.Type == ContentPartTypeText {
+					part.Text = compilePromptText(part.Text, variables, &missing)
+				}
+				parts[j] = part
+			}
+			msg.Content = parts
+		}
+		compiled[i] = msg
+	}
+
+	if err := checkMissingVariables(p.Name, missing, opts); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// compilePromptText substitutes variables in text, appending unresolved names to missing
+func compilePromptText(text string, variables map[string]interface{}, missing *[]string) string {
+	return promptVariablePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := promptVariablePattern.FindStringSubmatch(placeholder)[1]
+		value, ok := variables[name]
+		if !ok {
+			*missing = append(*missing, name)
+			return placeholder
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(encoded)
+	})
+}
+
+// checkMissingVariables returns an error for unresolved variables in strict mode
+func checkMissingVariables(promptName string, missing []string, opts []CompileOption) error {
+	var options compileOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if !options.strict || len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("prompt %q has unresolved variables: %s", promptName, strings.Join(missing, ", "))
+}
@@ -0,0 +1,188 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChatMessage is an OpenAI-style chat message reconstructed from generation input/output.
+// Content is a string, a []ContentPart for multimodal messages, or any other JSON value.
+type ChatMessage struct {
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`
+	Name       string      `json:"name,omitempty"`
+	ToolCalls  interface{} `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+}
+
+// AnnotatedMessage is a chat message together with the human and automated
+// annotations attached to the generation that produced it
+type AnnotatedMessage struct {
+	Message ChatMessage
+
+	// TraceID is the trace the message was taken from
+	TraceID string
+
+	// GenerationID is the generation the message was taken from
+	GenerationID string
+
+	// Scores are the scores attached to the generation (output messages only)
+	Scores []ScoreData
+
+	// Comments are the comments attached to the generation (output messages only)
+	Comments []Comment
+}
+
+// BuildSessionMessagesParams represents parameters for BuildSessionMessages
+type BuildSessionMessagesParams struct {
+	// SessionID is the session to rebuild (required)
+	SessionID string
+
+	// UpToTraceID is the last trace to include; all traces are included if empty
+	UpToTraceID string
+
+	// IncludeAnnotations additionally fetches scores and comments for each generation
+	IncludeAnnotations bool
+}
+
+// SessionMessages is the conversation reconstructed by BuildSessionMessages
+type SessionMessages struct {
+	// Messages are the chat messages in chronological order
+	Messages []ChatMessage
+
+	// Annotated holds the same messages with their annotations.
+	// It is only populated when IncludeAnnotations is set.
+	Annotated []AnnotatedMessage
+
+	// Errors holds the failures to fetch a trace, whose messages are then
+	// missing, or its generation's comments, which are then empty
+	Errors []error
+}
+
+// BuildSessionMessages rebuilds the chat history of a session from the input
+// and output of the first generation of each trace, oldest trace first.
+// With IncludeAnnotations, scores and comments are matched to each generation
+// by observation ID and attached to the messages taken from its output.
+// A trace that cannot be fetched is skipped and its error recorded in
+// SessionMessages.Errors; only a failure to fetch the session fails the call.
+func (c *Client) BuildSessionMessages(ctx context.Context, params BuildSessionMessagesParams) (*SessionMessages, error) {
+	session, err := c.GetSession(ctx, GetSessionParams{SessionID: params.SessionID})
+	if err != nil {
+		return nil, err
+	}
+
+	traces := session.TracesChronological()
+
+	lastIndex := len(traces) - 1
+	if params.UpToTraceID != "" {
+		lastIndex = -1
+		for i, traceSummary := range traces {
+			if traceSummary.ID == params.UpToTraceID {
+				lastIndex = i
+				break
+			}
+		}
+		if lastIndex == -1 {
+			return nil, fmt.Errorf("trace %s not found in session %s", params.UpToTraceID, params.SessionID)
+		}
+	}
+
+	result := &SessionMessages{}
+	for _, traceSummary := range traces[:lastIndex+1] {
+		trace, err := c.GetTrace(ctx, GetTraceParams{TraceID: traceSummary.ID})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("trace %s: %w", traceSummary.ID, err))
+			continue
+		}
+
+		var generation *ObservationDetails
+		for j := range trace.Observations {
+			if trace.Observations[j].Type == string(ObservationTypeGeneration) {
+				generation = &trace.Observations[j]
+				break
+			}
+		}
+		if generation == nil {
+			continue
+		}
+
+		var scores []ScoreData
+		var comments []Comment
+		if params.IncludeAnnotations {
+			for _, score := range trace.Scores {
+				if score.ObservationID != nil && *score.ObservationID == generation.ID {
+					scores = append(scores, score)
+				}
+			}
+			comments, err = c.listAllComments(ctx, "OBSERVATION", generation.ID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("comments of generation %s: %w", generation.ID, err))
+			}
+		}
+
+		for _, msg := range messagesFromData(generation.Input, "user") {
+			result.add(msg, trace.ID, generation.ID, nil, nil, params.IncludeAnnotations)
+		}
+		for _, msg := range messagesFromData(generation.Output, "assistant") {
+			result.add(msg, trace.ID, generation.ID, scores, comments, params.IncludeAnnotations)
+		}
+	}
+
+	return result, nil
+}
+
+// add appends a message and, if requested, its annotated counterpart
+func (s *SessionMessages) add(msg ChatMessage, traceID, generationID string, scores []ScoreData, comments []Comment, annotate bool) {
+	s.Messages = append(s.Messages, msg)
+	if annotate {
+		s.Annotated = append(s.Annotated, AnnotatedMessage{
+			Message:      msg,
+			TraceID:      traceID,
+			GenerationID: generationID,
+			Scores:       scores,
+			Comments:     comments,
+		})
+	}
+}
+
+// messagesFromData converts generation input or output into chat messages.
+// Arrays of message objects and single message objects are used as is;
+// any other value becomes a single message with the default role.
+func messagesFromData(data interface{}, defaultRole string) []ChatMessage {
+	if data == nil {
+		return nil
+	}
+
+	switch v := data.(type) {
+	case []interface{}:
+		var messages []ChatMessage
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				messages = append(messages, chatMessageFromMap(m))
+			}
+		}
+		return messages
+	case map[string]interface{}:
+		return []ChatMessage{chatMessageFromMap(v)}
+	default:
+		return []ChatMessage{{Role: defaultRole, Content: v}}
+	}
+}
+
+// chatMessageFromMap converts a decoded JSON message object into a ChatMessage
+func chatMessageFromMap(m map[string]interface{}) ChatMessage {
+	msg := ChatMessage{
+		Content:   contentFromData(m["content"]),
+		ToolCalls: m["tool_calls"],
+	}
+	if role, ok := m["role"].(string); ok {
+		msg.Role = role
+	}
+	if name, ok := m["name"].(string); ok {
+		msg.Name = name
+	}
+	if toolCallID, ok := m["tool_call_id"].(string); ok {
+		msg.ToolCallID = toolCallID
+	}
+	return msg
+}
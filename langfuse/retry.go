@@ -0,0 +1,136 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry policy
+//
+// An operation is retried automatically only if it is idempotent and failed
+// with a retryable error (network errors, HTTP 429 and 5xx):
+//   - GET, HEAD, PUT and DELETE requests are idempotent by definition.
+//   - Ingestion is idempotent because the server deduplicates events by ID,
+//     so the batcher requeues events after a retryable error.
+//   - Other POST requests, such as CreatePrompt, are never retried: a request
+//     that failed after reaching the server may already have taken effect,
+//     and the API has no idempotency key to make a second attempt safe.
+//
+// Synchronous requests made with doJSON are retried up to
+// Config.SyncMaxRetryAttempts times with exponential backoff between
+// Config.SyncRetryBaseDelay and Config.SyncRetryMaxDelay, a budget short
+// enough for a caller waiting on the result.
+//
+// A Retry-After header on the failed response (usually with HTTP 429) is
+// respected: the next attempt waits at least that long, even beyond RetryMaxDelay.
+//
+// Ingestion events are retried up to Config.MaxRetryAttempts times each, after
+// which they are recorded as failed. After a retryable error the background
+// flush loop waits for the same backoff, with jitter, before the next attempt;
+// explicit Flush and Close calls send immediately.
+
+// isIdempotentMethod reports whether requests with the HTTP method are idempotent
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry reports whether a failed operation may be retried automatically
+func shouldRetry(err error, idempotent bool) bool {
+	return idempotent && IsRetryableError(err)
+}
+
+// retryDelay returns the backoff before retry number attempt (starting at 1)
+// of ingested events: RetryBaseDelay doubled for each previous attempt,
+// capped at RetryMaxDelay
+func retryDelay(config *Config, attempt int) time.Duration {
+	return backoff(config.RetryBaseDelay, config.RetryMaxDelay, attempt)
+}
+
+// syncRetryDelay is retryDelay for synchronous requests, with
+// SyncRetryBaseDelay and SyncRetryMaxDelay
+func syncRetryDelay(config *Config, attempt int) time.Duration {
+	return backoff(config.SyncRetryBaseDelay, config.SyncRetryMaxDelay, attempt)
+}
+
+// backoff returns base doubled for each attempt before attempt, capped at
+// maxDelay if it is set
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header value, given either as a number
+// of seconds or as an HTTP date. It returns 0 if the value is missing, invalid
+// or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// newResponseError returns the error for an unsuccessful HTTP response,
+// including the wait requested by its Retry-After header
+func newResponseError(resp *http.Response, body []byte) *LangfuseError {
+	err := NewHTTPError(resp.StatusCode, string(body))
+	err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return err
+}
+
+// retryAfter returns the wait requested by the server for err, or 0
+func retryAfter(err error) time.Duration {
+	var lfErr *LangfuseError
+	if errors.As(err, &lfErr) {
+		return lfErr.RetryAfter
+	}
+	return 0
+}
+
+// withJitter randomizes d to between half and all of it, so clients that
+// failed together don't retry together
+func withJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the latter case
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
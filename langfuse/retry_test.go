@@ -0,0 +1,166 @@
+package langfuse
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSyncRequestsRetryWithTheirOwnBudget(t *testing.T) {
+	server := newTestServer(t)
+	var requests atomic.Int32
+	server.handle("GET", "/api/public/traces/t1", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	client := newTestClient(t, server, func(c *Config) {
+		// Ingestion backoff that a GetTrace caller must not wait for
+		c.MaxRetryAttempts = 10
+		c.RetryBaseDelay = time.Hour
+		c.RetryMaxDelay = time.Hour
+		c.SyncMaxRetryAttempts = 2
+	})
+
+	start := time.Now()
+	if _, err := client.GetTrace(context.Background(), GetTraceParams{TraceID: "t1"}); err == nil {
+		t.Fatal("GetTrace succeeded against a failing server")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("sent %d requests, want 1 and 2 retries", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetTrace took %v, want the short sync backoff", elapsed)
+	}
+}
+
+func TestSyncRequestsRecoverAfterRetry(t *testing.T) {
+	server := newTestServer(t)
+	var requests atomic.Int32
+	server.handle("GET", "/api/public/traces/t1", func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, TraceWithFullDetails{})
+	})
+	client := newTestClient(t, server)
+
+	if _, err := client.GetTrace(context.Background(), GetTraceParams{TraceID: "t1"}); err != nil {
+		t.Fatalf("GetTrace: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+func TestDefaultSyncRetryBudgetIsShort(t *testing.T) {
+	config := DefaultConfig()
+	total := time.Duration(0)
+	for attempt := 1; attempt <= config.SyncMaxRetryAttempts; attempt++ {
+		total += syncRetryDelay(config, attempt)
+	}
+	if total > 2*time.Second {
+		t.Errorf("default sync retries wait %v in total, want at most 2s", total)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"1", time.Second},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSyncRequestsWaitForRetryAfter(t *testing.T) {
+	tests := map[string]func() string{
+		"seconds": func() string { return "1" },
+		"HTTP date": func() string {
+			return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+		},
+	}
+	for name, retryAfter := range tests {
+		server := newTestServer(t)
+		var requests atomic.Int32
+		server.handle("GET", "/api/public/traces/t1", func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", retryAfter())
+				http.Error(w, "slow down", http.StatusTooManyRequests)
+				return
+			}
+			writeJSON(w, TraceWithFullDetails{})
+		})
+		client := newTestClient(t, server)
+
+		start := time.Now()
+		if _, err := client.GetTrace(context.Background(), GetTraceParams{TraceID: "t1"}); err != nil {
+			t.Fatalf("GetTrace: %v", err)
+		}
+		if got := requests.Load(); got != 2 {
+			t.Errorf("Retry-After in %s: sent %d requests, want 1 and 1 retry", name, got)
+		}
+		// An HTTP date has whole seconds, so it may be up to a second closer
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Errorf("Retry-After in %s: retried after %v, want at least 1s", name, elapsed)
+		}
+	}
+}
+
+func TestIngestionBacksOffForRetryAfter(t *testing.T) {
+	server := newTestServer(t)
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	client := newTestClient(t, server)
+
+	createTraces(t, client, 1)
+	err := client.Flush(context.Background())
+	if got := retryAfter(err); got != 30*time.Second {
+		t.Fatalf("Flush = %v with Retry-After %v, want 30s", err, got)
+	}
+
+	client.batcher.mu.Lock()
+	wait := time.Until(client.batcher.retryAt)
+	client.batcher.mu.Unlock()
+	if wait < 29*time.Second {
+		t.Errorf("background flushes resume in %v, want at least the 30s of Retry-After", wait)
+	}
+	if n := client.batcher.Len(); n != 1 {
+		t.Errorf("%d events queued, want the event kept for the retry", n)
+	}
+}
+
+func TestPostRequestsAreNotRetried(t *testing.T) {
+	server := newTestServer(t)
+	var requests atomic.Int32
+	server.handle("POST", "/api/public/v2/prompts", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	client := newTestClient(t, server)
+
+	if _, err := client.CreatePrompt(context.Background(), CreatePromptParams{Name: "greeting", Prompt: "Hello"}); err == nil {
+		t.Fatal("CreatePrompt succeeded against a 429")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("sent %d requests, want the POST not retried", got)
+	}
+}
@@ -0,0 +1,107 @@
+package langfuse
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+)
+
+// maxUnsampledObservations bounds how many observations of unsampled traces
+// are remembered to drop their updates, which carry no trace ID
+const maxUnsampledObservations = 10000
+
+// sampler drops the events of traces that are not sampled with Config.SampleRate
+type sampler struct {
+	rate float64
+
+	mu           sync.Mutex
+	observations map[string]*list.Element // IDs of observations of unsampled traces
+	order        *list.List               // front is the most recently created
+}
+
+// newSampler returns a sampler keeping the given fraction of traces, or every
+// trace if rate is nil
+func newSampler(rate *float64) *sampler {
+	s := &sampler{
+		rate:         1,
+		observations: make(map[string]*list.Element),
+		order:        list.New(),
+	}
+	if rate != nil {
+		s.rate = *rate
+	}
+	return s
+}
+
+// sampled reports whether the trace with the given ID is kept. The decision
+// depends only on the ID, so every event of a trace gets the same one, in
+// this and in any other process with the same SampleRate.
+func (s *sampler) sampled(traceID string) bool {
+	if s.rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(traceID))
+	// Use the top 53 bits, which a float64 holds exactly
+	return float64(h.Sum64()>>11)/(1<<53) < s.rate
+}
+
+// keep reports whether an event is sent: events of unsampled traces are
+// dropped, including updates of their observations, which carry no trace ID.
+// Events of no trace, such as SDK logs, are always kept.
+func (s *sampler) keep(event Event) bool {
+	if s.rate >= 1 {
+		return true
+	}
+
+	id, _ := event.Body["id"].(string)
+	traceID, _ := event.Body["traceId"].(string)
+	if event.Type == EventTypeTraceCreate {
+		traceID = id
+	}
+	if traceID == "" {
+		return !s.unsampledObservation(id)
+	}
+
+	if s.sampled(traceID) {
+		return true
+	}
+	if observationCreateTypes[event.Type] && id != "" {
+		s.rememberObservation(id)
+	}
+	return false
+}
+
+// rememberObservation records the ID of an observation of an unsampled trace,
+// forgetting the oldest one if too many are remembered
+func (s *sampler) rememberObservation(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.observations[id]; ok {
+		return
+	}
+	s.observations[id] = s.order.PushFront(id)
+	for s.order.Len() > maxUnsampledObservations {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.observations, oldest.Value.(string))
+	}
+}
+
+// unsampledObservation reports whether id is an observation of an unsampled trace
+func (s *sampler) unsampledObservation(id string) bool {
+	if id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.observations[id]
+	return ok
+}
+
+// Sampled reports whether the trace is kept with Config.SampleRate. The
+// events of a trace that is not sampled are dropped without being sent.
+func (t *Trace) Sampled() bool {
+	return t.client.sampler.sampled(t.id)
+}
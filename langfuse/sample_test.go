@@ -0,0 +1,71 @@
+package langfuse
+
+import "testing"
+
+func TestSampleRate(t *testing.T) {
+	tests := []struct {
+		name string
+		rate *float64
+		want func(sent int) bool
+	}{
+		{"unset sends every trace", nil, func(sent int) bool { return sent == 20 }},
+		{"1 sends every trace", Ptr(1.0), func(sent int) bool { return sent == 20 }},
+		{"0 drops every trace", Ptr(0.0), func(sent int) bool { return sent == 0 }},
+		{"0.5 sends some traces", Ptr(0.5), func(sent int) bool { return sent > 0 && sent < 20 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			client := newTestClient(t, server, func(c *Config) {
+				c.SampleRate = tt.rate
+				c.MetricsEnabled = true
+			})
+
+			sampled := 0
+			for i := 0; i < 20; i++ {
+				trace, err := client.CreateTrace(TraceParams{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				span, err := trace.StartSpan(NewSpan("step"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := span.End(); err != nil {
+					t.Fatal(err)
+				}
+				if trace.Sampled() {
+					sampled++
+				}
+			}
+			flush(t, client)
+
+			sent := len(server.eventsOfType(EventTypeTraceCreate))
+			if !tt.want(sent) {
+				t.Errorf("sent %d of 20 traces", sent)
+			}
+			if sent != sampled {
+				t.Errorf("sent %d traces, but Sampled reported %d", sent, sampled)
+			}
+			if n := len(server.Events()); n != 3*sent {
+				t.Errorf("sent %d events for %d traces, want each trace's events all sent or all dropped", n, sent)
+			}
+			if n := client.GetMetrics().DroppedByReason[DropReasonSampled]; n != int64(3*(20-sent)) {
+				t.Errorf("%d events recorded as sampled out, want %d", n, 3*(20-sent))
+			}
+		})
+	}
+}
+
+func TestSampleRateIsValidated(t *testing.T) {
+	for _, rate := range []float64{-0.1, 0, 0.5, 1, 1.5} {
+		config := DefaultConfig()
+		config.PublicKey = "pk"
+		config.SecretKey = "sk"
+		config.SampleRate = Ptr(rate)
+		err := config.Validate()
+		if valid := rate >= 0 && rate <= 1; valid != (err == nil) {
+			t.Errorf("Validate with SampleRate %v = %v", rate, err)
+		}
+	}
+}
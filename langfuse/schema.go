@@ -0,0 +1,310 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Metadata keys for structured output schemas
+const (
+	// MetadataKeyOutputSchema holds a generation's GenerationParams.OutputSchema
+	MetadataKeyOutputSchema = "output_schema"
+
+	// MetadataKeySchemaValid holds whether a generation's output matched its
+	// OutputSchema, see Config.ValidateOutputSchema
+	MetadataKeySchemaValid = "schema_valid"
+)
+
+// ResponseFormatSchema returns the JSON schema of an OpenAI-style chat
+// completion request that uses structured outputs
+// (response_format.json_schema.schema), or nil if it has none. request can
+// be any value that encodes to the request JSON, such as a request struct of
+// an OpenAI client library or a map.
+func ResponseFormatSchema(request interface{}) interface{} {
+	var decoded struct {
+		ResponseFormat *struct {
+			JSONSchema *struct {
+				Schema json.RawMessage `json:"schema"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil || json.Unmarshal(data, &decoded) != nil {
+		return nil
+	}
+	if decoded.ResponseFormat == nil || decoded.ResponseFormat.JSONSchema == nil {
+		return nil
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal(decoded.ResponseFormat.JSONSchema.Schema, &schema); err != nil {
+		return nil
+	}
+	return schema
+}
+
+// ValidateJSONSchema checks value against a JSON schema and returns an error
+// describing the first violation, or nil if value conforms. schema and value
+// can be anything that encodes to JSON; a string value holding JSON, such as
+// a model's JSON mode response, is decoded first.
+//
+// This is a small validator for the subset of JSON Schema used by structured
+// outputs: type, enum, const, properties, required, additionalProperties,
+// items, anyOf and local $ref to $defs or definitions. Other keywords are ignored.
+func ValidateJSONSchema(schema, value interface{}) error {
+	root, err := normalizeJSON(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	rootSchema, ok := root.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid schema: not a JSON object")
+	}
+
+	if s, ok := value.(string); ok {
+		var decoded interface{}
+		if json.Unmarshal([]byte(s), &decoded) == nil {
+			value = decoded
+		}
+	}
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+
+	v := schemaValidator{root: rootSchema}
+	return v.validate(rootSchema, normalized, "$")
+}
+
+// normalizeJSON converts v to the generic form produced by decoding JSON
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// schemaValidator validates values against a root schema
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+// maxRefDepth bounds $ref resolution, so recursive schemas cannot loop forever
+const maxRefDepth = 64
+
+func (v schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) error {
+	for depth := 0; ; depth++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			break
+		}
+		if depth == maxRefDepth {
+			return fmt.Errorf("%s: $ref %q nests too deeply", path, ref)
+		}
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		schema = resolved
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok {
+		matched := false
+		for _, t := range types {
+			if hasJSONType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: value does not equal the constant", path)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, option := range anyOf {
+			if optionSchema, ok := option.(map[string]interface{}); ok && v.validate(optionSchema, value, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value matches none of anyOf", path)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(schema, value, path)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := value[key]; !present {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Check keys in a stable order so the reported violation is deterministic
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := path + "." + key
+		if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+			if err := v.validate(propertySchema, value[key], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, declared := properties[key]; declared {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property is not allowed", propertyPath)
+			}
+		case map[string]interface{}:
+			if err := v.validate(additional, value[key], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve looks up a local reference such as "#/$defs/step"
+func (v schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	var current interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		current = object[part]
+	}
+	schema, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return schema, nil
+}
+
+// schemaTypes returns the types allowed by a schema's "type" keyword
+func schemaTypes(t interface{}) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+// hasJSONType reports whether a decoded JSON value has the JSON Schema type t
+func hasJSONType(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true // unknown types are not checked
+}
+
+// jsonTypeName returns the JSON type of a decoded JSON value
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -1,9 +1,35 @@
 package langfuse
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
+// Score sources
+const (
+	// ScoreSourceAPI is a score created through the API, including this SDK
+	ScoreSourceAPI = "API"
+
+	// ScoreSourceEval is a score produced by a Langfuse-managed evaluator
+	ScoreSourceEval = "EVAL"
+
+	// ScoreSourceAnnotation is a score added by a human in the Langfuse UI
+	ScoreSourceAnnotation = "ANNOTATION"
+)
+
+// Score data types
+const (
+	// ScoreDataTypeNumeric is a score with a numeric Value
+	ScoreDataTypeNumeric = "NUMERIC"
+
+	// ScoreDataTypeCategorical is a score with a StringValue
+	ScoreDataTypeCategorical = "CATEGORICAL"
+
+	// ScoreDataTypeBoolean is a score with a Value of 0 or 1
+	ScoreDataTypeBoolean = "BOOLEAN"
+)
+
 // ScoreParams contains parameters for creating a score
 type ScoreParams struct {
 	// ID is the unique identifier (auto-generated if not provided)
@@ -18,22 +44,52 @@ type ScoreParams struct {
 	// Name is the name/identifier of the score (required)
 	Name string
 
-	// Value is the numeric score value (required)
+	// Value is the numeric score value (required unless the score is
+	// CATEGORICAL); 0 or 1 for a BOOLEAN score
 	Value float64
 
+	// StringValue is the value of a CATEGORICAL score, e.g. "positive".
+	// Setting it makes the score CATEGORICAL unless DataType is set.
+	StringValue *string
+
 	// Comment is an optional comment about the score
 	Comment *string
 
-	// DataType is the type of score (default: "NUMERIC", can be "CATEGORICAL", "BOOLEAN")
+	// DataType is the type of score (default: "NUMERIC", or "CATEGORICAL" if
+	// StringValue is set; can be "CATEGORICAL", "BOOLEAN")
 	DataType *string
 
 	// ConfigID links the score to a score config
 	ConfigID *string
+
+	// Source is the score source, sent as "source". The ingestion API records
+	// every score it receives as ScoreSourceAPI, so that is the only accepted
+	// value; leaving it nil has the same effect. To tell your own evaluation
+	// scores apart, use a distinct Name or ConfigID.
+	Source *string
 }
 
 // CreateScore creates a new score for a trace or observation
 func (c *Client) CreateScore(params ScoreParams) (string, error) {
-	id := generateID()
+	return c.createScore(params, c.enqueue)
+}
+
+// CreateScoreWithContext is like CreateScore, but if the queue is full it
+// waits for space until ctx is done instead of dropping the score
+func (c *Client) CreateScoreWithContext(ctx context.Context, params ScoreParams) (string, error) {
+	return c.createScore(params, c.enqueueWaiting(ctx))
+}
+
+// createScore creates a new score, queuing its event with enqueue
+func (c *Client) createScore(params ScoreParams, enqueue func(Event) error) (string, error) {
+	if params.Source != nil && *params.Source != ScoreSourceAPI {
+		return "", fmt.Errorf("score source %s cannot be set through ingestion, only %s is allowed", *params.Source, ScoreSourceAPI)
+	}
+	if err := validateScoreValue(params); err != nil {
+		return "", err
+	}
+
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
 	}
@@ -41,13 +97,13 @@ func (c *Client) CreateScore(params ScoreParams) (string, error) {
 	body := scoreToBody(params, id)
 
 	event := Event{
-		ID:        generateID(),
+		ID:        c.generateID(),
 		Type:      EventTypeScoreCreate,
 		Timestamp: time.Now(),
 		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
+	if err := enqueue(event); err != nil {
 		return "", err
 	}
 
@@ -57,9 +113,95 @@ func (c *Client) CreateScore(params ScoreParams) (string, error) {
 // CreateScore creates a new score for this trace
 func (t *Trace) CreateScore(params ScoreParams) (string, error) {
 	params.TraceID = &t.id
+	if err := t.sendCreate(t.client.enqueue); err != nil {
+		return "", err
+	}
 	return t.client.CreateScore(params)
 }
 
+// CategoricalScoreValueSuffix is appended to the name of a categorical score
+// to name the NUMERIC score CreateCategoricalScore records alongside it
+const CategoricalScoreValueSuffix = "_value"
+
+// CreateCategoricalScore scores this trace with a category and, so that it
+// can be charted, with the number mapping assigns to it: it records a
+// CATEGORICAL score name with the category, linked to the score config
+// configID if it is not nil, and a NUMERIC score name +
+// CategoricalScoreValueSuffix with the mapped value. The category must be in
+// mapping. It returns the IDs of both scores.
+func (t *Trace) CreateCategoricalScore(name, category string, mapping map[string]float64, configID *string) (categoricalID, numericID string, err error) {
+	value, ok := mapping[category]
+	if !ok {
+		return "", "", fmt.Errorf("score %q: category %q is not in the mapping", name, category)
+	}
+
+	categoricalID, err = t.CreateScore(ScoreParams{
+		Name:        name,
+		StringValue: &category,
+		ConfigID:    configID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	numericID, err = t.CreateScore(ScoreParams{
+		Name:  name + CategoricalScoreValueSuffix,
+		Value: value,
+	})
+	if err != nil {
+		return categoricalID, "", err
+	}
+	return categoricalID, numericID, nil
+}
+
+// ScoreOption configures the params of a score created with a handle's Score method
+type ScoreOption func(*ScoreParams)
+
+// WithComment sets the score comment
+func WithComment(comment string) ScoreOption {
+	return func(p *ScoreParams) {
+		p.Comment = &comment
+	}
+}
+
+// WithDataType sets the score data type ("NUMERIC", "CATEGORICAL" or "BOOLEAN")
+func WithDataType(dataType string) ScoreOption {
+	return func(p *ScoreParams) {
+		p.DataType = &dataType
+	}
+}
+
+// WithStringValue sets the value of a CATEGORICAL score, whose numeric value
+// must then be 0
+func WithStringValue(value string) ScoreOption {
+	return func(p *ScoreParams) {
+		p.StringValue = &value
+	}
+}
+
+// WithConfigID links the score to a score config
+func WithConfigID(configID string) ScoreOption {
+	return func(p *ScoreParams) {
+		p.ConfigID = &configID
+	}
+}
+
+// Score creates a score for the observation and its trace
+func (h *observationHandle) Score(name string, value float64, opts ...ScoreOption) error {
+	params := ScoreParams{
+		TraceID:       &h.traceID,
+		ObservationID: &h.id,
+		Name:          name,
+		Value:         value,
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	_, err := h.client.CreateScore(params)
+	return err
+}
+
 // scoreToBody converts score params to event body
 func scoreToBody(params ScoreParams, id string) map[string]interface{} {
 	body := make(map[string]interface{})
@@ -67,28 +209,72 @@ func scoreToBody(params ScoreParams, id string) map[string]interface{} {
 	body["id"] = id
 	body["name"] = params.Name
 	body["value"] = params.Value
+	if params.StringValue != nil {
+		// The ingestion API takes the value of a CATEGORICAL score as a string
+		body["value"] = *params.StringValue
+	}
 
-	if params.TraceID != nil {
+	if params.TraceID != nil && *params.TraceID != "" {
 		body["traceId"] = *params.TraceID
 	}
 
-	if params.ObservationID != nil {
+	if params.ObservationID != nil && *params.ObservationID != "" {
 		body["observationId"] = *params.ObservationID
 	}
 
-	if params.Comment != nil {
+	if params.Comment != nil && *params.Comment != "" {
 		body["comment"] = *params.Comment
 	}
 
-	if params.DataType != nil {
-		body["dataType"] = *params.DataType
-	} else {
-		body["dataType"] = "NUMERIC"
-	}
+	body["dataType"] = scoreDataType(params)
 
-	if params.ConfigID != nil {
+	if params.ConfigID != nil && *params.ConfigID != "" {
 		body["configId"] = *params.ConfigID
 	}
 
+	if params.Source != nil && *params.Source != "" {
+		body["source"] = *params.Source
+	}
+
 	return body
 }
+
+// scoreDataType returns the data type of a score: DataType, or the default
+// for the kind of value it has
+func scoreDataType(params ScoreParams) string {
+	if params.DataType != nil && *params.DataType != "" {
+		return *params.DataType
+	}
+	if params.StringValue != nil {
+		return ScoreDataTypeCategorical
+	}
+	return ScoreDataTypeNumeric
+}
+
+// validateScoreValue checks that a score's value fits its data type, since
+// the ingestion API would only reject a malformed score after it is flushed
+func validateScoreValue(params ScoreParams) error {
+	switch dataType := scoreDataType(params); dataType {
+	case ScoreDataTypeNumeric:
+		if params.StringValue != nil {
+			return fmt.Errorf("score %q: a NUMERIC score takes Value, not StringValue", params.Name)
+		}
+	case ScoreDataTypeBoolean:
+		if params.StringValue != nil {
+			return fmt.Errorf("score %q: a BOOLEAN score takes Value, not StringValue", params.Name)
+		}
+		if params.Value != 0 && params.Value != 1 {
+			return fmt.Errorf("score %q: a BOOLEAN score must have Value 0 or 1, got %v", params.Name, params.Value)
+		}
+	case ScoreDataTypeCategorical:
+		if params.StringValue == nil || *params.StringValue == "" {
+			return fmt.Errorf("score %q: a CATEGORICAL score requires StringValue", params.Name)
+		}
+		if params.Value != 0 {
+			return fmt.Errorf("score %q: a CATEGORICAL score takes StringValue, not Value", params.Name)
+		}
+	default:
+		return fmt.Errorf("score %q: unknown data type %q", params.Name, dataType)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package langfuse
+
+import "testing"
+
+func TestCreateCategoricalScore(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := map[string]float64{"negative": 0, "neutral": 0.5, "positive": 1}
+	categoricalID, numericID, err := trace.CreateCategoricalScore("sentiment", "neutral", mapping, Ptr("config-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := trace.CreateCategoricalScore("sentiment", "angry", mapping, nil); err == nil {
+		t.Error("CreateCategoricalScore accepted a category missing from the mapping")
+	}
+	flush(t, client)
+
+	scores := map[interface{}]map[string]interface{}{}
+	for _, e := range server.eventsOfType(EventTypeScoreCreate) {
+		scores[e.Body["id"]] = e.Body
+	}
+	if len(scores) != 2 {
+		t.Fatalf("got %d scores, want the categorical and the numeric one", len(scores))
+	}
+
+	categorical := scores[categoricalID]
+	if categorical["name"] != "sentiment" || categorical["value"] != "neutral" ||
+		categorical["dataType"] != ScoreDataTypeCategorical || categorical["configId"] != "config-1" {
+		t.Errorf("categorical score = %v", categorical)
+	}
+	if categorical["traceId"] != trace.ID() {
+		t.Errorf("categorical score traceId = %v, want %s", categorical["traceId"], trace.ID())
+	}
+
+	numeric := scores[numericID]
+	if numeric["name"] != "sentiment"+CategoricalScoreValueSuffix || numeric["value"] != 0.5 ||
+		numeric["dataType"] != ScoreDataTypeNumeric {
+		t.Errorf("numeric score = %v", numeric)
+	}
+	if _, ok := numeric["configId"]; ok {
+		t.Error("the numeric score was linked to the categorical score config")
+	}
+}
+
+func TestScoreSourceIsSent(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trace.CreateScore(ScoreParams{Name: "accuracy", Value: 1, Source: Ptr(ScoreSourceAPI)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trace.CreateScore(ScoreParams{Name: "accuracy", Value: 1, Source: Ptr(ScoreSourceEval)}); err == nil {
+		t.Error("CreateScore accepted the EVAL source, which ingestion cannot set")
+	}
+	flush(t, client)
+
+	body := onlyEvent(t, server, EventTypeScoreCreate)
+	if body["source"] != ScoreSourceAPI {
+		t.Errorf("score source = %v, want %s", body["source"], ScoreSourceAPI)
+	}
+}
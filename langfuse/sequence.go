@@ -0,0 +1,164 @@
+package langfuse
+
+import "sort"
+
+// MetadataKeySequence is the reserved observation metadata key holding the
+// per-trace creation sequence number (1, 2, 3, ...). Observations that share a
+// start time can be ordered deterministically by it.
+const MetadataKeySequence = "observationSequence"
+
+// maxSequenceTraces bounds the number of traces whose sequence counters are kept.
+// When it is exceeded the oldest trace's counter is dropped, so an observation
+// created on a trace that old restarts from 1.
+const maxSequenceTraces = 10000
+
+// observationCreateTypes are the event types that get a sequence number
+var observationCreateTypes = map[EventType]bool{
+	EventTypeSpanCreate:       true,
+	EventTypeEventCreate:      true,
+	EventTypeGenerationCreate: true,
+	EventTypeAgentCreate:      true,
+	EventTypeToolCreate:       true,
+	EventTypeChainCreate:      true,
+	EventTypeRetrieverCreate:  true,
+	EventTypeEvaluatorCreate:  true,
+	EventTypeEmbeddingCreate:  true,
+	EventTypeGuardrailCreate:  true,
+}
+
+// sequenceCounter hands out monotonic per-trace sequence numbers.
+// It is not safe for concurrent use; the client guards it with its mutex.
+type sequenceCounter struct {
+	counts map[string]int64
+	order  []string // trace IDs in first-seen order, for eviction
+}
+
+func newSequenceCounter() *sequenceCounter {
+	return &sequenceCounter{counts: make(map[string]int64)}
+}
+
+// next returns the next sequence number for the trace
+func (s *sequenceCounter) next(traceID string) int64 {
+	if _, ok := s.counts[traceID]; !ok {
+		if len(s.order) >= maxSequenceTraces {
+			delete(s.counts, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, traceID)
+	}
+	s.counts[traceID]++
+	return s.counts[traceID]
+}
+
+// stampSequence adds the next sequence number of the event's trace to the
+// metadata of an observation create event. The metadata map is copied so the
+// caller's params are not modified.
+func (s *sequenceCounter) stampSequence(event *Event) {
+	if !observationCreateTypes[event.Type] || event.upsert {
+		return
+	}
+	body := event.Body
+	traceID, ok := body["traceId"].(string)
+	if !ok || traceID == "" {
+		return
+	}
+
+	existing, _ := body["metadata"].(map[string]interface{})
+	metadata := make(map[string]interface{}, len(existing)+1)
+	for k, v := range existing {
+		metadata[k] = v
+	}
+	metadata[MetadataKeySequence] = s.next(traceID)
+	body["metadata"] = metadata
+}
+
+// Sequence returns the creation sequence number stamped into the observation's
+// metadata, and false if the observation has none
+func (o *ObservationDetails) Sequence() (int64, bool) {
+	switch v := o.Metadata[MetadataKeySequence].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// orderByTrace returns events grouped by trace, in the order in which each
+// trace first appears, with a trace's trace-create events ahead of its other
+// events. Otherwise events keep their queue order, which is the order in which
+// they were created, so no trace is ingested out of order when several traces
+// share a batch. Observation updates carry no trace ID; they join the trace of
+// their observation's create event if it is among events, and otherwise stay
+// grouped with the other events of their observation.
+func orderByTrace(events []Event) []Event {
+	observationTraces := make(map[string]string)
+	for _, e := range events {
+		if observationCreateTypes[e.Type] {
+			id, _ := e.Body["id"].(string)
+			traceID, _ := e.Body["traceId"].(string)
+			if id != "" && traceID != "" {
+				observationTraces[id] = traceID
+			}
+		}
+	}
+
+	type orderKey struct {
+		group int // position of the event's group
+		rank  int // 0 for trace-create events, which go first in their group
+		index int // position in the queue
+	}
+
+	groups := make(map[string]int)
+	keys := make([]orderKey, len(events))
+	for i, e := range events {
+		group := eventGroup(e, observationTraces)
+		position, ok := groups[group]
+		if !ok {
+			position = len(groups)
+			groups[group] = position
+		}
+		rank := 1
+		if e.Type == EventTypeTraceCreate {
+			rank = 0
+		}
+		keys[i] = orderKey{group: position, rank: rank, index: i}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		return a.index < b.index
+	})
+
+	ordered := make([]Event, len(events))
+	for i, key := range keys {
+		ordered[i] = events[key.index]
+	}
+	return ordered
+}
+
+// eventGroup returns the key of the group an event is ordered in
+func eventGroup(e Event, observationTraces map[string]string) string {
+	id, _ := e.Body["id"].(string)
+	if e.Type == EventTypeTraceCreate && id != "" {
+		return "trace:" + id
+	}
+	if traceID, _ := e.Body["traceId"].(string); traceID != "" {
+		return "trace:" + traceID
+	}
+	if traceID, ok := observationTraces[id]; ok {
+		return "trace:" + traceID
+	}
+	if id != "" {
+		return "observation:" + id
+	}
+	return "event:" + e.ID
+}
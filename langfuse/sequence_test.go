@@ -0,0 +1,131 @@
+package langfuse
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOrderByTraceGroupsInterleavedTraces(t *testing.T) {
+	event := func(id string, typ EventType, body map[string]interface{}) Event {
+		return Event{ID: id, Type: typ, Body: body}
+	}
+	events := []Event{
+		event("e1", EventTypeSpanCreate, map[string]interface{}{"id": "a1", "traceId": "A"}),
+		event("e2", EventTypeTraceCreate, map[string]interface{}{"id": "B"}),
+		event("e3", EventTypeTraceCreate, map[string]interface{}{"id": "A"}),
+		event("e4", EventTypeSpanCreate, map[string]interface{}{"id": "b1", "traceId": "B"}),
+		event("e5", EventTypeSpanUpdate, map[string]interface{}{"id": "a1"}),
+		event("e6", EventTypeGenerationCreate, map[string]interface{}{"id": "a2", "traceId": "A"}),
+		event("e7", EventTypeSpanUpdate, map[string]interface{}{"id": "b1"}),
+	}
+
+	var got []string
+	for _, e := range orderByTrace(events) {
+		got = append(got, e.ID)
+	}
+	want := []string{"e3", "e1", "e5", "e6", "e2", "e4", "e7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orderByTrace = %v, want %v", got, want)
+	}
+}
+
+func TestFlushSendsEachTraceCreateBeforeItsObservations(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	first, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		for _, trace := range []*Trace{second, first} {
+			span, err := trace.StartSpan(NewSpan("step"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := span.End(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	flush(t, client)
+
+	created := map[string]bool{}
+	spanTraces := map[string]string{}
+	for _, e := range server.Events() {
+		id, _ := e.Body["id"].(string)
+		switch e.Type {
+		case EventTypeTraceCreate:
+			created[id] = true
+		case EventTypeSpanCreate:
+			traceID, _ := e.Body["traceId"].(string)
+			spanTraces[id] = traceID
+			if !created[traceID] {
+				t.Errorf("span %s was sent before its trace %s", id, traceID)
+			}
+		case EventTypeSpanUpdate:
+			if _, ok := spanTraces[id]; !ok {
+				t.Errorf("update of span %s was sent before its create", id)
+			}
+		}
+	}
+}
+
+func TestFailedBatchIsResentBeforeTheBatchesAfterIt(t *testing.T) {
+	server := newTestServer(t)
+	var requests atomic.Int32
+	server.handle("POST", "/api/public/ingestion", func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ingest(w, r)
+	})
+	client := newTestClient(t, server, func(c *Config) {
+		c.MaxBatchBytes = 1000
+	})
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{string(EventTypeTraceCreate) + " " + trace.ID()}
+	for i := 0; i < 3; i++ {
+		span, err := trace.StartSpan(NewSpan("step", WithInput(strings.Repeat("x", 400))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := span.End(); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, string(EventTypeSpanCreate)+" "+span.ObservationID(), string(EventTypeSpanUpdate)+" "+span.ObservationID())
+	}
+
+	if err := client.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded, want the 503 of the first batch")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("sent %d requests, want the batches after the failed one kept for the next flush", n)
+	}
+	flush(t, client)
+	if n := requests.Load(); n < 3 {
+		t.Fatalf("sent %d requests, want the events split into several batches", n)
+	}
+
+	var got []string
+	for _, e := range server.Events() {
+		id, _ := e.Body["id"].(string)
+		got = append(got, string(e.Type)+" "+id)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events were sent in the order\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a fake Langfuse API. It accepts every ingested event and
+// records it, and answers other requests with the handlers registered by the
+// test, or 404.
+type testServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	events   []Event
+	handlers map[string]http.HandlerFunc
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{handlers: make(map[string]http.HandlerFunc)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// handle registers the handler of requests to path with method, e.g.
+// handle("GET", "/api/public/traces/t1", ...)
+func (s *testServer) handle(method, path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = handler
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	handler := s.handlers[r.Method+" "+r.URL.Path]
+	s.mu.Unlock()
+
+	switch {
+	case handler != nil:
+		handler(w, r)
+	case r.Method == "POST" && r.URL.Path == "/api/public/ingestion":
+		s.ingest(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ingest records a batch and reports all its events as accepted
+func (s *testServer) ingest(w http.ResponseWriter, r *http.Request) {
+	var req IngestionRequest
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, req.Batch...)
+	s.mu.Unlock()
+
+	var resp IngestionResponse
+	for _, e := range req.Batch {
+		resp.Successes = append(resp.Successes, SuccessResult{ID: e.ID, Status: http.StatusCreated})
+	}
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Events returns the events ingested so far
+func (s *testServer) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// eventsOfType returns the ingested events of type typ
+func (s *testServer) eventsOfType(typ EventType) []Event {
+	var events []Event
+	for _, e := range s.Events() {
+		if e.Type == typ {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// newTestClient returns a client sending to server that only flushes when
+// told to. configure, if given, adjusts the config first.
+func newTestClient(t *testing.T, server *testServer, configure ...func(*Config)) *Client {
+	t.Helper()
+	return newTestClientFS(t, server, osFS{}, configure...)
+}
+
+// newTestClientFS is newTestClient keeping the client's queue files in fs
+func newTestClientFS(t *testing.T, server *testServer, fs queueFS, configure ...func(*Config)) *Client {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = server.URL
+	config.FlushAt = 1000
+	config.FlushInterval = time.Hour
+	config.RetryBaseDelay = time.Millisecond
+	config.RetryMaxDelay = time.Millisecond
+	config.SyncRetryBaseDelay = time.Millisecond
+	config.SyncRetryMaxDelay = time.Millisecond
+	for _, f := range configure {
+		f(config)
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	client, err := newClient(config, fs)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// flush sends the client's queued events
+func flush(t *testing.T, client *Client) {
+	t.Helper()
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+}
+
+// writeJSON answers a request with v as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// testLogger records the info messages and warnings it is given
+type testLogger struct {
+	mu       sync.Mutex
+	infos    []string
+	warnings []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {}
+func (l *testLogger) Errorf(format string, args ...interface{}) {}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+// Warnings returns the warnings logged so far
+func (l *testLogger) Warnings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.warnings...)
+}
+
+// Infos returns the info messages logged so far
+func (l *testLogger) Infos() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.infos...)
+}
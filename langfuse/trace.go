@@ -1,12 +1,19 @@
 package langfuse
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 )
 
 // TraceParams contains parameters for creating a trace
 type TraceParams struct {
-	// ID is the unique identifier for the trace (auto-generated if not provided)
+	// ID is the unique identifier for the trace (auto-generated if not provided).
+	// Besides UUIDs it can be a 32 hex character W3C trace ID, see
+	// TraceIDFromTraceparent.
 	ID *string
 
 	// Name is the name of the trace
@@ -44,42 +51,135 @@ type TraceParams struct {
 
 	// Public indicates if the trace is publicly accessible
 	Public *bool
+
+	// Clear lists fields to erase on the server, named as in the API body:
+	// "name", "input", "output", "metadata", "userId", "sessionId", "tags"
+	// or "public". They are sent as null.
+	Clear []string
 }
 
 // Trace represents a trace object
 type Trace struct {
+	*traceState
 	client *Client
+}
+
+// traceState is what a Trace knows about its trace. The trace cache keeps it
+// rather than the Trace, so cached traces do not keep the Client reachable
+// and its finalizer can still run.
+type traceState struct {
 	id     string
 	params TraceParams
+
+	mu           sync.Mutex
+	observations []ObservationRecord
+	lastSent     map[string]interface{} // full body of the last trace event, for DiffTraceUpdates
+	startedAt    time.Time              // Timestamp, or when the trace was created
+	ended        bool
+	open         map[string]ObservationType // observations started with a handle and not yet ended
+	pending      bool                       // the trace-create event is deferred, see Config.DeferTraceCreation
+}
+
+// ObservationRecord is an entry in a trace's local observation log.
+// Only observations created through the Trace's Create* methods are recorded.
+type ObservationRecord struct {
+	// ID is the observation ID
+	ID string
+
+	// Type is the observation type as reported by the API (SPAN, GENERATION, TOOL, ...)
+	Type string
+
+	// Name is the observation name (empty if not set)
+	Name string
+
+	// ParentObservationID is the parent observation ID, if any
+	ParentObservationID *string
+
+	// CreatedAt is when the observation was created locally
+	CreatedAt time.Time
 }
 
 // CreateTrace creates a new trace
 func (c *Client) CreateTrace(params TraceParams) (*Trace, error) {
+	return c.createTrace(params, c.enqueue)
+}
+
+// CreateTraceWithContext is like CreateTrace, but if the queue is full it
+// waits for space until ctx is done instead of dropping the trace
+func (c *Client) CreateTraceWithContext(ctx context.Context, params TraceParams) (*Trace, error) {
+	return c.createTrace(params, c.enqueueWaiting(ctx))
+}
+
+// createTrace creates a new trace, queuing its event with enqueue
+func (c *Client) createTrace(params TraceParams, enqueue func(Event) error) (*Trace, error) {
 	// Generate ID if not provided
-	id := generateID()
+	id := c.generateID()
 	if params.ID != nil {
 		id = *params.ID
+		if err := validateTraceID(id); err != nil {
+			return nil, err
+		}
 	}
 
 	trace := &Trace{
+		traceState: &traceState{
+			id:        id,
+			params:    params,
+			startedAt: time.Now(),
+		},
 		client: c,
-		id:     id,
-		params: params,
+	}
+	if params.Timestamp != nil {
+		trace.startedAt = *params.Timestamp
+	}
+	trace.params.Clear = nil
+	if err := trace.clearLocked(params.Clear); err != nil {
+		return nil, err
 	}
 
-	// Create trace event
+	trace.pending = true
+	if !c.config.DeferTraceCreation {
+		if err := trace.sendCreateLocked(enqueue, params.Clear); err != nil {
+			return nil, err
+		}
+	}
+	c.traces.add(trace.traceState)
+
+	return trace, nil
+}
+
+// sendCreateLocked queues the trace-create event with the trace's current
+// state, with the cleared fields as null, unless it was already queued;
+// t.mu must be held
+func (t *Trace) sendCreateLocked(enqueue func(Event) error, cleared []string) error {
+	if !t.pending {
+		return nil
+	}
+
+	body := t.toBody()
+	applyClear(body, cleared)
 	event := Event{
-		ID:        generateID(),
+		ID:        t.client.generateID(),
 		Type:      EventTypeTraceCreate,
 		Timestamp: time.Now(),
-		Body:      trace.toBody(),
+		Body:      body,
 	}
 
-	if err := c.enqueue(event); err != nil {
-		return nil, err
+	if err := enqueue(event); err != nil {
+		return err
 	}
 
-	return trace, nil
+	t.pending = false
+	t.lastSent = snapshotBody(body)
+	return nil
+}
+
+// sendCreate queues the deferred trace-create event of a trace created with
+// Config.DeferTraceCreation before its first observation or score is queued
+func (t *Trace) sendCreate(enqueue func(Event) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sendCreateLocked(enqueue, nil)
 }
 
 // toBody converts trace params to event body
@@ -88,7 +188,7 @@ func (t *Trace) toBody() map[string]interface{} {
 
 	body["id"] = t.id
 
-	if t.params.Name != nil {
+	if t.params.Name != nil && *t.params.Name != "" {
 		body["name"] = *t.params.Name
 	}
 
@@ -104,31 +204,31 @@ func (t *Trace) toBody() map[string]interface{} {
 		body["output"] = t.params.Output
 	}
 
-	if t.params.Metadata != nil {
+	if len(t.params.Metadata) > 0 {
 		body["metadata"] = t.params.Metadata
 	}
 
-	if t.params.UserID != nil {
+	if t.params.UserID != nil && *t.params.UserID != "" {
 		body["userId"] = *t.params.UserID
 	}
 
-	if t.params.SessionID != nil {
+	if t.params.SessionID != nil && *t.params.SessionID != "" {
 		body["sessionId"] = *t.params.SessionID
 	}
 
-	if t.params.Environment != nil {
+	if t.params.Environment != nil && *t.params.Environment != "" {
 		body["environment"] = *t.params.Environment
 	}
 
-	if t.params.Version != nil {
+	if t.params.Version != nil && *t.params.Version != "" {
 		body["version"] = *t.params.Version
 	}
 
-	if t.params.Release != nil {
+	if t.params.Release != nil && *t.params.Release != "" {
 		body["release"] = *t.params.Release
 	}
 
-	if t.params.Tags != nil && len(t.params.Tags) > 0 {
+	if len(t.params.Tags) > 0 {
 		body["tags"] = t.params.Tags
 	}
 
@@ -144,10 +244,86 @@ func (t *Trace) ID() string {
 	return t.id
 }
 
-// Update updates the trace with new parameters
+// Update updates the trace with new parameters.
+// Updates are sent as trace-create events, which the server upserts by trace
+// ID, so only the fields set in params are sent, plus the trace ID; metadata
+// is sent merged with the trace's earlier metadata.
+// Nil and empty fields of params are ignored; list fields in params.Clear to erase them.
 func (t *Trace) Update(params TraceParams) error {
-	// Merge params
-	if params.Name != nil {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.clearLocked(params.Clear); err != nil {
+		return err
+	}
+	t.mergeLocked(params)
+	return t.sendLocked(updatedFields(params), params.Clear)
+}
+
+// updatedFields returns the body fields that params sets when merged into a
+// trace. Metadata and, with Config.MergeTraceTags, tags are sent merged.
+func updatedFields(params TraceParams) []string {
+	var fields []string
+	if params.Name != nil && *params.Name != "" {
+		fields = append(fields, "name")
+	}
+	if params.Input != nil {
+		fields = append(fields, "input")
+	}
+	if params.Output != nil {
+		fields = append(fields, "output")
+	}
+	if len(params.Metadata) > 0 {
+		fields = append(fields, "metadata")
+	}
+	if params.UserID != nil && *params.UserID != "" {
+		fields = append(fields, "userId")
+	}
+	if params.SessionID != nil && *params.SessionID != "" {
+		fields = append(fields, "sessionId")
+	}
+	if len(params.Tags) > 0 {
+		fields = append(fields, "tags")
+	}
+	if params.Public != nil {
+		fields = append(fields, "public")
+	}
+	return fields
+}
+
+// clearLocked unsets the given fields of the trace, or returns an error for an
+// unknown field without changing anything; t.mu must be held
+func (t *Trace) clearLocked(fields []string) error {
+	cleared := t.params
+	for _, field := range fields {
+		switch field {
+		case "name":
+			cleared.Name = nil
+		case "input":
+			cleared.Input = nil
+		case "output":
+			cleared.Output = nil
+		case "metadata":
+			cleared.Metadata = nil
+		case "userId":
+			cleared.UserID = nil
+		case "sessionId":
+			cleared.SessionID = nil
+		case "tags":
+			cleared.Tags = nil
+		case "public":
+			cleared.Public = nil
+		default:
+			return fmt.Errorf("unknown trace field %q", field)
+		}
+	}
+	t.params = cleared
+	return nil
+}
+
+// mergeLocked merges the non-empty fields of params into the trace; t.mu must be held
+func (t *Trace) mergeLocked(params TraceParams) {
+	if params.Name != nil && *params.Name != "" {
 		t.params.Name = params.Name
 	}
 	if params.Input != nil {
@@ -156,34 +332,351 @@ func (t *Trace) Update(params TraceParams) error {
 	if params.Output != nil {
 		t.params.Output = params.Output
 	}
-	if params.Metadata != nil {
-		if t.params.Metadata == nil {
-			t.params.Metadata = make(map[string]interface{})
+	if len(params.Metadata) > 0 {
+		// Merge into a new map so the caller's original map is never mutated
+		merged := make(map[string]interface{}, len(t.params.Metadata)+len(params.Metadata))
+		for k, v := range t.params.Metadata {
+			merged[k] = v
 		}
 		for k, v := range params.Metadata {
-			t.params.Metadata[k] = v
+			merged[k] = v
 		}
+		t.params.Metadata = merged
 	}
-	if params.UserID != nil {
+	if params.UserID != nil && *params.UserID != "" {
 		t.params.UserID = params.UserID
 	}
-	if params.SessionID != nil {
+	if params.SessionID != nil && *params.SessionID != "" {
 		t.params.SessionID = params.SessionID
 	}
-	if params.Tags != nil {
-		t.params.Tags = params.Tags
+	if len(params.Tags) > 0 {
+		if t.client.config.MergeTraceTags {
+			t.params.Tags = mergeTags(t.params.Tags, params.Tags)
+		} else {
+			t.params.Tags = params.Tags
+		}
 	}
 	if params.Public != nil {
 		t.params.Public = params.Public
 	}
+}
+
+// AddTags adds tags to the trace, keeping its existing tags and skipping
+// duplicates, and sends the update. Unlike Update, which replaces the tags
+// unless Config.MergeTraceTags is set, it is safe to call from several
+// components of the same trace. Nothing is sent if all tags are already set.
+func (t *Trace) AddTags(tags ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	merged := mergeTags(t.params.Tags, tags)
+	if len(merged) == len(t.params.Tags) {
+		return nil
+	}
+	t.params.Tags = merged
+	return t.sendLocked([]string{"tags"}, nil)
+}
+
+// mergeTags returns existing followed by the tags of added not already in
+// it, without modifying either. Empty tags are skipped.
+func mergeTags(existing, added []string) []string {
+	merged := make([]string, 0, len(existing)+len(added))
+	seen := make(map[string]bool, len(existing)+len(added))
+	for _, tags := range [][]string{existing, added} {
+		for _, tag := range tags {
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// UpdatePartial updates only the listed fields of the trace with the values
+// in params. Fields are named as in the API body: "name", "input", "output",
+// "metadata", "userId", "sessionId", "tags" and "public". Values in params for
+// unlisted fields are ignored; an unknown field name is an error and nothing
+// is sent.
+//
+// Deprecated: use Update, which already sends only the fields set in params.
+func (t *Trace) UpdatePartial(fields []string, params TraceParams) error {
+	t.client.warnDeprecated("Trace.UpdatePartial", "Trace.Update")
+	if len(fields) == 0 {
+		return fmt.Errorf("no trace fields to update")
+	}
+
+	var selected TraceParams
+	for _, field := range fields {
+		switch field {
+		case "name":
+			selected.Name = params.Name
+		case "input":
+			selected.Input = params.Input
+		case "output":
+			selected.Output = params.Output
+		case "metadata":
+			selected.Metadata = params.Metadata
+		case "userId":
+			selected.UserID = params.UserID
+		case "sessionId":
+			selected.SessionID = params.SessionID
+		case "tags":
+			selected.Tags = params.Tags
+		case "public":
+			selected.Public = params.Public
+		default:
+			return fmt.Errorf("unknown trace field %q", field)
+		}
+	}
+	return t.Update(selected)
+}
+
+// MetadataKeyDurationMs is the trace metadata key set by Trace.End with WithDuration
+const MetadataKeyDurationMs = "duration_ms"
+
+// ErrTraceEnded is returned by Trace.End when the trace was already ended.
+//
+// Deprecated: use ErrAlreadyEnded, which it is equal to.
+var ErrTraceEnded = ErrAlreadyEnded
+
+// TraceEndOption configures Trace.End
+type TraceEndOption func(*traceEnd)
+
+type traceEnd struct {
+	duration bool
+}
+
+// WithDuration makes Trace.End record the time since the trace's Timestamp,
+// in milliseconds, under the MetadataKeyDurationMs metadata key
+func WithDuration() TraceEndOption {
+	return func(e *traceEnd) {
+		e.duration = true
+	}
+}
+
+// End finishes the trace: it sets the output and sends the trace.
+// Like the End methods of observation handles, only the first End that
+// succeeds sends anything; later calls return ErrAlreadyEnded. If sending
+// fails, End can be called again. Update can still be used after End.
+func (t *Trace) End(output interface{}, opts ...TraceEndOption) error {
+	var end traceEnd
+	for _, opt := range opts {
+		opt(&end)
+	}
+
+	t.mu.Lock()
+	if t.ended {
+		t.mu.Unlock()
+		return ErrAlreadyEnded
+	}
+	t.ended = true
+	t.mu.Unlock()
+
+	params := TraceParams{Output: output}
+	if end.duration {
+		params.Metadata = map[string]interface{}{
+			MetadataKeyDurationMs: time.Since(t.startedAt).Milliseconds(),
+		}
+	}
+	if err := t.Update(params); err != nil {
+		t.mu.Lock()
+		t.ended = false
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RedactedValue replaces the values of fields removed by Trace.Redact
+const RedactedValue = "[REDACTED]"
+
+// Redact replaces the given top-level keys of the trace's Input, Output and
+// Metadata with RedactedValue and sends the redacted trace.
+// Input and Output values that are not JSON objects are left unchanged.
+// This only overwrites the fields on the server with the next upsert; it does
+// not remove data the server has already received from its history.
+func (t *Trace) Redact(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	redact := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		redact[k] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.params.Input = redactFields(t.params.Input, redact)
+	t.params.Output = redactFields(t.params.Output, redact)
+	if t.params.Metadata != nil {
+		t.params.Metadata = redactMap(t.params.Metadata, redact)
+	}
+
+	return t.sendLocked([]string{"input", "output", "metadata"}, nil)
+}
+
+// redactFields redacts the top-level keys of a value that encodes to a JSON object
+func redactFields(v interface{}, keys map[string]bool) interface{} {
+	if v == nil {
+		return nil
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return redactMap(m, keys)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil || m == nil {
+		return v
+	}
+	return redactMap(m, keys)
+}
+
+// redactMap returns a copy of m with the given keys redacted
+func redactMap(m map[string]interface{}, keys map[string]bool) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if keys[k] {
+			v = RedactedValue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// sendLocked sends the current values of the given body fields plus the
+// trace ID, with the cleared fields as null; t.mu must be held. The server
+// upserts traces by ID, so the fields that are left out keep their values.
+// Nothing is sent if there is neither a field nor a cleared field.
+func (t *Trace) sendLocked(fields, cleared []string) error {
+	if t.pending {
+		// The server has not seen the trace yet, so send all of it
+		return t.sendCreateLocked(t.client.enqueue, cleared)
+	}
+
+	current := t.toBody()
+	applyClear(current, cleared)
+	full := snapshotBody(current)
+
+	body := map[string]interface{}{"id": t.id}
+	for _, field := range fields {
+		if v, ok := current[field]; ok {
+			body[field] = v
+		}
+	}
+	applyClear(body, cleared)
+	if len(body) == 1 {
+		return nil // only the ID, nothing to update
+	}
+
+	if t.client.config.DiffTraceUpdates {
+		body = diffBody(t.lastSent, body)
+		if len(body) == 1 {
+			return nil // only the ID is left, nothing changed
+		}
+	}
 
-	// Send updated trace event
 	event := Event{
-		ID:        generateID(),
+		ID:        t.client.generateID(),
 		Type:      EventTypeTraceCreate,
 		Timestamp: time.Now(),
-		Body:      t.toBody(),
+		Body:      body,
+	}
+
+	if err := t.client.enqueue(event); err != nil {
+		return err
+	}
+
+	t.lastSent = full
+	return nil
+}
+
+// snapshotBody copies a trace body so later merges don't alter it
+func snapshotBody(body map[string]interface{}) map[string]interface{} {
+	snapshot := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		if m, ok := v.(map[string]interface{}); ok {
+			clone := make(map[string]interface{}, len(m))
+			for mk, mv := range m {
+				clone[mk] = mv
+			}
+			v = clone
+		}
+		snapshot[k] = v
+	}
+	return snapshot
+}
+
+// diffBody returns the ID plus every field of body that differs from prev
+func diffBody(prev, body map[string]interface{}) map[string]interface{} {
+	diff := map[string]interface{}{"id": body["id"]}
+	for k, v := range body {
+		if old, ok := prev[k]; !ok || !reflect.DeepEqual(old, v) {
+			diff[k] = v
+		}
 	}
+	return diff
+}
+
+// LastObservationID returns the ID of the most recently created observation
+// on this trace, or an empty string if none has been created yet.
+// It is useful for chaining ParentObservationID in sequential pipelines.
+func (t *Trace) LastObservationID() string {
+	if last := t.LastObservation(); last != nil {
+		return last.ID
+	}
+	return ""
+}
+
+// LastObservation returns a copy of the most recently created observation
+// record on this trace, or nil if none has been created yet
+func (t *Trace) LastObservation() *ObservationRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.observations) == 0 {
+		return nil
+	}
+	last := t.observations[len(t.observations)-1]
+	return &last
+}
+
+// Observations returns a copy of the local observation log in creation order
+func (t *Trace) Observations() []ObservationRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records := make([]ObservationRecord, len(t.observations))
+	copy(records, t.observations)
+	return records
+}
+
+// logObservation appends a successfully created observation to the local log
+func (t *Trace) logObservation(id string, err error, obsType ObservationType, params ObservationParams) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	record := ObservationRecord{
+		ID:                  id,
+		Type:                string(obsType),
+		ParentObservationID: params.ParentObservationID,
+		CreatedAt:           time.Now(),
+	}
+	if params.Name != nil {
+		record.Name = *params.Name
+	}
+
+	t.mu.Lock()
+	t.observations = append(t.observations, record)
+	t.mu.Unlock()
 
-	return t.client.enqueue(event)
+	return id, nil
 }
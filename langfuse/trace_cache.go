@@ -0,0 +1,109 @@
+package langfuse
+
+import (
+	"container/list"
+	"sync"
+)
+
+// traceCache keeps the most recently used traces created by the client, so
+// they can be looked up by ID later, e.g. in another request handler. A
+// maxSize of 0 or less disables it.
+type traceCache struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[string]*list.Element // values are *traceState
+	lru     *list.List               // front is most recently used
+}
+
+func newTraceCache(maxSize int) *traceCache {
+	return &traceCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// add caches a trace, evicting the least recently used one if the cache is full
+func (c *traceCache) add(trace *traceState) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[trace.id]; ok {
+		elem.Value = trace
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[trace.id] = c.lru.PushFront(trace)
+	for c.lru.Len() > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*traceState).id)
+	}
+}
+
+// get returns a cached trace and marks it as recently used
+func (c *traceCache) get(id string) (*traceState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*traceState), true
+}
+
+// remove removes a trace from the cache
+func (c *traceCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// clear removes all cached traces and returns how many were removed
+func (c *traceCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.lru.Len()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return n
+}
+
+func (c *traceCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// CachedTrace returns a trace created by this client by its ID, if it is still
+// in the trace cache (see Config.TraceCacheMaxSize, which is 0 by default).
+// The returned Trace shares its state with the one CreateTrace returned.
+func (c *Client) CachedTrace(id string) (*Trace, bool) {
+	state, ok := c.traces.get(id)
+	if !ok {
+		return nil, false
+	}
+	return &Trace{traceState: state, client: c}, true
+}
+
+// ClearTraceCache removes all traces from the trace cache to release their
+// memory and returns how many were removed. The traces stay usable.
+func (c *Client) ClearTraceCache() int {
+	return c.traces.clear()
+}
+
+// TraceCacheSize returns the number of traces in the trace cache
+func (c *Client) TraceCacheSize() int {
+	return c.traces.len()
+}
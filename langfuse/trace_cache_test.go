@@ -0,0 +1,94 @@
+package langfuse
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTraceCacheIsDisabledByDefault(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.CachedTrace(trace.ID()); ok {
+		t.Error("CachedTrace found a trace with TraceCacheMaxSize 0")
+	}
+	if n := client.TraceCacheSize(); n != 0 {
+		t.Errorf("TraceCacheSize = %d, want 0", n)
+	}
+}
+
+func TestTraceCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	client := newTestClient(t, newTestServer(t), func(c *Config) {
+		c.TraceCacheMaxSize = 2
+	})
+
+	ids := createTraces(t, client, 2)
+	client.CachedTrace(ids[0]) // now more recently used than ids[1]
+	third := createTraces(t, client, 1)[0]
+
+	for id, want := range map[string]bool{ids[0]: true, ids[1]: false, third: true} {
+		if _, ok := client.CachedTrace(id); ok != want {
+			t.Errorf("CachedTrace(%s) found = %v, want %v", id, ok, want)
+		}
+	}
+}
+
+func TestCachedTraceSharesState(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.TraceCacheMaxSize = 10
+	})
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := client.CachedTrace(trace.ID())
+	if !ok {
+		t.Fatal("trace is not cached")
+	}
+	if _, err := cached.CreateEvent(EventParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(trace.Observations()); n != 1 {
+		t.Errorf("trace records %d observations created through the cached trace, want 1", n)
+	}
+}
+
+func TestCachedTracesDoNotKeepClientAlive(t *testing.T) {
+	server := newTestServer(t)
+	config := DefaultConfig()
+	config.PublicKey = "pk-test"
+	config.SecretKey = "sk-test"
+	config.BaseURL = server.URL
+	config.TraceCacheMaxSize = 10
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTraces(t, client, 3)
+	core := client.clientCore
+	client = nil
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		core.mu.Lock()
+		closed := core.closed
+		core.mu.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client with cached traces was not finalized")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := core.traces.len(); n != 3 {
+		t.Errorf("cache holds %d traces, want 3", n)
+	}
+}
@@ -0,0 +1,66 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// deleteTracesRequest is the body of a bulk trace deletion
+type deleteTracesRequest struct {
+	TraceIDs []string `json:"traceIds"`
+}
+
+// DeleteTrace deletes a trace and its observations and scores from Langfuse,
+// and removes it from the trace cache. Events of the trace that are still
+// queued would recreate it when they are sent, so Flush first if the trace
+// was created by this client. Deletion happens asynchronously on the server,
+// so a trace may still be returned by GetTrace for a short while.
+func (c *Client) DeleteTrace(ctx context.Context, traceID string) error {
+	if !c.config.Enabled {
+		return fmt.Errorf("client is disabled")
+	}
+
+	if traceID == "" {
+		return fmt.Errorf("traceID is required")
+	}
+
+	fullURL := fmt.Sprintf("%s/api/public/traces/%s", c.config.BaseURL, url.PathEscape(c.serverTraceID(traceID)))
+	if _, err := c.doJSON(ctx, "DELETE", fullURL, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete trace: %w", err)
+	}
+
+	c.traces.remove(traceID)
+	return nil
+}
+
+// DeleteTraces deletes several traces with one request, like DeleteTrace,
+// e.g. to clean up the traces of a CI run
+func (c *Client) DeleteTraces(ctx context.Context, traceIDs []string) error {
+	if !c.config.Enabled {
+		return fmt.Errorf("client is disabled")
+	}
+
+	if len(traceIDs) == 0 {
+		return nil
+	}
+	for _, id := range traceIDs {
+		if id == "" {
+			return fmt.Errorf("trace IDs must not be empty")
+		}
+	}
+
+	serverIDs := make([]string, len(traceIDs))
+	for i, id := range traceIDs {
+		serverIDs[i] = c.serverTraceID(id)
+	}
+	fullURL := c.config.BaseURL + "/api/public/traces"
+	if _, err := c.doJSON(ctx, "DELETE", fullURL, deleteTracesRequest{TraceIDs: serverIDs}, nil); err != nil {
+		return fmt.Errorf("failed to delete traces: %w", err)
+	}
+
+	for _, id := range traceIDs {
+		c.traces.remove(id)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ScoreConfig defines the name, data type and allowed values of a score
+type ScoreConfig struct {
+	ID          string                   `json:"id"`
+	Name        string                   `json:"name"`
+	DataType    string                   `json:"dataType"`
+	IsArchived  bool                     `json:"isArchived"`
+	MinValue    *float64                 `json:"minValue,omitempty"`
+	MaxValue    *float64                 `json:"maxValue,omitempty"`
+	Categories  []map[string]interface{} `json:"categories,omitempty"`
+	Description *string                  `json:"description,omitempty"`
+}
+
+// ScoreDetails is a score together with its score config, if it has one
+type ScoreDetails struct {
+	ScoreData
+
+	// Config is the score config referenced by ConfigID
+	Config *ScoreConfig
+}
+
+// TraceFull is a trace with everything attached to it, as returned by GetTraceFull
+type TraceFull struct {
+	*TraceWithFullDetails
+
+	// ScoreDetails are the trace's scores with author, source and config
+	ScoreDetails []ScoreDetails
+
+	// Comments are the comments on the trace and on its observations
+	Comments []Comment
+
+	// Errors holds the failures of the additional requests. The corresponding
+	// data is missing or, for scores, limited to what GetTrace returned.
+	Errors []error
+}
+
+// GetScoreConfig retrieves a score config by ID
+func (c *Client) GetScoreConfig(ctx context.Context, configID string) (*ScoreConfig, error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("client is disabled")
+	}
+
+	if configID == "" {
+		return nil, fmt.Errorf("configID is required")
+	}
+
+	url := fmt.Sprintf("%s/api/public/score-configs/%s", c.config.BaseURL, configID)
+
+	config, err := c.fetchJSON(ctx, url, &ScoreConfig{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get score config: %w", err)
+	}
+
+	return config.(*ScoreConfig), nil
+}
+
+// GetTraceFull retrieves a trace together with its full score details and the
+// comments on the trace and its observations. The additional requests run
+// concurrently after the trace is fetched; their failures are collected in
+// TraceFull.Errors rather than failing the call. Only a failure to fetch the
+// trace itself returns an error.
+func (c *Client) GetTraceFull(ctx context.Context, traceID string) (*TraceFull, error) {
+	trace, err := c.GetTrace(ctx, GetTraceParams{TraceID: traceID})
+	if err != nil {
+		return nil, err
+	}
+
+	full := &TraceFull{TraceWithFullDetails: trace}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, 5)
+	)
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := fn(); err != nil {
+				mu.Lock()
+				full.Errors = append(full.Errors, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	scores := trace.Scores
+	run(func() error {
+		fetched, err := c.listTraceScores(ctx, traceID)
+		if err != nil {
+			return fmt.Errorf("failed to list trace scores: %w", err)
+		}
+		mu.Lock()
+		scores = fetched
+		mu.Unlock()
+		return nil
+	})
+
+	addComments := func(objectType, objectID string) {
+		run(func() error {
+			comments, err := c.listAllComments(ctx, objectType, objectID)
+			if err != nil {
+				return fmt.Errorf("failed to list comments of %s %s: %w", objectType, objectID, err)
+			}
+			mu.Lock()
+			full.Comments = append(full.Comments, comments...)
+			mu.Unlock()
+			return nil
+		})
+	}
+	addComments("TRACE", traceID)
+	for _, observation := range trace.Observations {
+		addComments("OBSERVATION", observation.ID)
+	}
+
+	wg.Wait()
+
+	// Score configs are fetched once the full scores are known
+	configs := make(map[string]*ScoreConfig)
+	for _, score := range scores {
+		if score.ConfigID != nil {
+			configs[*score.ConfigID] = nil
+		}
+	}
+	for configID := range configs {
+		run(func() error {
+			config, err := c.GetScoreConfig(ctx, configID)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			configs[configID] = config
+			mu.Unlock()
+			return nil
+		})
+	}
+	wg.Wait()
+
+	full.ScoreDetails = make([]ScoreDetails, len(scores))
+	for i, score := range scores {
+		full.ScoreDetails[i] = ScoreDetails{ScoreData: score}
+		if score.ConfigID != nil {
+			full.ScoreDetails[i].Config = configs[*score.ConfigID]
+		}
+	}
+
+	return full, nil
+}
+
+// listAllComments retrieves all comments on an object, following pagination
+func (c *Client) listAllComments(ctx context.Context, objectType, objectID string) ([]Comment, error) {
+	var comments []Comment
+	for page := 1; ; page++ {
+		paginated, err := c.ListComments(ctx, ListCommentsParams{
+			Page:       &page,
+			Limit:      ptr(100),
+			ObjectType: &objectType,
+			ObjectID:   &objectID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, paginated.Data...)
+		if page >= paginated.Meta.TotalPages || len(paginated.Data) == 0 {
+			return comments, nil
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package langfuse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// traceUpdates returns the bodies of the trace events sent after the create
+func traceUpdates(t *testing.T, server *testServer) []map[string]interface{} {
+	t.Helper()
+	events := server.eventsOfType(EventTypeTraceCreate)
+	if len(events) == 0 {
+		t.Fatal("the trace was not sent")
+	}
+	var bodies []map[string]interface{}
+	for _, e := range events[1:] {
+		bodies = append(bodies, e.Body)
+	}
+	return bodies
+}
+
+func TestUpdateSendsOnlyTheFieldsItSets(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	input := strings.Repeat("x", 100000)
+	trace, err := client.CreateTrace(TraceParams{Name: Ptr("chat"), Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Update(TraceParams{Metadata: map[string]interface{}{"step": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) != 1 {
+		t.Fatalf("got %d trace updates, want 1", len(updates))
+	}
+	body := updates[0]
+	if body["id"] != trace.ID() {
+		t.Errorf("update id = %v, want %s", body["id"], trace.ID())
+	}
+	if _, ok := body["metadata"]; !ok {
+		t.Error("update did not send the metadata it set")
+	}
+	for _, key := range []string{"input", "name"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("update resent %s", key)
+		}
+	}
+	if len(body) != 2 {
+		t.Errorf("update body = %v, want only id and metadata", body)
+	}
+}
+
+func TestUpdatePartialSendsWhatUpdateSends(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{Name: Ptr("chat")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := TraceParams{Output: "answer", UserID: Ptr("u1")}
+	if err := trace.UpdatePartial([]string{"output"}, params); err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Update(TraceParams{Output: "answer"}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) != 2 {
+		t.Fatalf("got %d trace updates, want 2", len(updates))
+	}
+	partial, update := updates[0], updates[1]
+	if len(partial) != len(update) || partial["output"] != update["output"] {
+		t.Errorf("UpdatePartial sent %v, Update %v", partial, update)
+	}
+}
+
+func TestEndSendsExactlyOnce(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span, err := trace.StartSpan(NewSpan("step"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := map[string][]error{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := trace.End("done")
+			mu.Lock()
+			errs["Trace.End"] = append(errs["Trace.End"], err)
+			mu.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			err := span.End()
+			mu.Lock()
+			errs["SpanHandle.End"] = append(errs["SpanHandle.End"], err)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	flush(t, client)
+
+	for name, results := range errs {
+		succeeded := 0
+		for _, err := range results {
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrAlreadyEnded):
+				t.Errorf("%s = %v, want nil or ErrAlreadyEnded", name, err)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("%s succeeded %d times, want exactly once", name, succeeded)
+		}
+	}
+	if n := len(traceUpdates(t, server)); n != 1 {
+		t.Errorf("got %d trace updates, want 1", n)
+	}
+	if n := len(server.eventsOfType(EventTypeSpanUpdate)); n != 1 {
+		t.Errorf("got %d span updates, want 1", n)
+	}
+}
+
+func TestFailedEndCanBeRetried(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.MaxQueueSize = 2
+	})
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span, err := trace.StartSpan(NewSpan("step")) // fills the queue
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := span.End(); err == nil {
+		t.Fatal("End on a full queue succeeded")
+	}
+	if err := trace.End("done"); err == nil {
+		t.Fatal("Trace.End on a full queue succeeded")
+	}
+
+	flush(t, client)
+	if err := span.End(); err != nil {
+		t.Errorf("End after a failed End = %v", err)
+	}
+	if err := trace.End("done"); err != nil {
+		t.Errorf("Trace.End after a failed End = %v", err)
+	}
+}
+
+func TestDiffTraceUpdatesLeavesOutUnchangedFields(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.DiffTraceUpdates = true
+	})
+
+	trace, err := client.CreateTrace(TraceParams{Name: Ptr("chat"), UserID: Ptr("u1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Update(TraceParams{Name: Ptr("chat"), Output: "answer"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Update(TraceParams{Output: "answer"}); err != nil {
+		t.Fatal(err)
+	}
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) != 1 {
+		t.Fatalf("got %d trace updates, want 1 (the unchanged update is not sent)", len(updates))
+	}
+	body := updates[0]
+	if body["output"] != "answer" {
+		t.Errorf("update output = %v, want answer", body["output"])
+	}
+	for _, key := range []string{"name", "userId"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("update sent the unchanged %s", key)
+		}
+	}
+}
+
+func TestUpdatePartialSendsOnlyTheRequestedFields(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := TraceParams{
+		Output:   "answer",
+		UserID:   Ptr("u1"),
+		Metadata: map[string]interface{}{"step": 2},
+	}
+	if err := trace.UpdatePartial([]string{"output", "userId"}, params); err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.UpdatePartial([]string{"output", "bogus"}, params); err == nil {
+		t.Error("UpdatePartial accepted an unknown field")
+	}
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) != 1 {
+		t.Fatalf("got %d trace updates, want 1", len(updates))
+	}
+	body := updates[0]
+	want := map[string]interface{}{"id": trace.ID(), "output": "answer", "userId": "u1"}
+	if len(body) != len(want) {
+		t.Errorf("update body = %v, want %v", body, want)
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("update %s = %v, want %v", k, body[k], v)
+		}
+	}
+}
+
+func TestAddTagsIsSafeConcurrently(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	trace, err := client.CreateTrace(TraceParams{Tags: []string{"base"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := trace.AddTags(fmt.Sprintf("tag-%d", i), "shared"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	flush(t, client)
+
+	updates := traceUpdates(t, server)
+	if len(updates) == 0 {
+		t.Fatal("AddTags sent nothing")
+	}
+	last, _ := updates[len(updates)-1]["tags"].([]interface{})
+	tags := map[interface{}]int{}
+	for _, tag := range last {
+		tags[tag]++
+	}
+	if len(last) != 22 || len(tags) != 22 {
+		t.Errorf("final tags = %v, want base, shared and the 20 added tags once each", last)
+	}
+	for _, tag := range []string{"base", "shared", "tag-0", "tag-19"} {
+		if tags[tag] != 1 {
+			t.Errorf("tag %s appears %d times, want 1", tag, tags[tag])
+		}
+	}
+}
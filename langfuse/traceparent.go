@@ -0,0 +1,102 @@
+package langfuse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trace IDs can be shared with W3C Trace Context (https://www.w3.org/TR/trace-context/):
+// a trace created with the 32 hex character trace ID of a traceparent header,
+// which is also the form Langfuse's OpenTelemetry ingestion uses, is stored
+// under that ID, so it lines up with the distributed trace it belongs to.
+
+// traceparentVersion is the W3C Trace Context version written by TraceparentFromTrace
+const traceparentVersion = "00"
+
+// TraceIDFromTraceparent returns the trace ID of a W3C traceparent header,
+// such as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", for use
+// as TraceParams.ID. It returns an error if the header is malformed.
+func TraceIDFromTraceparent(header string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", fmt.Errorf("invalid traceparent %q: expected version-traceid-parentid-flags", header)
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" {
+		return "", fmt.Errorf("invalid traceparent %q: invalid version %q", header, version)
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if version == traceparentVersion && len(parts) != 4 {
+		return "", fmt.Errorf("invalid traceparent %q: expected version-traceid-parentid-flags", header)
+	}
+	if err := validateHexTraceID(traceID); err != nil {
+		return "", fmt.Errorf("invalid traceparent %q: %w", header, err)
+	}
+	if !isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) {
+		return "", fmt.Errorf("invalid traceparent %q: parent ID must be 16 lowercase hex characters and not all zero", header)
+	}
+	if !isLowerHex(flags, 2) {
+		return "", fmt.Errorf("invalid traceparent %q: invalid flags %q", header, flags)
+	}
+	return traceID, nil
+}
+
+// TraceparentFromTrace returns a W3C traceparent header that continues trace,
+// for passing to downstream services. Its trace ID is OTLPTraceID(trace.ID()),
+// which is the trace's own ID if it is a 32 hex character trace ID, and its
+// parent ID is the span Langfuse's OpenTelemetry ingestion stores the trace
+// under. The sampled flag is set if the trace is sampled (see Trace.Sampled).
+func TraceparentFromTrace(trace *Trace) string {
+	id := trace.ID()
+	flags := "00"
+	if trace.Sampled() {
+		flags = "01"
+	}
+	return strings.Join([]string{
+		traceparentVersion,
+		OTLPTraceID(id),
+		OTLPSpanID("trace:" + id),
+		flags,
+	}, "-")
+}
+
+// validateTraceID checks a trace ID passed in TraceParams.ID. Any non-empty
+// ID is accepted, but one of 32 hex characters must also be a valid W3C
+// trace ID, since Langfuse's OpenTelemetry ingestion stores traces under
+// that form.
+func validateTraceID(id string) error {
+	if id == "" {
+		return fmt.Errorf("trace ID must not be empty")
+	}
+	if len(id) == 32 && isHex(id) {
+		return validateHexTraceID(id)
+	}
+	return nil
+}
+
+// validateHexTraceID checks that id is a valid W3C trace ID
+func validateHexTraceID(id string) error {
+	if !isLowerHex(id, 32) {
+		return fmt.Errorf("trace ID %q must be 32 lowercase hex characters", id)
+	}
+	if id == strings.Repeat("0", 32) {
+		return fmt.Errorf("trace ID %q must not be all zero", id)
+	}
+	return nil
+}
+
+// isLowerHex reports whether s consists of n lowercase hex characters
+func isLowerHex(s string, n int) bool {
+	return len(s) == n && isHex(s) && strings.ToLower(s) == s
+}
+
+// isHex reports whether s consists of hex characters of either case
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
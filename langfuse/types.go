@@ -23,6 +23,22 @@ const (
 	EventTypeSdkLog           EventType = "sdk-log"
 )
 
+// ObservationType is the type of an observation as reported by the API
+type ObservationType string
+
+const (
+	ObservationTypeSpan       ObservationType = "SPAN"
+	ObservationTypeGeneration ObservationType = "GENERATION"
+	ObservationTypeEvent      ObservationType = "EVENT"
+	ObservationTypeAgent      ObservationType = "AGENT"
+	ObservationTypeTool       ObservationType = "TOOL"
+	ObservationTypeChain      ObservationType = "CHAIN"
+	ObservationTypeRetriever  ObservationType = "RETRIEVER"
+	ObservationTypeEvaluator  ObservationType = "EVALUATOR"
+	ObservationTypeEmbedding  ObservationType = "EMBEDDING"
+	ObservationTypeGuardrail  ObservationType = "GUARDRAIL"
+)
+
 // ObservationLevel represents the severity level of an observation
 type ObservationLevel string
 
@@ -40,6 +56,9 @@ type Event struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Body      map[string]interface{} `json:"body"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+
+	// upsert marks a create event re-emitted to update an existing observation
+	upsert bool
 }
 
 // IngestionRequest represents the batch ingestion request
@@ -71,11 +90,89 @@ type ErrorResult struct {
 
 // Usage represents token usage information
 type Usage struct {
-	Input      *int    `json:"input,omitempty"`
-	Output     *int    `json:"output,omitempty"`
-	Total      *int    `json:"total,omitempty"`
-	Unit       *string `json:"unit,omitempty"`
+	Input      *int     `json:"input,omitempty"`
+	Output     *int     `json:"output,omitempty"`
+	Total      *int     `json:"total,omitempty"`
+	Unit       *string  `json:"unit,omitempty"`
 	InputCost  *float64 `json:"inputCost,omitempty"`
 	OutputCost *float64 `json:"outputCost,omitempty"`
 	TotalCost  *float64 `json:"totalCost,omitempty"`
+
+	// Currency is the ISO code of the cost currency (default: USD).
+	// It is sent in the generation metadata under MetadataKeyCostCurrency.
+	Currency *string `json:"-"`
+}
+
+// AudioUsage represents the audio token usage of audio models, reported by
+// OpenAI as audio_tokens in the usage details
+type AudioUsage struct {
+	InputAudioTokens  *int     `json:"inputAudioTokens,omitempty"`
+	OutputAudioTokens *int     `json:"outputAudioTokens,omitempty"`
+	InputAudioCost    *float64 `json:"inputAudioCost,omitempty"`
+	OutputAudioCost   *float64 `json:"outputAudioCost,omitempty"`
+}
+
+// WithAudioUsage returns a copy of the usage with the audio token counts and
+// costs added to its input, output and totals. Totals that are not set stay
+// unset, so the server can still compute them. A nil usage is treated as empty.
+func (u *Usage) WithAudioUsage(au AudioUsage) *Usage {
+	var merged Usage
+	if u != nil {
+		addUsage(&merged, *u)
+		merged.Currency = u.Currency
+	}
+
+	delta := Usage{
+		Input:      au.InputAudioTokens,
+		Output:     au.OutputAudioTokens,
+		InputCost:  au.InputAudioCost,
+		OutputCost: au.OutputAudioCost,
+	}
+	if merged.Total != nil {
+		delta.Total = ptr(derefInt(au.InputAudioTokens) + derefInt(au.OutputAudioTokens))
+	}
+	if merged.TotalCost != nil {
+		delta.TotalCost = ptr(derefFloat(au.InputAudioCost) + derefFloat(au.OutputAudioCost))
+	}
+	addUsage(&merged, delta)
+
+	return &merged
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func derefFloat(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// UsageFromOpenAI builds a Usage from OpenAI-style token counts
+func UsageFromOpenAI(promptTokens, completionTokens, totalTokens int) Usage {
+	return Usage{
+		Input:  &promptTokens,
+		Output: &completionTokens,
+		Total:  &totalTokens,
+	}
+}
+
+// PromptTokens returns the input token count (OpenAI naming for Input)
+func (u Usage) PromptTokens() *int {
+	return u.Input
+}
+
+// CompletionTokens returns the output token count (OpenAI naming for Output)
+func (u Usage) CompletionTokens() *int {
+	return u.Output
+}
+
+// TotalTokens returns the total token count (OpenAI naming for Total)
+func (u Usage) TotalTokens() *int {
+	return u.Total
 }
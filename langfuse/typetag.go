@@ -0,0 +1,78 @@
+package langfuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// TypeTagKey is the key Config.TagStructTypes records the Go type of a struct
+// input or output under
+const TypeTagKey = "_type"
+
+// tagBodyTypes returns a copy of body with struct inputs and outputs replaced
+// by JSON objects carrying their Go type under TypeTagKey. Bodies without
+// struct values are returned as is.
+func tagBodyTypes(body map[string]interface{}) map[string]interface{} {
+	var tagged map[string]interface{}
+	for _, field := range maskedFields {
+		value, ok := tagType(body[field])
+		if !ok {
+			continue
+		}
+		if tagged == nil {
+			tagged = make(map[string]interface{}, len(body))
+			for k, v := range body {
+				tagged[k] = v
+			}
+		}
+		tagged[field] = value
+	}
+	if tagged == nil {
+		return body
+	}
+	return tagged
+}
+
+// tagType converts a value of a named struct type, or a pointer to one, to a
+// JSON object with its type name under TypeTagKey, e.g. "tools.SearchArgs".
+// It reports false for any other value, for ClearField, for structs with
+// their own JSON encoding such as time.Time, and for structs that cannot be
+// encoded, which are left to fail when sent.
+func tagType(value interface{}) (interface{}, bool) {
+	if value == ClearField {
+		return nil, false
+	}
+	if _, ok := value.(json.Marshaler); ok {
+		return nil, false
+	}
+
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return nil, false
+	}
+	if t.Kind() == reflect.Pointer {
+		if reflect.ValueOf(value).IsNil() {
+			return nil, false
+		}
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return nil, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	// Decode numbers as json.Number so large integers keep their precision
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, false
+	}
+	object[TypeTagKey] = t.String()
+	return object, true
+}
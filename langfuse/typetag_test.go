@@ -0,0 +1,49 @@
+package langfuse
+
+import "testing"
+
+type searchArgs struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+func TestTagStructTypes(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		server := newTestServer(t)
+		client := newTestClient(t, server, func(c *Config) {
+			c.TagStructTypes = enabled
+		})
+		trace, err := client.CreateTrace(TraceParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = trace.StartTool(NewTool("search",
+			WithInput(&searchArgs{Query: "go", Limit: 3}),
+			WithOutput(map[string]interface{}{"hits": 1}),
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		flush(t, client)
+
+		tools := server.eventsOfType(EventTypeToolCreate)
+		if len(tools) != 1 {
+			t.Fatalf("got %d tools, want 1", len(tools))
+		}
+		input, _ := tools[0].Body["input"].(map[string]interface{})
+		output, _ := tools[0].Body["output"].(map[string]interface{})
+		if input["query"] != "go" {
+			t.Errorf("TagStructTypes %v: input = %v, want the struct's fields", enabled, input)
+		}
+		want := interface{}(nil)
+		if enabled {
+			want = "langfuse.searchArgs"
+		}
+		if input[TypeTagKey] != want {
+			t.Errorf("TagStructTypes %v: input %s = %v, want %v", enabled, TypeTagKey, input[TypeTagKey], want)
+		}
+		if _, ok := output[TypeTagKey]; ok {
+			t.Errorf("TagStructTypes %v: a map output was tagged", enabled)
+		}
+	}
+}
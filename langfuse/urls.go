@@ -0,0 +1,135 @@
+package langfuse
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// URLFilter narrows the traces list opened by TracesURL
+type URLFilter func(query url.Values)
+
+// FilterNamePrefix shows only traces whose name starts with prefix
+func FilterNamePrefix(prefix string) URLFilter {
+	return func(query url.Values) {
+		query.Set("namePrefix", prefix)
+	}
+}
+
+// FilterUserID shows only traces of the given user
+func FilterUserID(userID string) URLFilter {
+	return func(query url.Values) {
+		query.Set("userId", userID)
+	}
+}
+
+// FilterTag shows only traces with the given tag. It can be passed several times.
+func FilterTag(tag string) URLFilter {
+	return func(query url.Values) {
+		query.Add("tags", tag)
+	}
+}
+
+// FilterDateRange shows only traces between from and to. A zero time leaves
+// that side of the range open.
+func FilterDateRange(from, to time.Time) URLFilter {
+	return func(query url.Values) {
+		if !from.IsZero() {
+			query.Set("fromTimestamp", from.UTC().Format(time.RFC3339))
+		}
+		if !to.IsZero() {
+			query.Set("toTimestamp", to.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+// DashboardURL returns the URL of the Langfuse dashboard
+func (c *Client) DashboardURL() string {
+	return c.uiURL("/dashboard")
+}
+
+// GetDashboardURL returns the URL of the Langfuse dashboard.
+//
+// Deprecated: use DashboardURL.
+func (c *Client) GetDashboardURL() string {
+	c.warnDeprecated("Client.GetDashboardURL", "Client.DashboardURL")
+	return c.DashboardURL()
+}
+
+// TracesURL returns the URL of the traces list, pre-filtered by filters.
+// Useful for linking from error notifications.
+func (c *Client) TracesURL(filters ...URLFilter) string {
+	query := url.Values{}
+	for _, filter := range filters {
+		filter(query)
+	}
+
+	tracesURL := c.uiURL("/traces")
+	if len(query) > 0 {
+		tracesURL += "?" + query.Encode()
+	}
+	return tracesURL
+}
+
+// GetTracesURL returns the URL of the traces list, pre-filtered by filters.
+//
+// Deprecated: use TracesURL.
+func (c *Client) GetTracesURL(filters ...URLFilter) string {
+	c.warnDeprecated("Client.GetTracesURL", "Client.TracesURL")
+	return c.TracesURL(filters...)
+}
+
+// TraceURL returns the URL of a trace in the Langfuse UI, e.g. to print a
+// link after creating it, or "" without a Config.BaseURL. With
+// Config.ProjectID it links to the trace page directly; otherwise it links to
+// a page that redirects to the trace's project once signed in.
+func (c *Client) TraceURL(traceID string) string {
+	traceID = c.serverTraceID(traceID)
+	if c.config.ProjectID == "" {
+		base := c.baseUIURL()
+		if base == "" {
+			return ""
+		}
+		return base + "/trace/" + url.PathEscape(traceID)
+	}
+	return c.uiURL("/traces/" + url.PathEscape(traceID))
+}
+
+// SessionURL returns the URL of a session in the Langfuse UI, which lists
+// the traces created with its SessionID
+func (c *Client) SessionURL(sessionID string) string {
+	return c.uiURL("/sessions/" + url.PathEscape(sessionID))
+}
+
+// URL returns the URL of the trace in the Langfuse UI, see Client.TraceURL
+func (t *Trace) URL() string {
+	return t.client.TraceURL(t.id)
+}
+
+// GetURL returns the URL of the trace in the Langfuse UI.
+//
+// Deprecated: use URL.
+func (t *Trace) GetURL() string {
+	t.client.warnDeprecated("Trace.GetURL", "Trace.URL")
+	return t.URL()
+}
+
+// uiURL joins a Langfuse UI path to the configured base URL, within the
+// Config.ProjectID project if it is set. Without a base URL there is no
+// link, and it returns "".
+func (c *Client) uiURL(path string) string {
+	base := c.baseUIURL()
+	if base == "" {
+		return ""
+	}
+	if c.config.ProjectID != "" {
+		path = "/project/" + url.PathEscape(c.config.ProjectID) + path
+	}
+	return base + path
+}
+
+// baseUIURL returns the configured base URL without a trailing slash or
+// "/api" suffix
+func (c *Client) baseUIURL() string {
+	return strings.TrimSuffix(strings.TrimRight(c.config.BaseURL, "/"), "/api")
+}
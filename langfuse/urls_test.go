@@ -0,0 +1,28 @@
+package langfuse
+
+import "testing"
+
+func TestURLAliasesMatch(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, func(c *Config) {
+		c.BaseURL = "https://cloud.langfuse.com/"
+		c.ProjectID = "p1"
+	})
+	trace, err := client.CreateTrace(TraceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := trace.GetURL(), trace.URL(); got != want {
+		t.Errorf("GetURL = %q, URL = %q", got, want)
+	}
+	if got, want := client.GetDashboardURL(), client.DashboardURL(); got != want {
+		t.Errorf("GetDashboardURL = %q, DashboardURL = %q", got, want)
+	}
+	if got, want := client.GetTracesURL(FilterTag("a")), client.TracesURL(FilterTag("a")); got != want {
+		t.Errorf("GetTracesURL = %q, TracesURL = %q", got, want)
+	}
+	if want := "https://cloud.langfuse.com/project/p1/traces?tags=a"; client.TracesURL(FilterTag("a")) != want {
+		t.Errorf("TracesURL = %q, want %q", client.TracesURL(FilterTag("a")), want)
+	}
+}